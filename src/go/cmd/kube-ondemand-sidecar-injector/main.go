@@ -1,20 +1,43 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
 	"net/http"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	ginzap "github.com/gin-contrib/zap"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.uber.org/zap"
 
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/audit"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/auth"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/config"
+	auditcontroller "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/controllers/audit"
+	clusterscontroller "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/controllers/clusters"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/controllers/health"
 	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/controllers/injector"
+	operationscontroller "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/controllers/operations"
+	webhookscontroller "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/controllers/webhooks"
 	_ "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/docs"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/httputil"
 	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/kube"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/leader"
 	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/metrics"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/operations"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/reaper"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/tlsutil"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/tracing"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/webhooks"
 )
 
 // for generating swagger docs execute the following command at the src/go folder
@@ -35,6 +58,10 @@ import (
 // @in header
 // @name X-API-KEY
 
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+
 // @externalDocs.description	OpenAPI
 // @externalDocs.url			https://swagger.io/resources/open-api/
 func main() {
@@ -42,19 +69,151 @@ func main() {
 
 	//initialize required modules and types
 	logger := logging.New()
-	kubeClient := kube.New(logger, os.Getenv("SIDECAR_NAME_PREFIX"))
-	injectorController := injector.New(logger, kubeClient)
+
+	loader, err := config.NewLoader(os.Args[1:])
+	if err != nil {
+		logger.Log().Fatal("Invalid command line", zap.Error(err))
+	}
+
+	cfg, err := loader.Load()
+	if err != nil {
+		logger.Log().Fatal("Invalid configuration", zap.Error(err))
+	}
+
+	// closed on shutdown, stopping the background loops
+	stopCh := make(chan struct{})
+
+	configWatcher := config.NewWatcher(logger, loader, cfg, config.DefaultWatchInterval)
+
+	notifier, err := webhooks.New(logger, webhooks.Options{Webhooks: cfg.Webhooks})
+	if err != nil {
+		logger.Log().Fatal("Invalid webhooks", zap.Error(err))
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{Exporter: cfg.Tracing.Exporter})
+	if err != nil {
+		logger.Log().Fatal("Error setting up tracing", zap.Error(err))
+	}
+
+	cacheEnabled := cfg.Kubernetes.CacheEnabled
+
+	injectorMetrics := metrics.New(logger)
+	injectorMetrics.RegisterKubeClientMetrics()
+
+	kubeClient, err := kube.New(logger, kube.Options{
+		SidecarNamePrefix:  cfg.Sidecar.NamePrefix,
+		Impersonate:        cfg.Auth.Impersonate,
+		Policy:             cfg.NamespacePolicy(),
+		Kubeconfig:         cfg.Kubernetes.Kubeconfig,
+		KubeconfigContexts: cfg.Kubernetes.Contexts,
+		KubeconfigDir:      cfg.Kubernetes.KubeconfigDir,
+		DefaultCluster:     cfg.Kubernetes.DefaultCluster,
+		OperationTimeout:   cfg.Kubernetes.OperationTimeout.Duration,
+		CacheEnabled:       cacheEnabled,
+		HistoryLimit:       cfg.Sidecar.HistoryLimit,
+		ExpirationEnabled:  cfg.Sidecar.ReapInterval.Duration > 0,
+		Notifier:           notifier,
+		Metrics:            injectorMetrics,
+	})
+	if err != nil {
+		logger.Log().Fatal("Error creating the Kubernetes client", zap.Error(err))
+	}
+
+	// apply the reloadable settings, the API keys being read by the middleware at every request
+	configWatcher.OnReload(func(reloaded *config.Config) {
+		kubeClient.SetPolicy(reloaded.NamespacePolicy())
+
+		if err := notifier.SetWebhooks(reloaded.Webhooks); err != nil {
+			logger.Log().Error("Error reloading webhooks", zap.Error(err))
+		}
+	})
+	go configWatcher.Run(stopCh)
+
+	// Only the replica elected leader runs the background loops, while all the replicas serve the API
+	identity, err := os.Hostname()
+	if err != nil {
+		logger.Log().Fatal("Error getting the replica identity", zap.Error(err))
+	}
+
+	elector, err := leader.New(logger, kubeClient.DefaultClientset(), leader.Options{
+		Enabled:   cfg.LeaderElection.Enabled,
+		Namespace: cfg.LeaderElection.Namespace,
+		LeaseName: cfg.LeaderElection.LeaseName,
+		Identity:  identity,
+	})
+	if err != nil {
+		logger.Log().Fatal("Invalid leader election", zap.Error(err))
+	}
+
+	sidecarReaper := reaper.New(logger, kubeClient, cfg.Sidecar.ReapInterval.Duration)
+
+	electorDone := make(chan struct{})
+	go func() {
+		elector.Run(stopCh, sidecarReaper.Run)
+		close(electorDone)
+	}()
+
+	// counting the sidecars at every scrape is affordable only on the caches, which also grant listing Deployments cluster-wide
+	if cacheEnabled {
+		injectorMetrics.RegisterSidecarCounter(kubeClient)
+	}
+	auditor := audit.New(logger, newAuditSink(logger, &cfg.Audit))
+	operationsManager := operations.New(logger, newOperationsStore(logger, &cfg.Operations))
+	injectorController := injector.New(logger, kubeClient, auditor, operationsManager)
+	auditController := auditcontroller.New(logger, auditor)
+	operationsController := operationscontroller.New(logger, operationsManager)
+	webhooksController := webhookscontroller.New(logger, notifier)
+	clustersController := clusterscontroller.New(logger, kubeClient)
+	// refuses the new mutating requests on shutdown, a replaced pod being ready again within a few probe periods
+	drainer := httputil.NewDrainer(10 * time.Second)
+	healthController := health.New(logger, kubeClient, drainer, elector)
+
+	// Serve HTTPS with the certificates of the configuration, if any, reloaded when renewed
+	var certificates *tlsutil.Reloader
+	if cfg.Server.TLS.CertFile != "" {
+		certificates, err = tlsutil.New(logger, tlsutil.Options{
+			CertFile:     cfg.Server.TLS.CertFile,
+			KeyFile:      cfg.Server.TLS.KeyFile,
+			ClientCAFile: cfg.Server.TLS.ClientCAFile,
+		})
+		if err != nil {
+			logger.Log().Fatal("Error loading the TLS certificates", zap.Error(err))
+		}
+		go certificates.Run(stopCh)
+	}
 
 	// Attach Zap logger middleware from logger-module
 	r.Use(ginzap.Ginzap(logger.Log(), time.RFC3339, true))
-	r.Use(AuthMiddleware(os.Getenv("SECRET_API_KEY")))
+	r.Use(httputil.RequestIDMiddleware())
+	r.Use(injectorMetrics.Middleware())
+	r.Use(tracing.Middleware())
+	r.Use(AuthMiddleware(func() []string { return configWatcher.Current().Auth.APIKeys }))
+
+	// Authenticate callers with their client certificate, if mutual TLS is enabled
+	if certificates != nil && certificates.MutualTLS() {
+		r.Use(auth.ClientCertMiddleware())
+	}
+
+	// Authenticate callers with their own Kubernetes token, if enabled; the token identity prevails over the certificate one
+	if cfg.Auth.KubeAuth {
+		r.Use(auth.TokenReviewMiddleware(logger, kubeClient))
+	}
 
 	r.GET("/", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "Kubernetes OnDemand Sidecar Injector API up & running"})
 	})
 
+	r.GET("/healthz", healthController.GetLiveness)
+	r.GET("/readyz", healthController.GetReadiness)
+	r.GET("/status", healthController.GetStatus)
+
+	r.GET("/metrics", gin.WrapH(injectorMetrics.Handler()))
+
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	// the routes changing Deployments are refused on shutdown, while the reads are served until the listener is closed
+	mutating := drainer.Middleware()
+
 	// Routes Group, Routes and Handlers...
 	api := r.Group("/api")
 	{
@@ -62,22 +221,111 @@ func main() {
 		{
 			injectorApi.POST("/GetDeployments", injectorController.GetDeployments)
 			injectorApi.POST("/GetSingleDeployment", injectorController.GetSingleDeployment)
-			injectorApi.POST("/SetSidecar", injectorController.SetSidecar)
-			injectorApi.POST("/ClearSidecar", injectorController.ClearSidecar)
+			injectorApi.POST("/SetSidecar", mutating, injectorController.SetSidecar)
+			injectorApi.POST("/ClearSidecar", mutating, injectorController.ClearSidecar)
+			injectorApi.POST("/BulkSetSidecar", mutating, injectorController.BulkSetSidecar)
+			injectorApi.POST("/ClearAllSidecars", mutating, injectorController.ClearAllSidecars)
+			injectorApi.POST("/GetHistory", injectorController.GetHistory)
+			injectorApi.POST("/RestoreSnapshot", mutating, injectorController.RestoreSnapshot)
+		}
+
+		// resource-oriented API, backed by the same operations of the RPC-style one above
+		v2Api := api.Group("/v2")
+		{
+			v2Api.GET("/namespaces/:namespace/deployments", injectorController.ListDeployments)
+			v2Api.GET("/namespaces/:namespace/deployments/:name", injectorController.GetDeployment)
+			v2Api.PUT("/namespaces/:namespace/deployments/:name/sidecars/:sidecar", mutating, injectorController.PutSidecar)
+			v2Api.DELETE("/namespaces/:namespace/deployments/:name/sidecars/:sidecar", mutating, injectorController.DeleteSidecar)
+			v2Api.DELETE("/namespaces/:namespace/deployments/:name/sidecars", mutating, injectorController.DeleteAllSidecars)
+			v2Api.DELETE("/namespaces/:namespace/sidecars", mutating, injectorController.DeleteNamespaceSidecars)
+			v2Api.GET("/namespaces/:namespace/deployments/:name/history", injectorController.ListHistory)
+			v2Api.POST("/namespaces/:namespace/deployments/:name/history/:revision/restore", mutating, injectorController.PostRestore)
 		}
+
+		api.GET("/audit", auditController.GetAuditRecords)
+		api.GET("/clusters", clustersController.GetClusters)
+		api.GET("/operations/:id", operationsController.GetOperation)
+		api.GET("/webhooks/deliveries", webhooksController.GetDeliveries)
 	}
 
 	// Run the server
-	r.Run(":8080")
+	server := &http.Server{
+		Addr:    ":" + strconv.Itoa(cfg.Server.Port),
+		Handler: r,
+	}
+
+	serveErrors := make(chan error, 1)
+	go func() {
+		if certificates != nil {
+			server.TLSConfig = certificates.TLSConfig()
+			serveErrors <- server.ListenAndServeTLS("", "")
+		} else {
+			serveErrors <- server.ListenAndServe()
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	select {
+	case err := <-serveErrors:
+		logger.Log().Fatal("Error serving", zap.Error(err))
+	case received := <-signals:
+		logger.Log().Info("Shutting down", zap.String("signal", received.String()))
+	}
+
+	// Stop accepting changes and fail the readiness probe, serving the requests still routed to the pod
+	// until the endpoints are updated
+	drainer.Drain()
+	time.Sleep(cfg.Server.ShutdownDelay.Duration)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout.Duration)
+	defer cancel()
+
+	// Close the listener and wait for the in-flight requests, interrupting them at the deadline
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Log().Warn("Shutdown deadline reached, closing the in-flight requests", zap.Error(err))
+		server.Close()
+	}
+
+	// Wait for the asynchronous operations within the same deadline, cancelling and saving the remaining ones as interrupted
+	operationsManager.Shutdown(ctx)
+
+	close(stopCh)
+
+	// the shutdown deadline may be over already, so releasing the Lease, delivering the last notifications
+	// and flushing the spans are given a deadline of their own
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer flushCancel()
+
+	// Release the Lease, if leading, so that another replica takes over at once
+	if !waitUntil(flushCtx, func() { <-electorDone }) {
+		logger.Log().Warn("Shutdown deadline reached, leaving the Lease to expire")
+	}
+
+	// Deliver the notifications of the last changes, and flush their spans
+	if !waitUntil(flushCtx, notifier.Wait) {
+		logger.Log().Warn("Shutdown deadline reached, abandoning the pending webhook deliveries")
+	}
+
+	if err := shutdownTracing(flushCtx); err != nil {
+		logger.Log().Error("Error flushing the spans", zap.Error(err))
+	}
+
+	logger.Log().Info("Shutdown complete")
 }
 
-func AuthMiddleware(apiKey string) gin.HandlerFunc {
+// AuthMiddleware requires one of the API keys in the X-API-KEY header of the /api requests.
+// The keys are read at every request, so that they can be reloaded; no key is required when none is configured
+func AuthMiddleware(apiKeys func() []string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+			keys := apiKeys()
 			apiKeyHeader := c.GetHeader("X-API-KEY")
-			if apiKeyHeader != apiKey {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-				c.Abort()
+			if len(keys) > 0 && !slices.ContainsFunc(keys, func(key string) bool {
+				return subtle.ConstantTimeCompare([]byte(key), []byte(apiKeyHeader)) == 1
+			}) {
+				httputil.AbortWithError(c, http.StatusUnauthorized, "Unauthorized", nil)
 				return
 			}
 		}
@@ -85,3 +333,53 @@ func AuthMiddleware(apiKey string) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// waitUntil calls wait until it returns or ctx is done, reporting whether it returned
+func waitUntil(ctx context.Context, wait func()) bool {
+
+	done := make(chan struct{})
+	go func() {
+		wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// newAuditSink creates the audit sink of the configuration: "file" appends to the file path,
+// otherwise records are written to stdout and the most recent ones kept in memory for querying
+func newAuditSink(logger *logging.Logger, options *config.Audit) audit.ISink {
+
+	if options.Sink == config.StoreFile {
+		sink, err := audit.NewFileSink(options.FilePath)
+		if err != nil {
+			logger.Log().Fatal("Error opening audit log file", zap.Error(err))
+		}
+		return sink
+	}
+
+	return audit.NewWriterSink(os.Stdout, 1000)
+}
+
+// operationsCapacity is how many operations are kept by the stores before dropping the oldest ones
+const operationsCapacity = 1000
+
+// newOperationsStore creates the store of the asynchronous operations of the configuration: "file" saves them
+// to the folder, surviving restarts, otherwise the most recent ones are kept in memory
+func newOperationsStore(logger *logging.Logger, options *config.Operations) operations.IStore {
+
+	if options.Store == config.StoreFile {
+		store, err := operations.NewFileStore(options.Dir, operationsCapacity)
+		if err != nil {
+			logger.Log().Fatal("Error opening operations folder", zap.Error(err))
+		}
+		return store
+	}
+
+	return operations.NewMemoryStore(operationsCapacity)
+}
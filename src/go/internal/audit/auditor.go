@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"go.uber.org/zap"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+	auditmodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/audit"
+)
+
+// ISink persists audit records and permits to query them back
+type ISink interface {
+	Write(record *auditmodels.Record) error
+	Query(query *auditmodels.Query) ([]auditmodels.Record, error)
+}
+
+type Auditor struct {
+	logger *logging.Logger
+	sink   ISink
+}
+
+// NewAuditor creates a new instance of the Auditor writing to the given sink
+func New(logger *logging.Logger, sink ISink) *Auditor {
+	return &Auditor{
+		logger: logger,
+		sink:   sink,
+	}
+}
+
+// Log writes the audit record to the sink. Failures are logged and never interrupt the audited operation
+func (a *Auditor) Log(record *auditmodels.Record) {
+
+	if err := a.sink.Write(record); err != nil {
+		a.logger.Log().Error("Error writing audit record", zap.Error(err), zap.Any("record", record))
+	}
+}
+
+// Query returns the audit records matching the query, oldest first
+func (a *Auditor) Query(query *auditmodels.Query) ([]auditmodels.Record, error) {
+	return a.sink.Query(query)
+}
+
+// private functions and methods
+
+// keepLast returns the last limit records, or all of them when limit is not positive
+func keepLast(records []auditmodels.Record, limit int) []auditmodels.Record {
+
+	if limit > 0 && len(records) > limit {
+		return records[len(records)-limit:]
+	}
+
+	return records
+}
@@ -0,0 +1,113 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+	auditmodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/audit"
+)
+
+var baseTime = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+func testRecords() []auditmodels.Record {
+	return []auditmodels.Record{
+		{Time: baseTime, Operation: "SetSidecar", Namespace: "payments", DeploymentName: "payments-api", Caller: "jane"},
+		{Time: baseTime.Add(time.Hour), Operation: "SetSidecar", Namespace: "orders", DeploymentName: "orders-api", Caller: "john"},
+		{Time: baseTime.Add(2 * time.Hour), Operation: "ClearSidecar", Namespace: "payments", DeploymentName: "payments-api", Caller: "jane"},
+	}
+}
+
+func testQueries(t *testing.T, auditor *Auditor) {
+	testCases := []struct {
+		name              string
+		query             auditmodels.Query
+		expectedOperation []string
+	}{
+		{"no filters", auditmodels.Query{}, []string{"SetSidecar", "SetSidecar", "ClearSidecar"}},
+		{"namespace", auditmodels.Query{Namespace: "payments"}, []string{"SetSidecar", "ClearSidecar"}},
+		{"since", auditmodels.Query{Since: baseTime.Add(30 * time.Minute)}, []string{"SetSidecar", "ClearSidecar"}},
+		{"until", auditmodels.Query{Until: baseTime.Add(30 * time.Minute)}, []string{"SetSidecar"}},
+		{"deployment and limit", auditmodels.Query{DeploymentName: "payments-api", Limit: 1}, []string{"ClearSidecar"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			records, err := auditor.Query(&tc.query)
+			assert.NoError(t, err)
+
+			operations := make([]string, len(records))
+			for i, record := range records {
+				operations[i] = record.Operation
+			}
+			assert.Equal(t, tc.expectedOperation, operations)
+		})
+	}
+}
+
+func TestWriterSink(t *testing.T) {
+	var buffer bytes.Buffer
+	auditor := New(logging.New(), NewWriterSink(&buffer, 10))
+
+	records := testRecords()
+	for i := range records {
+		auditor.Log(&records[i])
+	}
+
+	// Check that every record is written as a JSON line
+	lines := bytes.Split(bytes.TrimSpace(buffer.Bytes()), []byte("\n"))
+	assert.Len(t, lines, 3)
+
+	var record auditmodels.Record
+	assert.NoError(t, json.Unmarshal(lines[0], &record))
+	assert.Equal(t, "payments-api", record.DeploymentName)
+
+	testQueries(t, auditor)
+}
+
+func TestWriterSinkCapacity(t *testing.T) {
+	sink := NewWriterSink(&bytes.Buffer{}, 2)
+
+	records := testRecords()
+	for i := range records {
+		assert.NoError(t, sink.Write(&records[i]))
+	}
+
+	// Check that only the most recent records are kept in memory
+	result, err := sink.Query(&auditmodels.Query{})
+	assert.NoError(t, err)
+	assert.Len(t, result, 2)
+	assert.Equal(t, "orders-api", result[0].DeploymentName)
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	sink, err := NewFileSink(path)
+	assert.NoError(t, err)
+	defer sink.Close()
+
+	auditor := New(logging.New(), sink)
+
+	records := testRecords()
+	for i := range records {
+		auditor.Log(&records[i])
+	}
+
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Len(t, bytes.Split(bytes.TrimSpace(content), []byte("\n")), 3)
+
+	testQueries(t, auditor)
+}
+
+func TestNewFileSinkWithoutPath(t *testing.T) {
+	_, err := NewFileSink("")
+	assert.Error(t, err)
+}
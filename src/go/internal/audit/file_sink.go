@@ -0,0 +1,97 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+
+	auditmodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/audit"
+)
+
+// maximum size of a single JSON line read back from the audit file
+const maxRecordSize = 1024 * 1024
+
+// FileSink appends audit records as JSON lines to a file, which is scanned on query
+type FileSink struct {
+	mutex sync.Mutex
+	path  string
+	file  *os.File
+}
+
+// NewFileSink creates a new instance of the FileSink appending to the file at the given path
+func NewFileSink(path string) (*FileSink, error) {
+
+	if path == "" {
+		return nil, errors.New("audit file path is required")
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileSink{
+		path: path,
+		file: file,
+	}, nil
+}
+
+func (s *FileSink) Write(record *auditmodels.Record) error {
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+func (s *FileSink) Query(query *auditmodels.Query) ([]auditmodels.Record, error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	result := make([]auditmodels.Record, 0)
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxRecordSize)
+	for scanner.Scan() {
+
+		var record auditmodels.Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// skip lines partially written or not produced by this sink
+			continue
+		}
+
+		if query.Matches(&record) {
+			result = append(result, record)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return keepLast(result, query.Limit), nil
+}
+
+// Close closes the underlying file
+func (s *FileSink) Close() error {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.file.Close()
+}
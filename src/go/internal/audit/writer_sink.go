@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	auditmodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/audit"
+)
+
+// WriterSink writes audit records as JSON lines to a writer (typically stdout)
+// and keeps the most recent ones in memory for querying
+type WriterSink struct {
+	mutex    sync.Mutex
+	writer   io.Writer
+	capacity int
+	records  []auditmodels.Record
+}
+
+// NewWriterSink creates a new instance of the WriterSink keeping up to capacity records in memory
+func NewWriterSink(writer io.Writer, capacity int) *WriterSink {
+	return &WriterSink{
+		writer:   writer,
+		capacity: capacity,
+		records:  make([]auditmodels.Record, 0),
+	}
+}
+
+func (s *WriterSink) Write(record *auditmodels.Record) error {
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.capacity > 0 {
+		s.records = keepLast(append(s.records, *record), s.capacity)
+	}
+
+	_, err = s.writer.Write(append(line, '\n'))
+	return err
+}
+
+func (s *WriterSink) Query(query *auditmodels.Query) ([]auditmodels.Record, error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result := make([]auditmodels.Record, 0)
+	for i := range s.records {
+		if query.Matches(&s.records[i]) {
+			result = append(result, s.records[i])
+		}
+	}
+
+	return keepLast(result, query.Limit), nil
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/httputil"
+)
+
+// ClientCertMiddleware requires the /api requests to present a verified client certificate, whose subject becomes
+// the caller identity as in Kubernetes: the common name is the username, and the organizations are the groups.
+// The certificate is verified by the TLS handshake against the configured client certificate authorities
+func ClientCertMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+			identity := identityFromClientCert(c.Request)
+			if identity == nil {
+				httputil.AbortWithError(c, http.StatusUnauthorized, "Unauthorized: client certificate is required", nil)
+				return
+			}
+
+			SetIdentity(c, identity)
+		}
+
+		c.Next()
+	}
+}
+
+// private functions and methods
+
+// identityFromClientCert returns the identity of the verified client certificate of the request, if any
+func identityFromClientCert(request *http.Request) *Identity {
+
+	if request.TLS == nil || len(request.TLS.VerifiedChains) == 0 || len(request.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	subject := request.TLS.VerifiedChains[0][0].Subject
+	if subject.CommonName == "" {
+		return nil
+	}
+
+	return &Identity{
+		Username: subject.CommonName,
+		Groups:   subject.Organization,
+	}
+}
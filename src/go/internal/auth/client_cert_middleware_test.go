@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientCertMiddleware(t *testing.T) {
+	var caller *Identity
+	r := gin.New()
+	r.Use(ClientCertMiddleware())
+	r.GET("/api/test", func(c *gin.Context) {
+		caller = IdentityFrom(c)
+		c.Status(http.StatusOK)
+	})
+	r.GET("/readyz", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	verified := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{
+		{Subject: pkix.Name{CommonName: "jane", Organization: []string{"sre", "oncall"}}},
+	}}}
+	anonymous := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{Organization: []string{"sre"}}}}}}
+
+	testCases := []struct {
+		name           string
+		path           string
+		state          *tls.ConnectionState
+		expectedStatus int
+	}{
+		{"verified certificate", "/api/test", verified, http.StatusOK},
+		{"plain http", "/api/test", nil, http.StatusUnauthorized},
+		{"no certificate", "/api/test", &tls.ConnectionState{}, http.StatusUnauthorized},
+		{"certificate without common name", "/api/test", anonymous, http.StatusUnauthorized},
+		{"path outside the api", "/readyz", &tls.ConnectionState{}, http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tc.path, nil)
+			req.TLS = tc.state
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+		})
+	}
+
+	// Check that the subject is mapped as in Kubernetes
+	assert.Equal(t, &Identity{Username: "jane", Groups: []string{"sre", "oncall"}}, caller)
+}
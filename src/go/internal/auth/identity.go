@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+const identityContextKey = "auth.identity"
+
+// APIKeyCaller names the callers authenticated only by the shared API Key
+const APIKeyCaller = "api-key"
+
+// Identity describes the authenticated caller of an API request
+type Identity struct {
+	Username string              `json:"Username"`
+	UID      string              `json:"UID,omitempty"`
+	Groups   []string            `json:"Groups,omitempty"`
+	Extra    map[string][]string `json:"Extra,omitempty"`
+}
+
+// SetIdentity stores the authenticated caller on the gin context
+func SetIdentity(c *gin.Context, identity *Identity) {
+	c.Set(identityContextKey, identity)
+}
+
+// IdentityFrom returns the authenticated caller stored on the gin context, if any
+func IdentityFrom(c *gin.Context) *Identity {
+	value, exists := c.Get(identityContextKey)
+	if !exists {
+		return nil
+	}
+
+	identity, ok := value.(*Identity)
+	if !ok {
+		return nil
+	}
+
+	return identity
+}
+
+// CallerName returns the username of the caller, or APIKeyCaller when not authenticated by a Kubernetes token
+func CallerName(identity *Identity) string {
+	if identity == nil {
+		return APIKeyCaller
+	}
+
+	return identity.Username
+}
@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/httputil"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+)
+
+type ITokenReviewer interface {
+	ReviewToken(ctx context.Context, token string) (*Identity, error)
+}
+
+// TokenReviewMiddleware authenticates the bearer token of every API request
+// against the Kubernetes API server and stores the resulting Identity on the context
+func TokenReviewMiddleware(logger *logging.Logger, reviewer ITokenReviewer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+			token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+			if !found || strings.TrimSpace(token) == "" {
+				httputil.AbortWithError(c, http.StatusUnauthorized, "Unauthorized: bearer token is required", nil)
+				return
+			}
+
+			identity, err := reviewer.ReviewToken(c.Request.Context(), strings.TrimSpace(token))
+			if err != nil {
+				logger.Log().Warn("Token review failed", zap.Error(err))
+
+				httputil.AbortWithError(c, http.StatusUnauthorized, "Unauthorized", nil)
+				return
+			}
+
+			SetIdentity(c, identity)
+		}
+
+		c.Next()
+	}
+}
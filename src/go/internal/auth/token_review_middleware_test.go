@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+)
+
+type fakeTokenReviewer struct {
+	identities map[string]*Identity
+}
+
+func (f *fakeTokenReviewer) ReviewToken(ctx context.Context, token string) (*Identity, error) {
+	identity, found := f.identities[token]
+	if !found {
+		return nil, errors.New("token not authenticated")
+	}
+	return identity, nil
+}
+
+func TestTokenReviewMiddleware(t *testing.T) {
+	reviewer := &fakeTokenReviewer{identities: map[string]*Identity{"good-token": {Username: "jane"}}}
+
+	var caller *Identity
+	r := gin.New()
+	r.Use(TokenReviewMiddleware(logging.New(), reviewer))
+	r.GET("/api/test", func(c *gin.Context) {
+		caller = IdentityFrom(c)
+		c.Status(http.StatusOK)
+	})
+	r.GET("/", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	testCases := []struct {
+		name           string
+		path           string
+		authorization  string
+		expectedStatus int
+	}{
+		{"valid token", "/api/test", "Bearer good-token", http.StatusOK},
+		{"missing token", "/api/test", "", http.StatusUnauthorized},
+		{"not a bearer token", "/api/test", "Basic good-token", http.StatusUnauthorized},
+		{"rejected token", "/api/test", "Bearer bad-token", http.StatusUnauthorized},
+		{"path outside the api", "/", "", http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tc.path, nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+		})
+	}
+
+	assert.Equal(t, "jane", caller.Username)
+}
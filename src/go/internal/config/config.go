@@ -0,0 +1,406 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/policy"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/tracing"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/webhooks"
+)
+
+// Stores of the audit records and of the asynchronous operations
+const (
+	StoreMemory = "memory"
+	StoreStdout = "stdout"
+	StoreFile   = "file"
+)
+
+// Config is the whole configuration of the injector, read from the YAML file and overridden by the environment variables
+// and the command line flags. Policy, Auth.APIKeys and Webhooks are reloaded when the file changes, the other settings
+// are structural and require a restart
+type Config struct {
+	Server     Server             `json:"server"`
+	Auth       Auth               `json:"auth"`
+	Policy     Policy             `json:"policy"`
+	Sidecar    Sidecar            `json:"sidecar"`
+	Kubernetes Kubernetes         `json:"kubernetes"`
+	Audit      Audit              `json:"audit"`
+	Operations Operations         `json:"operations"`
+	Webhooks   []webhooks.Webhook `json:"webhooks"`
+	Tracing    Tracing            `json:"tracing"`
+	// LeaderElection elects the replica running the background loops
+	LeaderElection LeaderElection `json:"leaderElection"`
+}
+
+type Server struct {
+	Port int `json:"port"`
+	TLS  TLS `json:"tls"`
+	// on termination, how long the mutating requests are refused and the readiness probe fails before closing the listener,
+	// so that the endpoints stop targeting the pod
+	ShutdownDelay v1.Duration `json:"shutdownDelay"`
+	// on termination, how long the in-flight requests and the asynchronous operations are waited for, the latter
+	// being cancelled past it
+	ShutdownTimeout v1.Duration `json:"shutdownTimeout"`
+}
+
+// TLS serves HTTPS when the certificate files are set, reloading them when they change
+type TLS struct {
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+	// enables mutual TLS: the /api requests require a client certificate issued by these authorities,
+	// whose subject becomes the caller identity
+	ClientCAFile string `json:"clientCAFile"`
+}
+
+type Auth struct {
+	// keys accepted in the X-API-KEY header
+	APIKeys []string `json:"apiKeys"`
+	// authenticate the callers with their own Kubernetes token
+	KubeAuth bool `json:"kubeAuth"`
+	// perform every Kubernetes API call as the caller, requires KubeAuth
+	Impersonate bool `json:"impersonate"`
+}
+
+type Policy struct {
+	AllowedNamespaces []string `json:"allowedNamespaces"`
+	DeniedNamespaces  []string `json:"deniedNamespaces"`
+	OptIn             OptIn    `json:"optIn"`
+}
+
+type OptIn struct {
+	Required bool   `json:"required"`
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+}
+
+type Sidecar struct {
+	NamePrefix string `json:"namePrefix"`
+	// snapshots of the pod template kept in the history of every deployment; no history when zero
+	HistoryLimit int `json:"historyLimit"`
+	// how often the sidecars whose TTL expired are looked for and removed; never removed when zero. Without the cache
+	// the deployments are listed namespace by namespace, requiring the policy to allow the namespaces by name
+	ReapInterval v1.Duration `json:"reapInterval"`
+}
+
+type Kubernetes struct {
+	// kubeconfig file, ~/.kube/config when empty
+	Kubeconfig string `json:"kubeconfig"`
+	// contexts of the kubeconfig file to load as clusters, "*" for all of them
+	Contexts []string `json:"contexts"`
+	// folder holding one kubeconfig file per cluster, named after the cluster
+	KubeconfigDir    string      `json:"kubeconfigDir"`
+	DefaultCluster   string      `json:"defaultCluster"`
+	OperationTimeout v1.Duration `json:"operationTimeout"`
+	CacheEnabled     bool        `json:"cacheEnabled"`
+}
+
+type Audit struct {
+	// StoreStdout, the default, or StoreFile
+	Sink     string `json:"sink"`
+	FilePath string `json:"filePath"`
+}
+
+type Operations struct {
+	// StoreMemory, the default, or StoreFile
+	Store string `json:"store"`
+	Dir   string `json:"dir"`
+}
+
+type Tracing struct {
+	Exporter string `json:"exporter"`
+}
+
+// LeaderElection elects by a Lease the only replica running the background loops, like the removal of the expired sidecars,
+// while all the replicas serve the API; when disabled every replica runs them
+type LeaderElection struct {
+	Enabled   bool   `json:"enabled"`
+	Namespace string `json:"namespace"`
+	LeaseName string `json:"leaseName"`
+}
+
+// Loader loads the configuration from the file named by the -config flag or the CONFIG_FILE environment variable, if any
+type Loader struct {
+	path       string
+	port       int
+	kubeconfig string
+}
+
+// NewLoader creates a new instance of the Loader for the command line arguments, without the program name
+func NewLoader(args []string) (*Loader, error) {
+
+	flags := flag.NewFlagSet("kube-ondemand-sidecar-injector", flag.ContinueOnError)
+
+	loader := &Loader{}
+	flags.StringVar(&loader.path, "config", os.Getenv("CONFIG_FILE"), "YAML configuration file")
+	flags.IntVar(&loader.port, "port", 0, "port to listen on, overriding the configuration")
+	flags.StringVar(&loader.kubeconfig, "kubeconfig", "", "absolute path to the kubeconfig file, overriding the configuration")
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	return loader, nil
+}
+
+// Path returns the path of the configuration file, empty when the configuration comes from the environment only
+func (l *Loader) Path() string {
+	return l.path
+}
+
+// Load reads the configuration file, applies the overrides of the environment variables and of the flags,
+// fills the defaults and validates the result
+func (l *Loader) Load() (*Config, error) {
+
+	cfg := defaults()
+
+	if l.path != "" {
+		content, err := os.ReadFile(l.path)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := yaml.UnmarshalStrict(content, cfg); err != nil {
+			return nil, fmt.Errorf("configuration file %s: %w", l.path, err)
+		}
+	}
+
+	if err := applyEnv(cfg); err != nil {
+		return nil, err
+	}
+
+	if l.port != 0 {
+		cfg.Server.Port = l.port
+	}
+	if l.kubeconfig != "" {
+		cfg.Kubernetes.Kubeconfig = l.kubeconfig
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Validate checks the settings, failing on the first invalid one
+func (cfg *Config) Validate() error {
+
+	if cfg.Server.Port <= 0 || cfg.Server.Port > 65535 {
+		return fmt.Errorf("server.port %d is not a valid port", cfg.Server.Port)
+	}
+
+	if cfg.Server.ShutdownDelay.Duration < 0 {
+		return fmt.Errorf("server.shutdownDelay %s is negative", cfg.Server.ShutdownDelay.Duration)
+	}
+	if cfg.Server.ShutdownTimeout.Duration < 0 {
+		return fmt.Errorf("server.shutdownTimeout %s is negative", cfg.Server.ShutdownTimeout.Duration)
+	}
+
+	if (cfg.Server.TLS.CertFile == "") != (cfg.Server.TLS.KeyFile == "") {
+		return errors.New("server.tls.certFile and server.tls.keyFile are required together")
+	}
+	if cfg.Server.TLS.ClientCAFile != "" && cfg.Server.TLS.CertFile == "" {
+		return errors.New("server.tls.clientCAFile requires server.tls.certFile and server.tls.keyFile")
+	}
+
+	if cfg.Auth.Impersonate && !cfg.Auth.KubeAuth {
+		return errors.New("auth.impersonate requires auth.kubeAuth for knowing the caller to impersonate")
+	}
+
+	if err := cfg.namespacePolicy().Validate(); err != nil {
+		return fmt.Errorf("policy: %w", err)
+	}
+
+	if cfg.Sidecar.HistoryLimit < 0 {
+		return fmt.Errorf("sidecar.historyLimit %d is negative", cfg.Sidecar.HistoryLimit)
+	}
+
+	if cfg.Sidecar.ReapInterval.Duration < 0 {
+		return fmt.Errorf("sidecar.reapInterval %s is negative", cfg.Sidecar.ReapInterval.Duration)
+	}
+	// the injector is granted listing Deployments cluster-wide only along with the cache
+	if _, named := cfg.namespacePolicy().AllowedNamespaceNames(); cfg.Sidecar.ReapInterval.Duration > 0 && !cfg.Kubernetes.CacheEnabled && !named {
+		return errors.New("sidecar.reapInterval requires kubernetes.cacheEnabled, or policy.allowedNamespaces naming the namespaces without patterns, for listing the deployments")
+	}
+
+	if cfg.Kubernetes.OperationTimeout.Duration < 0 {
+		return fmt.Errorf("kubernetes.operationTimeout %s is negative", cfg.Kubernetes.OperationTimeout.Duration)
+	}
+
+	if cfg.Audit.Sink != StoreStdout && cfg.Audit.Sink != StoreFile {
+		return fmt.Errorf("audit.sink '%s' is not one of %s, %s", cfg.Audit.Sink, StoreStdout, StoreFile)
+	}
+	if cfg.Audit.Sink == StoreFile && cfg.Audit.FilePath == "" {
+		return errors.New("audit.filePath is required by the file sink")
+	}
+
+	if cfg.Operations.Store != StoreMemory && cfg.Operations.Store != StoreFile {
+		return fmt.Errorf("operations.store '%s' is not one of %s, %s", cfg.Operations.Store, StoreMemory, StoreFile)
+	}
+	if cfg.Operations.Store == StoreFile && cfg.Operations.Dir == "" {
+		return errors.New("operations.dir is required by the file store")
+	}
+
+	if err := webhooks.Validate(cfg.Webhooks); err != nil {
+		return err
+	}
+
+	switch cfg.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout:
+	default:
+		return fmt.Errorf("tracing.exporter '%s' is not one of %s, %s, %s", cfg.Tracing.Exporter, tracing.ExporterOTLP, tracing.ExporterStdout, tracing.ExporterNone)
+	}
+
+	if cfg.LeaderElection.Enabled && (cfg.LeaderElection.Namespace == "" || cfg.LeaderElection.LeaseName == "") {
+		return errors.New("leaderElection.namespace and leaderElection.leaseName are required by the leader election")
+	}
+
+	return nil
+}
+
+// NamespacePolicy returns the policy of the validated configuration, with the opt-in defaults filled
+func (cfg *Config) NamespacePolicy() *policy.Policy {
+
+	namespacePolicy := cfg.namespacePolicy()
+	// only fills the defaults, the patterns being checked by Validate
+	namespacePolicy.Validate()
+
+	return namespacePolicy
+}
+
+// private functions and methods
+
+func (cfg *Config) namespacePolicy() *policy.Policy {
+	return &policy.Policy{
+		AllowedNamespaces: cfg.Policy.AllowedNamespaces,
+		DeniedNamespaces:  cfg.Policy.DeniedNamespaces,
+		OptInRequired:     cfg.Policy.OptIn.Required,
+		OptInKey:          cfg.Policy.OptIn.Key,
+		OptInValue:        cfg.Policy.OptIn.Value,
+	}
+}
+
+func defaults() *Config {
+	return &Config{
+		Server: Server{
+			Port:            8080,
+			ShutdownDelay:   v1.Duration{Duration: 5 * time.Second},
+			ShutdownTimeout: v1.Duration{Duration: 20 * time.Second},
+		},
+		Sidecar:    Sidecar{HistoryLimit: 10},
+		Kubernetes: Kubernetes{OperationTimeout: v1.Duration{Duration: 30 * time.Second}},
+		Audit:      Audit{Sink: StoreStdout},
+		Operations: Operations{Store: StoreMemory},
+		Webhooks:   make([]webhooks.Webhook, 0),
+		Tracing:    Tracing{Exporter: tracing.ExporterNone},
+		LeaderElection: LeaderElection{
+			LeaseName: "kube-ondemand-sidecar-injector",
+		},
+	}
+}
+
+// applyEnv overrides the settings with the environment variables set to a non empty value
+func applyEnv(cfg *Config) error {
+
+	errs := make([]error, 0)
+
+	envInt("PORT", &cfg.Server.Port, &errs)
+	envDuration("SHUTDOWN_DELAY", &cfg.Server.ShutdownDelay.Duration, &errs)
+	envDuration("SHUTDOWN_TIMEOUT", &cfg.Server.ShutdownTimeout.Duration, &errs)
+	envString("TLS_CERT_FILE", func(value string) { cfg.Server.TLS.CertFile = value })
+	envString("TLS_KEY_FILE", func(value string) { cfg.Server.TLS.KeyFile = value })
+	envString("TLS_CLIENT_CA_FILE", func(value string) { cfg.Server.TLS.ClientCAFile = value })
+	// the key of the environment is accepted besides the ones of the file, so that keys can be added without a restart
+	envString("SECRET_API_KEY", func(value string) { cfg.Auth.APIKeys = append(cfg.Auth.APIKeys, value) })
+	envString("KUBE_AUTH_ENABLED", func(value string) { cfg.Auth.KubeAuth = value == "true" })
+	envString("KUBE_IMPERSONATION_ENABLED", func(value string) { cfg.Auth.Impersonate = value == "true" })
+
+	envString("ALLOWED_NAMESPACES", func(value string) { cfg.Policy.AllowedNamespaces = splitList(value) })
+	envString("DENIED_NAMESPACES", func(value string) { cfg.Policy.DeniedNamespaces = splitList(value) })
+	envString("OPT_IN_REQUIRED", func(value string) { cfg.Policy.OptIn.Required = value == "true" })
+	envString("OPT_IN_KEY", func(value string) { cfg.Policy.OptIn.Key = value })
+	envString("OPT_IN_VALUE", func(value string) { cfg.Policy.OptIn.Value = value })
+
+	envString("SIDECAR_NAME_PREFIX", func(value string) { cfg.Sidecar.NamePrefix = value })
+	envInt("HISTORY_LIMIT", &cfg.Sidecar.HistoryLimit, &errs)
+	envDuration("REAP_INTERVAL", &cfg.Sidecar.ReapInterval.Duration, &errs)
+
+	envString("KUBECONFIG_CONTEXTS", func(value string) { cfg.Kubernetes.Contexts = splitList(value) })
+	envString("KUBECONFIG_DIR", func(value string) { cfg.Kubernetes.KubeconfigDir = value })
+	envString("DEFAULT_CLUSTER", func(value string) { cfg.Kubernetes.DefaultCluster = value })
+	envString("DEPLOYMENT_CACHE_ENABLED", func(value string) { cfg.Kubernetes.CacheEnabled = value == "true" })
+	envDuration("KUBE_OPERATION_TIMEOUT", &cfg.Kubernetes.OperationTimeout.Duration, &errs)
+
+	envString("AUDIT_LOG_SINK", func(value string) { cfg.Audit.Sink = value })
+	envString("AUDIT_LOG_FILE", func(value string) { cfg.Audit.FilePath = value })
+	envString("OPERATIONS_STORE", func(value string) { cfg.Operations.Store = value })
+	envString("OPERATIONS_DIR", func(value string) { cfg.Operations.Dir = value })
+	envString("OTEL_TRACES_EXPORTER", func(value string) { cfg.Tracing.Exporter = value })
+	envString("LEADER_ELECTION_ENABLED", func(value string) { cfg.LeaderElection.Enabled = value == "true" })
+	envString("LEADER_ELECTION_NAMESPACE", func(value string) { cfg.LeaderElection.Namespace = value })
+	envString("LEADER_ELECTION_LEASE_NAME", func(value string) { cfg.LeaderElection.LeaseName = value })
+
+	envString("WEBHOOKS", func(value string) {
+		webhookList, err := webhooks.ParseWebhooks(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid WEBHOOKS: %w", err))
+			return
+		}
+		cfg.Webhooks = webhookList
+	})
+
+	return errors.Join(errs...)
+}
+
+// envString calls apply with the value of the environment variable, when set and not empty
+func envString(name string, apply func(value string)) {
+	if value := os.Getenv(name); value != "" {
+		apply(value)
+	}
+}
+
+// envInt sets the target to the integer value of the environment variable, when set and not empty
+func envInt(name string, target *int, errs *[]error) {
+	envString(name, func(value string) {
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("invalid %s: %w", name, err))
+			return
+		}
+		*target = number
+	})
+}
+
+// envDuration sets the target to the duration value of the environment variable, like "30s", when set and not empty
+func envDuration(name string, target *time.Duration, errs *[]error) {
+	envString(name, func(value string) {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("invalid %s: %w", name, err))
+			return
+		}
+		*target = duration
+	})
+}
+
+// splitList splits a comma separated list, ignoring blanks
+func splitList(value string) []string {
+
+	result := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
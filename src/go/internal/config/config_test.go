@@ -0,0 +1,172 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/policy"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/webhooks"
+)
+
+const testConfig = `
+server:
+  port: 9090
+auth:
+  apiKeys: [first-key]
+  kubeAuth: true
+policy:
+  deniedNamespaces: [kube-*]
+  optIn:
+    required: true
+sidecar:
+  namePrefix: debug
+kubernetes:
+  contexts: [production, staging]
+  operationTimeout: 1m
+webhooks:
+- name: chatops
+  url: https://hooks.example.com/chatops
+  format: slack
+`
+
+func TestLoadDefaults(t *testing.T) {
+	cfg := load(t, "")
+
+	assert.Equal(t, defaults(), cfg)
+}
+
+func TestLoadFile(t *testing.T) {
+	cfg := load(t, testConfig)
+
+	assert.Equal(t, 9090, cfg.Server.Port)
+	assert.Equal(t, Auth{APIKeys: []string{"first-key"}, KubeAuth: true}, cfg.Auth)
+	assert.Equal(t, "debug", cfg.Sidecar.NamePrefix)
+	assert.Equal(t, []string{"production", "staging"}, cfg.Kubernetes.Contexts)
+	assert.Equal(t, time.Minute, cfg.Kubernetes.OperationTimeout.Duration)
+	assert.Equal(t, []webhooks.Webhook{{Name: "chatops", URL: "https://hooks.example.com/chatops", Format: webhooks.FormatSlack}}, cfg.Webhooks)
+
+	// Check that the defaults are kept for the settings missing in the file
+	assert.Equal(t, 10, cfg.Sidecar.HistoryLimit)
+	assert.Equal(t, StoreStdout, cfg.Audit.Sink)
+
+	// Check that the opt-in defaults are filled
+	assert.Equal(t, &policy.Policy{DeniedNamespaces: []string{"kube-*"}, OptInRequired: true, OptInKey: policy.DefaultOptInKey, OptInValue: "true"}, cfg.NamespacePolicy())
+}
+
+func TestLoadOverrides(t *testing.T) {
+	t.Setenv("SECRET_API_KEY", "second-key")
+	t.Setenv("PORT", "7070")
+	t.Setenv("ALLOWED_NAMESPACES", "payments, orders")
+	t.Setenv("KUBE_OPERATION_TIMEOUT", "45s")
+	t.Setenv("SHUTDOWN_TIMEOUT", "50s")
+	t.Setenv("REAP_INTERVAL", "1m")
+	t.Setenv("LEADER_ELECTION_NAMESPACE", "injector")
+	t.Setenv("OPT_IN_KEY", "")
+
+	loader, err := NewLoader([]string{"-config", writeConfig(t, testConfig), "-port", "6060", "-kubeconfig", "/etc/kubeconfig"})
+	assert.NoError(t, err)
+
+	cfg, err := loader.Load()
+	assert.NoError(t, err)
+
+	// Check that the flags override the environment, which overrides the file
+	assert.Equal(t, 6060, cfg.Server.Port)
+	assert.Equal(t, "/etc/kubeconfig", cfg.Kubernetes.Kubeconfig)
+	assert.Equal(t, []string{"payments", "orders"}, cfg.Policy.AllowedNamespaces)
+	assert.Equal(t, 45*time.Second, cfg.Kubernetes.OperationTimeout.Duration)
+	assert.Equal(t, 50*time.Second, cfg.Server.ShutdownTimeout.Duration)
+	assert.Equal(t, time.Minute, cfg.Sidecar.ReapInterval.Duration)
+	assert.Equal(t, LeaderElection{Namespace: "injector", LeaseName: "kube-ondemand-sidecar-injector"}, cfg.LeaderElection)
+
+	// Check that the key of the environment is accepted besides the ones of the file
+	assert.Equal(t, []string{"first-key", "second-key"}, cfg.Auth.APIKeys)
+
+	// Check that empty environment variables do not override
+	assert.Equal(t, "", cfg.Policy.OptIn.Key)
+}
+
+func TestLoadErrors(t *testing.T) {
+	testCases := []struct {
+		name          string
+		content       string
+		env           map[string]string
+		expectedError string
+	}{
+		{"unknown setting", "server:\n  address: localhost", nil, `unknown field "address"`},
+		{"malformed duration", "kubernetes:\n  operationTimeout: soon", nil, "invalid duration"},
+		{"invalid port", "server:\n  port: 70000", nil, "server.port 70000 is not a valid port"},
+		{"negative shutdown timeout", "server:\n  shutdownTimeout: -1s", nil, "server.shutdownTimeout -1s is negative"},
+		{"certificate without key", "server:\n  tls:\n    certFile: /etc/tls/tls.crt", nil, "server.tls.certFile and server.tls.keyFile are required together"},
+		{"client CA without certificate", "server:\n  tls:\n    clientCAFile: /etc/tls/ca.crt", nil, "server.tls.clientCAFile requires"},
+		{"impersonation without kube auth", "auth:\n  impersonate: true", nil, "auth.impersonate requires auth.kubeAuth"},
+		{"invalid namespace pattern", "policy:\n  allowedNamespaces: ['team-[']", nil, "policy: invalid namespace pattern"},
+		{"negative history limit", "sidecar:\n  historyLimit: -1", nil, "sidecar.historyLimit -1 is negative"},
+		{"unknown audit sink", "audit:\n  sink: syslog", nil, "audit.sink 'syslog' is not one of"},
+		{"file sink without path", "audit:\n  sink: file", nil, "audit.filePath is required"},
+		{"file store without folder", "operations:\n  store: file", nil, "operations.dir is required"},
+		{"invalid webhook", "webhooks:\n- name: chatops\n  url: ftp://example.com", nil, "webhook chatops: url"},
+		{"leader election without namespace", "leaderElection:\n  enabled: true", nil, "leaderElection.namespace and leaderElection.leaseName are required"},
+		{"negative reap interval", "sidecar:\n  reapInterval: -1m", nil, "sidecar.reapInterval -1m0s is negative"},
+		{"reap interval without cache", "sidecar:\n  reapInterval: 30s", nil, "sidecar.reapInterval requires kubernetes.cacheEnabled"},
+		{"reap interval with namespace patterns", "sidecar:\n  reapInterval: 30s\npolicy:\n  allowedNamespaces: [payments, team-*]", nil, "sidecar.reapInterval requires kubernetes.cacheEnabled"},
+		{"unknown exporter", "tracing:\n  exporter: zipkin", nil, "tracing.exporter 'zipkin' is not one of"},
+		{"invalid environment", "", map[string]string{"HISTORY_LIMIT": "ten", "PORT": "http"}, "invalid PORT"},
+		{"invalid environment duration", "", map[string]string{"SHUTDOWN_DELAY": "soon"}, "invalid SHUTDOWN_DELAY"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for name, value := range tc.env {
+				t.Setenv(name, value)
+			}
+
+			loader, err := NewLoader([]string{"-config", writeConfig(t, tc.content)})
+			assert.NoError(t, err)
+
+			_, err = loader.Load()
+			assert.ErrorContains(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestNewLoaderErrors(t *testing.T) {
+	_, err := NewLoader([]string{"-port", "http"})
+	assert.Error(t, err)
+
+	loader, err := NewLoader([]string{"-config", filepath.Join(t.TempDir(), "missing.yaml")})
+	assert.NoError(t, err)
+
+	_, err = loader.Load()
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// private functions and methods
+
+// load loads the configuration of the given file content, no file when empty
+func load(t *testing.T, content string) *Config {
+
+	args := []string{}
+	if content != "" {
+		args = append(args, "-config", writeConfig(t, content))
+	}
+
+	loader, err := NewLoader(args)
+	assert.NoError(t, err)
+
+	cfg, err := loader.Load()
+	assert.NoError(t, err)
+
+	return cfg
+}
+
+func writeConfig(t *testing.T, content string) string {
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+)
+
+// DefaultWatchInterval is how often the configuration file is checked for changes
+const DefaultWatchInterval = 10 * time.Second
+
+// Watcher holds the current configuration, reloading it when the configuration file changes. The file is polled,
+// rather than watched, since the mounted ConfigMaps are updated by swapping symbolic links
+type Watcher struct {
+	logger   *logging.Logger
+	loader   *Loader
+	interval time.Duration
+
+	mutex     sync.RWMutex
+	current   *Config
+	content   []byte
+	listeners []func(*Config)
+}
+
+// NewWatcher creates a new instance of the Watcher of the configuration loaded by the loader, current at start
+func NewWatcher(logger *logging.Logger, loader *Loader, current *Config, interval time.Duration) *Watcher {
+
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	watcher := &Watcher{
+		logger:   logger,
+		loader:   loader,
+		interval: interval,
+		current:  current,
+	}
+
+	// the file loaded at start is not a change
+	if loader.Path() != "" {
+		watcher.content, _ = os.ReadFile(loader.Path())
+	}
+
+	return watcher
+}
+
+// Current returns the current configuration, to be treated as read-only
+func (w *Watcher) Current() *Config {
+
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	return w.current
+}
+
+// OnReload registers a listener called with the new configuration after every reload
+func (w *Watcher) OnReload(listener func(*Config)) {
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.listeners = append(w.listeners, listener)
+}
+
+// Run checks the configuration file for changes until stopCh is closed. Nothing is done without a configuration file
+func (w *Watcher) Run(stopCh <-chan struct{}) {
+
+	if w.loader.Path() == "" {
+		return
+	}
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			w.reload()
+		}
+	}
+}
+
+// private functions and methods
+
+// reload loads the configuration again when the file content changed, keeping the current one if the new one is invalid.
+// Only the reloadable settings are applied, the changes of the structural ones being logged as requiring a restart
+func (w *Watcher) reload() bool {
+
+	content, err := os.ReadFile(w.loader.Path())
+	if err != nil {
+		w.logger.Log().Error("Error reading configuration file", zap.String("path", w.loader.Path()), zap.Error(err))
+		return false
+	}
+
+	if bytes.Equal(content, w.content) {
+		return false
+	}
+	w.content = content
+
+	loaded, err := w.loader.Load()
+	if err != nil {
+		w.logger.Log().Error("Invalid configuration, keeping the current one", zap.String("path", w.loader.Path()), zap.Error(err))
+		return false
+	}
+
+	w.mutex.Lock()
+
+	previous := w.current
+
+	// the structural settings are kept until restart
+	next := *previous
+	next.Auth.APIKeys = loaded.Auth.APIKeys
+	next.Policy = loaded.Policy
+	next.Webhooks = loaded.Webhooks
+
+	if !reflect.DeepEqual(&next, loaded) {
+		w.logger.Log().Warn("Configuration changes requiring a restart are ignored until then", zap.String("path", w.loader.Path()))
+	}
+
+	w.current = &next
+	listeners := w.listeners
+
+	w.mutex.Unlock()
+
+	w.logger.Log().Info("Configuration reloaded", zap.String("path", w.loader.Path()))
+
+	for _, listener := range listeners {
+		listener(&next)
+	}
+
+	return true
+}
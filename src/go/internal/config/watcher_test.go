@@ -0,0 +1,95 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+)
+
+func TestWatcherReload(t *testing.T) {
+	path := writeConfig(t, testConfig)
+
+	loader, err := NewLoader([]string{"-config", path})
+	assert.NoError(t, err)
+
+	initial, err := loader.Load()
+	assert.NoError(t, err)
+
+	watcher := NewWatcher(logging.New(), loader, initial, time.Millisecond)
+
+	reloaded := make([]*Config, 0)
+	watcher.OnReload(func(cfg *Config) { reloaded = append(reloaded, cfg) })
+
+	// Check that an unchanged file is not reloaded
+	assert.False(t, watcher.reload())
+
+	updated := strings.NewReplacer("first-key", "rotated-key", "kube-*", "kube-system", "port: 9090", "port: 9191").Replace(testConfig)
+	assert.NoError(t, os.WriteFile(path, []byte(updated), 0o600))
+
+	assert.True(t, watcher.reload())
+
+	// Check that the reloadable settings are applied, and the structural ones kept until restart
+	current := watcher.Current()
+	assert.Equal(t, []string{"rotated-key"}, current.Auth.APIKeys)
+	assert.Equal(t, []string{"kube-system"}, current.Policy.DeniedNamespaces)
+	assert.Equal(t, 9090, current.Server.Port)
+	assert.Equal(t, []*Config{current}, reloaded)
+
+	// Check that the initial configuration is left untouched
+	assert.Equal(t, []string{"first-key"}, initial.Auth.APIKeys)
+}
+
+func TestWatcherInvalidReload(t *testing.T) {
+	path := writeConfig(t, testConfig)
+
+	loader, err := NewLoader([]string{"-config", path})
+	assert.NoError(t, err)
+
+	initial, err := loader.Load()
+	assert.NoError(t, err)
+
+	watcher := NewWatcher(logging.New(), loader, initial, 0)
+	watcher.OnReload(func(cfg *Config) { t.Error("invalid configuration reloaded") })
+
+	assert.NoError(t, os.WriteFile(path, []byte("policy:\n  deniedNamespaces: ['kube-[']"), 0o600))
+	assert.False(t, watcher.reload())
+
+	assert.NoError(t, os.Remove(path))
+	assert.False(t, watcher.reload())
+
+	// Check that the current configuration is kept
+	assert.Same(t, initial, watcher.Current())
+}
+
+func TestWatcherRun(t *testing.T) {
+	path := writeConfig(t, testConfig)
+
+	loader, err := NewLoader([]string{"-config", path})
+	assert.NoError(t, err)
+
+	initial, err := loader.Load()
+	assert.NoError(t, err)
+
+	watcher := NewWatcher(logging.New(), loader, initial, time.Millisecond)
+
+	reloaded := make(chan *Config, 1)
+	watcher.OnReload(func(cfg *Config) { reloaded <- cfg })
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go watcher.Run(stopCh)
+
+	assert.NoError(t, os.WriteFile(path, []byte(strings.Replace(testConfig, "first-key", "rotated-key", 1)), 0o600))
+
+	select {
+	case cfg := <-reloaded:
+		assert.Equal(t, []string{"rotated-key"}, cfg.Auth.APIKeys)
+	case <-time.After(5 * time.Second):
+		t.Fatal("configuration not reloaded")
+	}
+}
@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/audit"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/httputil"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+	auditmodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/audit"
+)
+
+type AuditController struct {
+	logger  *logging.Logger
+	auditor *audit.Auditor
+}
+
+// NewAuditController creates a new instance of the AuditController
+func New(logger *logging.Logger, auditor *audit.Auditor) *AuditController {
+	return &AuditController{
+		logger:  logger,
+		auditor: auditor,
+	}
+}
+
+// GetAuditRecords godoc
+// @Summary      Query the audit log
+// @Description  Get the audit records of the injection and removal operations, oldest first, optionally filtered by time range, namespace and deployment
+// @Tags         audit
+// @Produce      json
+// @Param        since           query     string  false  "RFC3339 lower bound of the record time"
+// @Param        until           query     string  false  "RFC3339 upper bound of the record time"
+// @Param        cluster         query     string  false  "Cluster of the target deployment"
+// @Param        namespace       query     string  false  "Namespace of the target deployment"
+// @Param        deploymentName  query     string  false  "Name of the target deployment"
+// @Param        limit           query     int     false  "Maximum number of most recent records to return"
+// @Success      200  {object}  []auditmodels.Record
+// @Failure     400  {object}  httputil.HTTPError
+// @Failure     500  {object}  httputil.HTTPError
+// @Router       /api/audit [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (ac *AuditController) GetAuditRecords(c *gin.Context) {
+
+	var query auditmodels.Query
+
+	if err := c.ShouldBindQuery(&query); err != nil {
+		ac.logger.Log().Error("Error binding query", zap.Error(err))
+
+		httputil.NewError(c, http.StatusBadRequest, "Error binding query", err)
+		return
+	}
+
+	records, err := ac.auditor.Query(&query)
+
+	if err != nil {
+		ac.logger.Log().Error("Error querying audit records", zap.Error(err))
+
+		httputil.NewError(c, http.StatusInternalServerError, "Error querying audit records", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, records)
+}
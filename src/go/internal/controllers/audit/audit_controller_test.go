@@ -0,0 +1,76 @@
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/audit"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+	auditmodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/audit"
+)
+
+func TestNew(t *testing.T) {
+	logger := logging.New()
+	auditor := audit.New(logger, audit.NewWriterSink(io.Discard, 10))
+
+	controller := New(logger, auditor)
+
+	assert.Equal(t, logger, controller.logger)
+	assert.Equal(t, auditor, controller.auditor)
+}
+
+func TestGetAuditRecords(t *testing.T) {
+	logger := logging.New()
+	auditor := audit.New(logger, audit.NewWriterSink(io.Discard, 10))
+
+	baseTime := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	auditor.Log(&auditmodels.Record{Time: baseTime, Operation: "SetSidecar", Namespace: "payments", DeploymentName: "payments-api"})
+	auditor.Log(&auditmodels.Record{Time: baseTime.Add(time.Hour), Operation: "SetSidecar", Namespace: "orders", DeploymentName: "orders-api"})
+	auditor.Log(&auditmodels.Record{Time: baseTime.Add(2 * time.Hour), Operation: "ClearSidecar", Namespace: "payments", DeploymentName: "payments-api"})
+
+	controller := New(logger, auditor)
+
+	w, context := createGetRequestFor("/api/audit?namespace=payments&since=2024-05-01T10:30:00Z")
+
+	controller.GetAuditRecords(context)
+
+	// Check that the HTTP response status code is 200
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Check that only the records matching the filters are returned
+	var records []auditmodels.Record
+	err := json.Unmarshal(w.Body.Bytes(), &records)
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "ClearSidecar", records[0].Operation)
+}
+
+func TestGetAuditRecordsErrorBinding(t *testing.T) {
+	logger := logging.New()
+	auditor := audit.New(logger, audit.NewWriterSink(io.Discard, 10))
+
+	controller := New(logger, auditor)
+
+	w, context := createGetRequestFor("/api/audit?since=yesterday")
+
+	controller.GetAuditRecords(context)
+
+	// Check that the HTTP response status code is 400
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"code": 400, "message": "Error binding query", "details": "parsing time \"yesterday\" as \"2006-01-02T15:04:05Z07:00\": cannot parse \"yesterday\" as \"2006\""}`, w.Body.String())
+}
+
+func createGetRequestFor(url string) (*httptest.ResponseRecorder, *gin.Context) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", url, nil)
+	context, _ := gin.CreateTestContext(w)
+	context.Request = req
+	return w, context
+}
@@ -0,0 +1,39 @@
+package clusters
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/kube"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+)
+
+type ClustersController struct {
+	logger     *logging.Logger
+	kubeClient kube.IKubeClient
+}
+
+// NewClustersController creates a new instance of the ClustersController
+func New(logger *logging.Logger, kubeClient kube.IKubeClient) *ClustersController {
+	return &ClustersController{
+		logger:     logger,
+		kubeClient: kubeClient,
+	}
+}
+
+// GetClusters godoc
+// @Summary      List the clusters
+// @Description  Get the clusters the injector can act on, along with the reachability and the version of their API server
+// @Tags         clusters
+// @Produce      json
+// @Success      200  {object}  []clustermodels.Cluster
+// @Router       /api/clusters [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (cc *ClustersController) GetClusters(c *gin.Context) {
+
+	cc.logger.Log().Info("GetClusters")
+
+	c.JSON(http.StatusOK, cc.kubeClient.ListClusters(c.Request.Context()))
+}
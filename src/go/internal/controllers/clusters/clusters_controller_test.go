@@ -0,0 +1,59 @@
+package clusters
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/kube"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+	clustermodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/clusters"
+)
+
+func TestNew(t *testing.T) {
+	logger := logging.New()
+	kubeClient := new(kube.KubeClientMock)
+
+	controller := New(logger, kubeClient)
+
+	assert.Equal(t, logger, controller.logger)
+	assert.Equal(t, kubeClient, controller.kubeClient)
+}
+
+func TestGetClusters(t *testing.T) {
+	expectedClusters := []clustermodels.Cluster{
+		{Name: "production", Default: true, Host: "https://production.example:6443", Reachable: true, Version: "v1.30.2"},
+		{Name: "staging", Host: "https://staging.example:6443", Error: "connection refused"},
+	}
+
+	kubeClient := new(kube.KubeClientMock)
+	kubeClient.On("ListClusters", mock.Anything).Return(expectedClusters)
+
+	controller := New(logging.New(), kubeClient)
+
+	w, context := createGetRequestFor("/api/clusters")
+
+	controller.GetClusters(context)
+
+	// Check that the HTTP response status code is 200
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Check that the HTTP response body contains the expected clusters
+	var clusters []clustermodels.Cluster
+	err := json.Unmarshal(w.Body.Bytes(), &clusters)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedClusters, clusters)
+}
+
+func createGetRequestFor(url string) (*httptest.ResponseRecorder, *gin.Context) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", url, nil)
+	context, _ := gin.CreateTestContext(w)
+	context.Request = req
+	return w, context
+}
@@ -0,0 +1,150 @@
+package health
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/httputil"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/kube"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/leader"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+	clustermodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/clusters"
+	healthmodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/health"
+)
+
+// readinessTimeout bounds the check of the default cluster by the readiness probe, shorter than the timeout of the probes
+const readinessTimeout = 800 * time.Millisecond
+
+type HealthController struct {
+	logger     *logging.Logger
+	kubeClient kube.IKubeClient
+	drainer    *httputil.Drainer
+	elector    *leader.Elector
+	startedAt  time.Time
+}
+
+// NewHealthController creates a new instance of the HealthController, reporting not ready once the drainer drains, if any,
+// and the leader elected by the elector, if any
+func New(logger *logging.Logger, kubeClient kube.IKubeClient, drainer *httputil.Drainer, elector *leader.Elector) *HealthController {
+	return &HealthController{
+		logger:     logger,
+		kubeClient: kubeClient,
+		drainer:    drainer,
+		elector:    elector,
+		startedAt:  time.Now().UTC(),
+	}
+}
+
+// GetLiveness godoc
+// @Summary      Liveness probe
+// @Description  Report that the injector process is alive and serving requests, with no dependency checked
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  map[string]string
+// @Router       /healthz [get]
+func (hc *HealthController) GetLiveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "alive"})
+}
+
+// GetReadiness godoc
+// @Summary      Readiness probe
+// @Description  Report whether the injector is ready to serve requests, that is when the Deployment caches, if enabled, are synced
+// @Description  and the API server of the default cluster is reachable within 800 milliseconds; the other clusters are not checked.
+// @Description  The configuration is loaded before serving any request.
+// @Description  The injector is no longer ready once shutting down, so that the endpoints stop targeting it
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  map[string]string
+// @Failure      503  {object}  httputil.HTTPError
+// @Router       /readyz [get]
+func (hc *HealthController) GetReadiness(c *gin.Context) {
+
+	defaultCluster := hc.kubeClient.CheckDefaultCluster(c.Request.Context(), readinessTimeout)
+
+	_, reason := hc.checkReadiness(defaultCluster)
+	if reason != "" {
+		hc.logger.Log().Warn("Not ready: " + reason)
+
+		httputil.NewError(c, http.StatusServiceUnavailable, reason, nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
+
+// GetStatus godoc
+// @Summary      Detailed status
+// @Description  Get the readiness of the injector along with the outcome of every check, the uptime, the state of every cluster
+// @Description  and the identity of the replica and of the leader running the background loops.
+// @Description  The status is 503 when not ready, like the readiness probe
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  healthmodels.Status
+// @Failure      503  {object}  healthmodels.Status
+// @Router       /status [get]
+func (hc *HealthController) GetStatus(c *gin.Context) {
+
+	clusters := hc.kubeClient.ListClusters(c.Request.Context())
+
+	var defaultCluster clustermodels.Cluster
+	for _, cluster := range clusters {
+		if cluster.Default {
+			defaultCluster = cluster
+		}
+	}
+
+	cacheSynced, reason := hc.checkReadiness(defaultCluster)
+
+	// the endpoint is not authenticated, so the addresses of the API servers are not disclosed
+	for i := range clusters {
+		clusters[i].Host = ""
+	}
+
+	status := healthmodels.Status{
+		Ready:       reason == "",
+		Reason:      reason,
+		StartedAt:   hc.startedAt,
+		Uptime:      time.Since(hc.startedAt).Round(time.Second).String(),
+		CacheSynced: cacheSynced,
+		Replica:     hc.elector.Identity(),
+		Leader:      hc.elector.Leader(),
+		IsLeader:    hc.elector.IsLeader(),
+		Clusters:    clusters,
+	}
+
+	if !status.Ready {
+		c.JSON(http.StatusServiceUnavailable, status)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// private functions and methods
+
+// checkReadiness returns the outcome of the readiness checks, given the health of the default cluster, and why the
+// injector is not ready, empty when ready. Only the default cluster is required to be reachable, so that an unreachable
+// secondary cluster does not stop serving the other ones
+func (hc *HealthController) checkReadiness(defaultCluster clustermodels.Cluster) (cacheSynced bool, reason string) {
+
+	cacheSynced = hc.kubeClient.CacheSynced()
+
+	if hc.drainer.Draining() {
+		reason = "Shutting down"
+		return
+	}
+
+	if !cacheSynced {
+		reason = "Deployment cache not synced"
+		return
+	}
+
+	if !defaultCluster.Reachable {
+		reason = "Default cluster " + defaultCluster.Name + " not reachable"
+		return
+	}
+
+	return
+}
@@ -0,0 +1,146 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/httputil"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/kube"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/leader"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+	clustermodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/clusters"
+	healthmodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/health"
+)
+
+func TestNew(t *testing.T) {
+	logger := logging.New()
+	kubeClient := new(kube.KubeClientMock)
+
+	controller := New(logger, kubeClient, nil, nil)
+
+	assert.Equal(t, logger, controller.logger)
+	assert.Equal(t, kubeClient, controller.kubeClient)
+}
+
+func TestGetLiveness(t *testing.T) {
+	controller := New(logging.New(), new(kube.KubeClientMock), nil, nil)
+
+	w, context := createGetRequestFor("/healthz")
+
+	controller.GetLiveness(context)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status": "alive"}`, w.Body.String())
+}
+
+func TestGetReadiness(t *testing.T) {
+	testCases := []struct {
+		name           string
+		cacheSynced    bool
+		draining       bool
+		defaultCluster clustermodels.Cluster
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"ready", true, false, reachableClusters()[0], http.StatusOK, `{"status": "ready"}`},
+		{"cache not synced", false, false, reachableClusters()[0], http.StatusServiceUnavailable, `{"code": 503, "message": "Deployment cache not synced"}`},
+		{"default cluster not reachable", true, false, clustermodels.Cluster{Name: "production", Default: true, Error: "connection refused"}, http.StatusServiceUnavailable, `{"code": 503, "message": "Default cluster production not reachable"}`},
+		{"shutting down", true, true, reachableClusters()[0], http.StatusServiceUnavailable, `{"code": 503, "message": "Shutting down"}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			kubeClient := new(kube.KubeClientMock)
+			kubeClient.On("CacheSynced").Return(tc.cacheSynced)
+			// Check that only the default cluster is checked, within the timeout of the probe
+			kubeClient.On("CheckDefaultCluster", mock.Anything, readinessTimeout).Return(tc.defaultCluster)
+
+			drainer := httputil.NewDrainer(time.Second)
+			if tc.draining {
+				drainer.Drain()
+			}
+
+			controller := New(logging.New(), kubeClient, drainer, nil)
+
+			w, context := createGetRequestFor("/readyz")
+
+			controller.GetReadiness(context)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.JSONEq(t, tc.expectedBody, w.Body.String())
+			kubeClient.AssertNotCalled(t, "ListClusters", mock.Anything)
+		})
+	}
+}
+
+func TestGetStatus(t *testing.T) {
+	kubeClient := new(kube.KubeClientMock)
+	kubeClient.On("CacheSynced").Return(false)
+	kubeClient.On("ListClusters", mock.Anything).Return(reachableClusters())
+
+	elector, err := leader.New(logging.New(), nil, leader.Options{Identity: "replica-1"})
+	assert.NoError(t, err)
+
+	controller := New(logging.New(), kubeClient, nil, elector)
+
+	w, context := createGetRequestFor("/status")
+
+	controller.GetStatus(context)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	var status healthmodels.Status
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+
+	assert.False(t, status.Ready)
+	assert.Equal(t, "Deployment cache not synced", status.Reason)
+	assert.False(t, status.CacheSynced)
+	assert.Equal(t, controller.startedAt, status.StartedAt)
+	assert.Equal(t, "replica-1", status.Replica)
+	assert.Equal(t, "replica-1", status.Leader)
+	assert.True(t, status.IsLeader)
+
+	// Check that the addresses of the API servers are not disclosed
+	assert.Equal(t, []clustermodels.Cluster{{Name: "production", Default: true, Reachable: true, Version: "v1.33.1"}}, status.Clusters)
+}
+
+func TestGetStatusSecondaryClusterNotReachable(t *testing.T) {
+	kubeClient := new(kube.KubeClientMock)
+	kubeClient.On("CacheSynced").Return(true)
+	kubeClient.On("ListClusters", mock.Anything).Return(append(reachableClusters(), clustermodels.Cluster{Name: "staging", Error: "connection refused"}))
+
+	controller := New(logging.New(), kubeClient, nil, nil)
+
+	w, context := createGetRequestFor("/status")
+
+	controller.GetStatus(context)
+
+	// Check that every cluster is reported, while only the default one is required to be reachable
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var status healthmodels.Status
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+
+	assert.True(t, status.Ready)
+	assert.Len(t, status.Clusters, 2)
+	assert.Equal(t, "connection refused", status.Clusters[1].Error)
+}
+
+func reachableClusters() []clustermodels.Cluster {
+	return []clustermodels.Cluster{{Name: "production", Default: true, Host: "https://10.0.0.1:6443", Reachable: true, Version: "v1.33.1"}}
+}
+
+func createGetRequestFor(url string) (*httptest.ResponseRecorder, *gin.Context) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", url, nil)
+	context, _ := gin.CreateTestContext(w)
+	context.Request = req
+	return w, context
+}
@@ -1,25 +1,41 @@
 package injector
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/audit"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/auth"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/httputil"
 	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/kube"
 	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+	auditmodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/audit"
 	injectormodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/injector"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/operations"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/tracing"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
 type InjectorController struct {
 	logger     *logging.Logger
 	kubeClient kube.IKubeClient
+	auditor    *audit.Auditor
+	operations *operations.Manager
 }
 
 // NewInjectorController creates a new instance of the InjectorController
-func New(logger *logging.Logger, kubeClient kube.IKubeClient) *InjectorController {
+func New(logger *logging.Logger, kubeClient kube.IKubeClient, auditor *audit.Auditor, operationsManager *operations.Manager) *InjectorController {
 	return &InjectorController{
 		logger:     logger,
 		kubeClient: kubeClient,
+		auditor:    auditor,
+		operations: operationsManager,
 	}
 }
 
@@ -31,11 +47,15 @@ func New(logger *logging.Logger, kubeClient kube.IKubeClient) *InjectorControlle
 // @Produce      json
 // @Param        payload   body      injectormodels.GetDeploymentsPayload  true  "GetDeploymentsPayload type"
 // @Success      200  {object}  []injectormodels.Deployment
-// Failure      400  {object}  httputil.HTTPError
-// Failure      404  {object}  httputil.HTTPError
-// Failure      500  {object}  httputil.HTTPError
+// @Failure     400  {object}  httputil.HTTPError
+// @Failure     403  {object}  httputil.HTTPError
+// @Failure     404  {object}  httputil.HTTPError
+// @Failure     422  {object}  httputil.HTTPError
+// @Failure     500  {object}  httputil.HTTPError
+// @Failure     503  {object}  httputil.HTTPError
 // @Router       /api/injector/GetDeployments [post]
 // @Security ApiKeyAuth
+// @Security BearerAuth
 func (ic *InjectorController) GetDeployments(c *gin.Context) {
 
 	var payload injectormodels.GetDeploymentsPayload
@@ -43,22 +63,13 @@ func (ic *InjectorController) GetDeployments(c *gin.Context) {
 	if err := c.ShouldBindJSON(&payload); err != nil {
 		ic.logger.Log().Error("Error binding JSON", zap.Error(err))
 
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Error binding JSON"})
+		httputil.NewError(c, http.StatusBadRequest, "Error binding JSON", err)
 		return
 	}
 
 	ic.logger.Log().Info("GetDeployments - Received request", zap.Any("payload", payload))
 
-	deployments, err := ic.kubeClient.GetDeployments(payload.Namespace)
-
-	if err != nil {
-		ic.logger.Log().Error("Error getting deployments", zap.Error(err))
-
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Error getting deployments: " + err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, deployments)
+	ic.getDeployments(c, &payload)
 }
 
 // GetSingleDeployment godoc
@@ -69,11 +80,15 @@ func (ic *InjectorController) GetDeployments(c *gin.Context) {
 // @Produce      json
 // @Param        payload   body      injectormodels.GetSingleDeploymentPayload  true  "GetSingleDeploymentPayload type"
 // @Success      200  {object}  injectormodels.Deployment
-// Failure      400  {object}  httputil.HTTPError
-// Failure      404  {object}  httputil.HTTPError
-// Failure      500  {object}  httputil.HTTPError
+// @Failure     400  {object}  httputil.HTTPError
+// @Failure     403  {object}  httputil.HTTPError
+// @Failure     404  {object}  httputil.HTTPError
+// @Failure     422  {object}  httputil.HTTPError
+// @Failure     500  {object}  httputil.HTTPError
+// @Failure     503  {object}  httputil.HTTPError
 // @Router       /api/injector/GetSingleDeployment [post]
 // @Security ApiKeyAuth
+// @Security BearerAuth
 func (ic *InjectorController) GetSingleDeployment(c *gin.Context) {
 
 	var payload injectormodels.GetSingleDeploymentPayload
@@ -81,22 +96,13 @@ func (ic *InjectorController) GetSingleDeployment(c *gin.Context) {
 	if err := c.ShouldBindJSON(&payload); err != nil {
 		ic.logger.Log().Error("Error binding JSON", zap.Error(err))
 
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Error binding JSON"})
+		httputil.NewError(c, http.StatusBadRequest, "Error binding JSON", err)
 		return
 	}
 
 	ic.logger.Log().Info("GetSingleDeployment - Received request", zap.Any("payload", payload))
 
-	deployment, err := ic.kubeClient.GetSingleDeployment(payload.Namespace, payload.DeploymentName)
-
-	if err != nil {
-		ic.logger.Log().Error("Error getting single deployment", zap.Error(err))
-
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Error getting single deployment: " + err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, deployment)
+	ic.getSingleDeployment(c, &payload)
 }
 
 // SetSidecar godoc
@@ -106,12 +112,19 @@ func (ic *InjectorController) GetSingleDeployment(c *gin.Context) {
 // @Accept       json
 // @Produce      json
 // @Param        payload   body      injectormodels.SetSidecarPayload  true  "SetSidecarPayload type"
+// @Param        async     query     bool  false  "Run the call as an operation, answering 202 with its ID"
 // @Success      200  {object}  injectormodels.Deployment
-// Failure      400  {object}  httputil.HTTPError
-// Failure      404  {object}  httputil.HTTPError
-// Failure      500  {object}  httputil.HTTPError
+// @Success      202  {object}  operationsmodels.Operation
+// @Failure     400  {object}  httputil.HTTPError
+// @Failure     403  {object}  httputil.HTTPError
+// @Failure     404  {object}  httputil.HTTPError
+// @Failure     409  {object}  httputil.HTTPError
+// @Failure     422  {object}  httputil.HTTPError
+// @Failure     500  {object}  httputil.HTTPError
+// @Failure     503  {object}  httputil.HTTPError
 // @Router       /api/injector/SetSidecar [post]
 // @Security ApiKeyAuth
+// @Security BearerAuth
 func (ic *InjectorController) SetSidecar(c *gin.Context) {
 
 	var payload injectormodels.SetSidecarPayload
@@ -119,22 +132,13 @@ func (ic *InjectorController) SetSidecar(c *gin.Context) {
 	if err := c.ShouldBindJSON(&payload); err != nil {
 		ic.logger.Log().Error("Error binding JSON", zap.Error(err))
 
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Error binding JSON"})
+		httputil.NewError(c, http.StatusBadRequest, "Error binding JSON", err)
 		return
 	}
 
 	ic.logger.Log().Info("SetSidecar - Received request", zap.Any("payload", payload))
 
-	deployment, err := ic.kubeClient.SetSidecar(&payload)
-
-	if err != nil {
-		ic.logger.Log().Error("Error on setting sidecar", zap.Error(err))
-
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Error on setting sidecar: " + err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, deployment)
+	ic.setSidecar(c, &payload)
 }
 
 // ClearSidecar godoc
@@ -144,12 +148,18 @@ func (ic *InjectorController) SetSidecar(c *gin.Context) {
 // @Accept       json
 // @Produce      json
 // @Param        payload   body      injectormodels.ClearSidecarPayload  true  "ClearSidecarPayload type"
+// @Param        async     query     bool  false  "Run the call as an operation, answering 202 with its ID"
 // @Success      200  {object}  injectormodels.Deployment
-// Failure      400  {object}  httputil.HTTPError
-// Failure      404  {object}  httputil.HTTPError
-// Failure      500  {object}  httputil.HTTPError
+// @Success      202  {object}  operationsmodels.Operation
+// @Failure     400  {object}  httputil.HTTPError
+// @Failure     403  {object}  httputil.HTTPError
+// @Failure     404  {object}  httputil.HTTPError
+// @Failure     422  {object}  httputil.HTTPError
+// @Failure     500  {object}  httputil.HTTPError
+// @Failure     503  {object}  httputil.HTTPError
 // @Router       /api/injector/ClearSidecar [post]
 // @Security ApiKeyAuth
+// @Security BearerAuth
 func (ic *InjectorController) ClearSidecar(c *gin.Context) {
 
 	var payload injectormodels.ClearSidecarPayload
@@ -157,20 +167,598 @@ func (ic *InjectorController) ClearSidecar(c *gin.Context) {
 	if err := c.ShouldBindJSON(&payload); err != nil {
 		ic.logger.Log().Error("Error binding JSON", zap.Error(err))
 
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Error binding JSON"})
+		httputil.NewError(c, http.StatusBadRequest, "Error binding JSON", err)
 		return
 	}
 
 	ic.logger.Log().Info("ClearSidecar - Received request", zap.Any("payload", payload))
 
-	deployment, err := ic.kubeClient.ClearSidecar(&payload)
+	ic.clearSidecar(c, &payload)
+}
+
+// BulkSetSidecar godoc
+// @Summary      Activate the sidecar on many deployments
+// @Description  Set the sidecar for all the deployments of the given namespaces matching a label selector, reporting the outcome of each
+// @Tags         injector
+// @Accept       json
+// @Produce      json
+// @Param        payload   body      injectormodels.BulkSetSidecarPayload  true  "BulkSetSidecarPayload type"
+// @Param        async     query     bool  false  "Run the call as an operation, answering 202 with its ID"
+// @Success      200  {object}  []injectormodels.WorkloadResult
+// @Success      202  {object}  operationsmodels.Operation
+// @Failure     400  {object}  httputil.HTTPError
+// @Failure     403  {object}  httputil.HTTPError
+// @Failure     404  {object}  httputil.HTTPError
+// @Failure     422  {object}  httputil.HTTPError
+// @Failure     500  {object}  httputil.HTTPError
+// @Failure     503  {object}  httputil.HTTPError
+// @Router       /api/injector/BulkSetSidecar [post]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (ic *InjectorController) BulkSetSidecar(c *gin.Context) {
+
+	var payload injectormodels.BulkSetSidecarPayload
+
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		ic.logger.Log().Error("Error binding JSON", zap.Error(err))
+
+		httputil.NewError(c, http.StatusBadRequest, "Error binding JSON", err)
+		return
+	}
+
+	ic.logger.Log().Info("BulkSetSidecar - Received request", zap.Any("payload", payload))
+
+	ic.bulkSetSidecar(c, &payload)
+}
+
+// ClearAllSidecars godoc
+// @Summary      Remove all the sidecars
+// @Description  Remove every sidecar from a given deployment, or from all the deployments of a namespace matching a label selector
+// @Tags         injector
+// @Accept       json
+// @Produce      json
+// @Param        payload   body      injectormodels.ClearAllSidecarsPayload  true  "ClearAllSidecarsPayload type"
+// @Param        async     query     bool  false  "Run the call as an operation, answering 202 with its ID"
+// @Success      200  {object}  []injectormodels.WorkloadResult
+// @Success      202  {object}  operationsmodels.Operation
+// @Failure     400  {object}  httputil.HTTPError
+// @Failure     403  {object}  httputil.HTTPError
+// @Failure     404  {object}  httputil.HTTPError
+// @Failure     422  {object}  httputil.HTTPError
+// @Failure     500  {object}  httputil.HTTPError
+// @Failure     503  {object}  httputil.HTTPError
+// @Router       /api/injector/ClearAllSidecars [post]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (ic *InjectorController) ClearAllSidecars(c *gin.Context) {
+
+	var payload injectormodels.ClearAllSidecarsPayload
+
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		ic.logger.Log().Error("Error binding JSON", zap.Error(err))
+
+		httputil.NewError(c, http.StatusBadRequest, "Error binding JSON", err)
+		return
+	}
+
+	ic.logger.Log().Info("ClearAllSidecars - Received request", zap.Any("payload", payload))
+
+	ic.clearAllSidecars(c, &payload)
+}
+
+// GetHistory godoc
+// @Summary      Obtain the history of a Deployment
+// @Description  Get the snapshots of the pod template taken before every change of the injector, from the oldest
+// @Tags         injector
+// @Accept       json
+// @Produce      json
+// @Param        payload   body      injectormodels.GetHistoryPayload  true  "GetHistoryPayload type"
+// @Success      200  {object}  []injectormodels.HistoryEntry
+// @Failure     400  {object}  httputil.HTTPError
+// @Failure     403  {object}  httputil.HTTPError
+// @Failure     404  {object}  httputil.HTTPError
+// @Failure     422  {object}  httputil.HTTPError
+// @Failure     500  {object}  httputil.HTTPError
+// @Failure     503  {object}  httputil.HTTPError
+// @Router       /api/injector/GetHistory [post]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (ic *InjectorController) GetHistory(c *gin.Context) {
+
+	var payload injectormodels.GetHistoryPayload
+
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		ic.logger.Log().Error("Error binding JSON", zap.Error(err))
+
+		httputil.NewError(c, http.StatusBadRequest, "Error binding JSON", err)
+		return
+	}
+
+	ic.logger.Log().Info("GetHistory - Received request", zap.Any("payload", payload))
+
+	ic.getHistory(c, &payload)
+}
+
+// RestoreSnapshot godoc
+// @Summary      Restore a previous pod template
+// @Description  Restore the pod template of a given deployment to a revision of its history
+// @Tags         injector
+// @Accept       json
+// @Produce      json
+// @Param        payload   body      injectormodels.RestoreSnapshotPayload  true  "RestoreSnapshotPayload type"
+// @Param        async     query     bool  false  "Run the call as an operation, answering 202 with its ID"
+// @Success      200  {object}  injectormodels.Deployment
+// @Success      202  {object}  operationsmodels.Operation
+// @Failure     400  {object}  httputil.HTTPError
+// @Failure     403  {object}  httputil.HTTPError
+// @Failure     404  {object}  httputil.HTTPError
+// @Failure     409  {object}  httputil.HTTPError
+// @Failure     422  {object}  httputil.HTTPError
+// @Failure     500  {object}  httputil.HTTPError
+// @Failure     503  {object}  httputil.HTTPError
+// @Router       /api/injector/RestoreSnapshot [post]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (ic *InjectorController) RestoreSnapshot(c *gin.Context) {
+
+	var payload injectormodels.RestoreSnapshotPayload
+
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		ic.logger.Log().Error("Error binding JSON", zap.Error(err))
+
+		httputil.NewError(c, http.StatusBadRequest, "Error binding JSON", err)
+		return
+	}
+
+	ic.logger.Log().Info("RestoreSnapshot - Received request", zap.Any("payload", payload))
+
+	ic.restoreSnapshot(c, &payload)
+}
+
+// private functions and methods
+
+// getDeployments writes the deployments of the payload namespace
+func (ic *InjectorController) getDeployments(c *gin.Context, payload *injectormodels.GetDeploymentsPayload) {
+
+	kubeClient, ok := ic.kubeClientFor(c)
+	if !ok {
+		return
+	}
+
+	ctx, span := tracing.Start(c.Request.Context(), "InjectorController.GetDeployments", tracing.Target(payload.Cluster, payload.Namespace, "")...)
+	deployments, err := kubeClient.GetDeployments(ctx, payload)
+	tracing.End(span, err)
 
 	if err != nil {
-		ic.logger.Log().Error("Error clearing sidecar", zap.Error(err))
+		ic.logger.Log().Error("Error getting deployments", zap.Error(err))
 
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Error clearing sidecar: " + err.Error()})
+		httputil.NewError(c, statusOf(err), "Error getting deployments", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deployments)
+}
+
+// getSingleDeployment writes the deployment named by the payload
+func (ic *InjectorController) getSingleDeployment(c *gin.Context, payload *injectormodels.GetSingleDeploymentPayload) {
+
+	kubeClient, ok := ic.kubeClientFor(c)
+	if !ok {
+		return
+	}
+
+	ctx, span := tracing.Start(c.Request.Context(), "InjectorController.GetSingleDeployment", tracing.Target(payload.Cluster, payload.Namespace, payload.DeploymentName)...)
+	deployment, err := kubeClient.GetSingleDeployment(ctx, payload)
+	tracing.End(span, err)
+
+	if err != nil {
+		ic.logger.Log().Error("Error getting single deployment", zap.Error(err))
+
+		httputil.NewError(c, statusOf(err), "Error getting single deployment", err)
 		return
 	}
 
 	c.JSON(http.StatusOK, deployment)
 }
+
+// setSidecar injects the sidecar described by the payload, authorizing and auditing the call
+func (ic *InjectorController) setSidecar(c *gin.Context, payload *injectormodels.SetSidecarPayload) {
+
+	auditRecord := ic.startAudit(c, "SetSidecar", payload.Cluster, payload.Namespace, payload.DeploymentName, payload)
+	defer ic.finishAudit(c, auditRecord)
+
+	if !ic.authorize(c, payload.Cluster, payload.Namespace, payload.DeploymentName) {
+		return
+	}
+
+	kubeClient, ok := ic.kubeClientFor(c)
+	if !ok {
+		return
+	}
+
+	auditRecord.ContainersBefore = containerNamesOf(c.Request.Context(), kubeClient, payload.Cluster, payload.Namespace, payload.DeploymentName)
+
+	ic.execute(c, auditRecord, "Error on setting sidecar", func(ctx context.Context) (any, error) {
+
+		deployment, err := kubeClient.SetSidecar(ctx, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		auditRecord.ContainersAfter = deployment.ContainerNames
+
+		return deployment, nil
+	})
+}
+
+// clearSidecar removes the sidecar named by the payload, authorizing and auditing the call
+func (ic *InjectorController) clearSidecar(c *gin.Context, payload *injectormodels.ClearSidecarPayload) {
+
+	auditRecord := ic.startAudit(c, "ClearSidecar", payload.Cluster, payload.Namespace, payload.DeploymentName, payload)
+	defer ic.finishAudit(c, auditRecord)
+
+	if !ic.authorize(c, payload.Cluster, payload.Namespace, payload.DeploymentName) {
+		return
+	}
+
+	kubeClient, ok := ic.kubeClientFor(c)
+	if !ok {
+		return
+	}
+
+	auditRecord.ContainersBefore = containerNamesOf(c.Request.Context(), kubeClient, payload.Cluster, payload.Namespace, payload.DeploymentName)
+
+	ic.execute(c, auditRecord, "Error clearing sidecar", func(ctx context.Context) (any, error) {
+
+		deployment, err := kubeClient.ClearSidecar(ctx, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		auditRecord.ContainersAfter = deployment.ContainerNames
+
+		return deployment, nil
+	})
+}
+
+// bulkSetSidecar injects the sidecar described by the payload into many deployments, authorizing and auditing the call.
+// The caller must be allowed to update all the deployments of every namespace
+func (ic *InjectorController) bulkSetSidecar(c *gin.Context, payload *injectormodels.BulkSetSidecarPayload) {
+
+	auditRecord := ic.startAudit(c, "BulkSetSidecar", payload.Cluster, strings.Join(payload.Namespaces, ","), "", payload)
+	defer ic.finishAudit(c, auditRecord)
+
+	for _, namespace := range payload.Namespaces {
+		if !ic.authorize(c, payload.Cluster, namespace, "") {
+			return
+		}
+	}
+
+	kubeClient, ok := ic.kubeClientFor(c)
+	if !ok {
+		return
+	}
+
+	ic.execute(c, auditRecord, "Error bulk setting sidecar", func(ctx context.Context) (any, error) {
+
+		results, err := kubeClient.BulkSetSidecar(ctx, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		// the injected containers are recorded as the ones after the call, none of them being there before
+		auditRecord.ContainersBefore = make([]string, 0)
+		auditRecord.ContainersAfter = auditWorkloadResults(auditRecord, results)
+
+		return results, nil
+	})
+}
+
+// clearAllSidecars removes every sidecar from the deployments targeted by the payload, authorizing and auditing the call.
+// Without a deployment name, the caller must be allowed to update all the deployments of the namespace
+func (ic *InjectorController) clearAllSidecars(c *gin.Context, payload *injectormodels.ClearAllSidecarsPayload) {
+
+	auditRecord := ic.startAudit(c, "ClearAllSidecars", payload.Cluster, payload.Namespace, payload.DeploymentName, payload)
+	defer ic.finishAudit(c, auditRecord)
+
+	if !ic.authorize(c, payload.Cluster, payload.Namespace, payload.DeploymentName) {
+		return
+	}
+
+	kubeClient, ok := ic.kubeClientFor(c)
+	if !ok {
+		return
+	}
+
+	ic.execute(c, auditRecord, "Error clearing all sidecars", func(ctx context.Context) (any, error) {
+
+		results, err := kubeClient.ClearAllSidecars(ctx, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		// the removed containers are recorded as the ones before the call, none of them being left after
+		auditRecord.ContainersBefore = auditWorkloadResults(auditRecord, results)
+		auditRecord.ContainersAfter = make([]string, 0)
+
+		return results, nil
+	})
+}
+
+// getHistory writes the history of the deployment named by the payload.
+// The snapshots expose whole pod templates, so the call is authorized as the mutating ones
+func (ic *InjectorController) getHistory(c *gin.Context, payload *injectormodels.GetHistoryPayload) {
+
+	if !ic.authorize(c, payload.Cluster, payload.Namespace, payload.DeploymentName) {
+		return
+	}
+
+	kubeClient, ok := ic.kubeClientFor(c)
+	if !ok {
+		return
+	}
+
+	ctx, span := tracing.Start(c.Request.Context(), "InjectorController.GetHistory", tracing.Target(payload.Cluster, payload.Namespace, payload.DeploymentName)...)
+	history, err := kubeClient.GetHistory(ctx, payload)
+	tracing.End(span, err)
+
+	if err != nil {
+		ic.logger.Log().Error("Error getting history", zap.Error(err))
+
+		httputil.NewError(c, statusOf(err), "Error getting history", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// restoreSnapshot restores the revision named by the payload, authorizing and auditing the call
+func (ic *InjectorController) restoreSnapshot(c *gin.Context, payload *injectormodels.RestoreSnapshotPayload) {
+
+	auditRecord := ic.startAudit(c, "RestoreSnapshot", payload.Cluster, payload.Namespace, payload.DeploymentName, payload)
+	defer ic.finishAudit(c, auditRecord)
+
+	if !ic.authorize(c, payload.Cluster, payload.Namespace, payload.DeploymentName) {
+		return
+	}
+
+	kubeClient, ok := ic.kubeClientFor(c)
+	if !ok {
+		return
+	}
+
+	auditRecord.ContainersBefore = containerNamesOf(c.Request.Context(), kubeClient, payload.Cluster, payload.Namespace, payload.DeploymentName)
+
+	ic.execute(c, auditRecord, "Error restoring snapshot", func(ctx context.Context) (any, error) {
+
+		deployment, err := kubeClient.RestoreSnapshot(ctx, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		auditRecord.ContainersAfter = deployment.ContainerNames
+
+		return deployment, nil
+	})
+}
+
+// kubeClientFor returns the kube client acting on behalf of the authenticated caller, if any.
+// It writes the error response and returns false when the client cannot be created
+func (ic *InjectorController) kubeClientFor(c *gin.Context) (kube.IKubeClient, bool) {
+
+	kubeClient, err := ic.kubeClient.ForCaller(auth.IdentityFrom(c))
+
+	if err != nil {
+		ic.logger.Log().Error("Error creating kube client for caller", zap.Error(err))
+
+		httputil.NewError(c, http.StatusInternalServerError, "Error creating kube client for caller", err)
+		return nil, false
+	}
+
+	return kubeClient, true
+}
+
+// authorize verifies, when the caller has been authenticated by means of a Kubernetes token,
+// that the caller could update the target deployment by itself. It writes the error response and returns false otherwise
+func (ic *InjectorController) authorize(c *gin.Context, cluster string, namespace string, name string) bool {
+
+	caller := auth.IdentityFrom(c)
+	if caller == nil {
+		return true
+	}
+
+	allowed, err := ic.kubeClient.ReviewAccess(c.Request.Context(), caller, cluster, namespace, name)
+
+	if err != nil {
+		ic.logger.Log().Error("Error reviewing caller access", zap.Error(err))
+
+		httputil.NewError(c, statusOf(err), "Error reviewing caller access", err)
+		return false
+	}
+
+	if !allowed {
+		ic.logger.Log().Warn("Caller is not allowed to update deployment", zap.String("username", caller.Username), zap.String("namespace", namespace), zap.String("name", name))
+
+		httputil.NewError(c, http.StatusForbidden, "Forbidden: "+caller.Username+" cannot update deployment "+namespace+"/"+name, nil)
+		return false
+	}
+
+	return true
+}
+
+// startAudit prepares the audit record of a mutating call
+func (ic *InjectorController) startAudit(c *gin.Context, operation string, cluster string, namespace string, name string, payload any) *auditmodels.Record {
+
+	caller := auth.IdentityFrom(c)
+
+	record := &auditmodels.Record{
+		Time:           time.Now().UTC(),
+		Operation:      operation,
+		Caller:         auth.CallerName(caller),
+		SourceIP:       c.ClientIP(),
+		RequestID:      httputil.RequestIDFrom(c),
+		Cluster:        cluster,
+		Namespace:      namespace,
+		DeploymentName: name,
+		Payload:        payload,
+	}
+
+	if caller != nil {
+		record.CallerGroups = caller.Groups
+	}
+
+	return record
+}
+
+// finishAudit completes the audit record with the outcome of the call and writes it.
+// The record of a call accepted as an operation is written by the operation, once finished
+func (ic *InjectorController) finishAudit(c *gin.Context, record *auditmodels.Record) {
+
+	if c.Writer.Status() == http.StatusAccepted {
+		return
+	}
+
+	ic.logAudit(record, c.Writer.Status())
+}
+
+// logAudit completes the audit record with the status of the call and writes it. The result of a successful call
+// is kept when already set from the outcomes on its deployments
+func (ic *InjectorController) logAudit(record *auditmodels.Record, status int) {
+
+	record.StatusCode = status
+	record.DurationMs = time.Since(record.Time).Milliseconds()
+
+	if record.StatusCode < http.StatusBadRequest {
+		if record.Result == "" {
+			record.Result = auditmodels.ResultSucceeded
+		}
+	} else {
+		record.Result = auditmodels.ResultFailed
+		if record.Error == "" {
+			record.Error = http.StatusText(record.StatusCode)
+		}
+	}
+
+	ic.auditor.Log(record)
+}
+
+// execute performs the call of a mutating endpoint, which completes the audit record on success, and writes its response.
+// When the async query parameter is true, the call runs as an operation instead, detached from the request:
+// the response is 202 with the operation, whose result is the response the call would have had
+func (ic *InjectorController) execute(c *gin.Context, auditRecord *auditmodels.Record, errorMessage string, call func(ctx context.Context) (any, error)) {
+
+	attributes := tracing.Target(auditRecord.Cluster, auditRecord.Namespace, auditRecord.DeploymentName)
+
+	if c.Query("async") != "true" {
+		ctx, span := tracing.Start(c.Request.Context(), "InjectorController."+auditRecord.Operation, attributes...)
+
+		result, err := call(ctx)
+		tracing.End(span, err)
+
+		status, body := ic.outcomeOf(auditRecord, errorMessage, result, err)
+		c.JSON(status, body)
+		return
+	}
+
+	// the operation outlives the request, so it is traced on its own, linked to the request trace
+	link := trace.LinkFromContext(c.Request.Context())
+
+	operation := ic.operations.Start(auditRecord.Operation, auditRecord.Caller, auditRecord.RequestID, func(ctx context.Context) (any, int, error) {
+
+		ctx, span := tracing.Start(ctx, "InjectorController."+auditRecord.Operation, attributes...)
+		span.AddLink(link)
+
+		result, err := call(ctx)
+		tracing.End(span, err)
+
+		status, body := ic.outcomeOf(auditRecord, errorMessage, result, err)
+		ic.logAudit(auditRecord, status)
+
+		return body, status, err
+	})
+
+	c.Header("Location", "/api/operations/"+operation.ID)
+	c.JSON(http.StatusAccepted, operation)
+}
+
+// outcomeOf returns the status and the body of the response to a call, recording its error in the audit record
+func (ic *InjectorController) outcomeOf(auditRecord *auditmodels.Record, errorMessage string, result any, err error) (int, any) {
+
+	if err != nil {
+		ic.logger.Log().Error(errorMessage, zap.Error(err))
+
+		auditRecord.Error = err.Error()
+
+		return statusOf(err), httputil.NewHTTPError(statusOf(err), errorMessage, auditRecord.RequestID, err)
+	}
+
+	return http.StatusOK, result
+}
+
+// auditWorkloadResults completes the audit record of an operation acting on many deployments with the count of the failed ones,
+// whose errors are reported in the response, and the result from their outcomes, failed when all of them failed and partially
+// failed when only some did. The containers changed on the others are returned as deployment/container
+func auditWorkloadResults(record *auditmodels.Record, results []injectormodels.WorkloadResult) []string {
+
+	changed := make([]string, 0)
+	failed := 0
+
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+			continue
+		}
+
+		for _, container := range result.Containers {
+			changed = append(changed, result.DeploymentName+"/"+container)
+		}
+	}
+
+	switch {
+	case failed == 0:
+		record.Result = auditmodels.ResultSucceeded
+	case failed < len(results):
+		record.Result = auditmodels.ResultPartiallyFailed
+		record.Error = fmt.Sprintf("%d of %d deployments failed", failed, len(results))
+	default:
+		record.Result = auditmodels.ResultFailed
+		record.Error = fmt.Sprintf("%d of %d deployments failed", failed, len(results))
+	}
+
+	return changed
+}
+
+// statusOf maps the kind of the kube client error to the http status of the response
+func statusOf(err error) int {
+
+	switch {
+	case errors.Is(err, kube.ErrInvalid):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, kube.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, kube.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, kube.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, kube.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusInternalServerError
+}
+
+// containerNamesOf returns the containers of the deployment, read from the API server, or nil if it cannot be retrieved
+func containerNamesOf(ctx context.Context, kubeClient kube.IKubeClient, cluster string, namespace string, name string) []string {
+
+	deployment, err := kubeClient.GetSingleDeployment(ctx, &injectormodels.GetSingleDeploymentPayload{
+		Cluster:        cluster,
+		Namespace:      namespace,
+		DeploymentName: name,
+		Consistent:     true,
+	})
+	if err != nil {
+		return nil
+	}
+
+	return deployment.ContainerNames
+}
@@ -1,20 +1,28 @@
 package injector
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/audit"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/auth"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/httputil"
 	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/kube"
 	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
+	auditmodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/audit"
 	injectormodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/injector"
+	operationsmodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/operations"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/operations"
 )
 
 func TestNew(t *testing.T) {
@@ -22,10 +30,13 @@ func TestNew(t *testing.T) {
 
 	kubeClient := new(kube.KubeClientMock)
 
-	controller := New(logger, kubeClient)
+	auditor := newTestAuditor(logger)
+
+	controller := New(logger, kubeClient, auditor, newTestOperations(logger))
 
 	assert.Equal(t, logger, controller.logger)
 	assert.Equal(t, kubeClient, controller.kubeClient)
+	assert.Equal(t, auditor, controller.auditor)
 }
 
 func TestGetDeployments(t *testing.T) {
@@ -34,11 +45,11 @@ func TestGetDeployments(t *testing.T) {
 
 	kubeClient := new(kube.KubeClientMock)
 	expectedDeployments := []injectormodels.Deployment{{Name: "test-deployment"}}
-	kubeClient.On("GetDeployments", "test-namespace").Return(expectedDeployments, nil)
+	kubeClient.On("GetDeployments", mock.Anything, &injectormodels.GetDeploymentsPayload{Namespace: "test-namespace"}).Return(expectedDeployments, nil)
 
 	logger := logging.New()
 
-	controller := New(logger, kubeClient)
+	controller := New(logger, kubeClient, newTestAuditor(logger), newTestOperations(logger))
 
 	// Create a new gin context and request
 	// gin.Default()
@@ -56,16 +67,38 @@ func TestGetDeployments(t *testing.T) {
 	assert.Equal(t, expectedDeployments, deployments)
 }
 
+func TestGetDeploymentsRequestContext(t *testing.T) {
+	type contextKey string
+
+	kubeClient := new(kube.KubeClientMock)
+	kubeClient.On("GetDeployments", mock.MatchedBy(func(ctx context.Context) bool {
+		return ctx.Value(contextKey("test")) == "request"
+	}), mock.Anything).Return([]injectormodels.Deployment{}, nil)
+
+	logger := logging.New()
+
+	controller := New(logger, kubeClient, newTestAuditor(logger), newTestOperations(logger))
+
+	w, c := createPostRequestFor("/api/injector/GetDeployments", strings.NewReader(`{"Namespace": "test-namespace"}`))
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), contextKey("test"), "request"))
+
+	controller.GetDeployments(c)
+
+	// Check that the kube client received the context of the http request
+	assert.Equal(t, http.StatusOK, w.Code)
+	kubeClient.AssertExpectations(t)
+}
+
 func TestGetDeploymentsErrorBinding(t *testing.T) {
 	// You'll need to mock the kube.IKubeClient interface and its methods
 	// to test this function. Here's a basic example:
 
 	kubeClient := new(kube.KubeClientMock)
-	kubeClient.On("GetDeployments", "test-namespace").Return(nil, assert.AnError)
+	kubeClient.On("GetDeployments", mock.Anything, &injectormodels.GetDeploymentsPayload{Namespace: "test-namespace"}).Return(nil, assert.AnError)
 
 	logger := logging.New()
 
-	controller := New(logger, kubeClient)
+	controller := New(logger, kubeClient, newTestAuditor(logger), newTestOperations(logger))
 
 	// Create a new gin context and request
 	// gin.Default()
@@ -77,7 +110,7 @@ func TestGetDeploymentsErrorBinding(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 
 	// Check that the HTTP response body contains the expected error message
-	assert.JSONEq(t, `{"error": "Error binding JSON"}`, w.Body.String())
+	assertHTTPError(t, w, http.StatusBadRequest, "Error binding JSON")
 }
 
 func TestGetDeploymentsErrorGettingDeployments(t *testing.T) {
@@ -85,11 +118,11 @@ func TestGetDeploymentsErrorGettingDeployments(t *testing.T) {
 	// to test this function. Here's a basic example:
 
 	kubeClient := new(kube.KubeClientMock)
-	kubeClient.On("GetDeployments", "test-namespace").Return(nil, assert.AnError)
+	kubeClient.On("GetDeployments", mock.Anything, &injectormodels.GetDeploymentsPayload{Namespace: "test-namespace"}).Return(nil, assert.AnError)
 
 	logger := logging.New()
 
-	controller := New(logger, kubeClient)
+	controller := New(logger, kubeClient, newTestAuditor(logger), newTestOperations(logger))
 
 	// Create a new gin context and request
 	// gin.Default()
@@ -97,11 +130,11 @@ func TestGetDeploymentsErrorGettingDeployments(t *testing.T) {
 
 	controller.GetDeployments(context)
 
-	// Check that the HTTP response status code is 400
-	assert.Equal(t, http.StatusBadRequest, w.Code)
+	// Check that the HTTP response status code is 500
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
 
 	// Check that the HTTP response body contains the expected error message
-	assert.JSONEq(t, `{"error": "Error getting deployments: assert.AnError general error for testing"}`, w.Body.String())
+	assert.JSONEq(t, `{"code": 500, "message": "Error getting deployments", "details": "assert.AnError general error for testing"}`, w.Body.String())
 }
 
 func TestGetSingleDeployment(t *testing.T) {
@@ -110,11 +143,11 @@ func TestGetSingleDeployment(t *testing.T) {
 
 	kubeClient := new(kube.KubeClientMock)
 	expectedDeployment := injectormodels.Deployment{Name: "test-deployment"}
-	kubeClient.On("GetSingleDeployment", "test-namespace", "test-deployment").Return(expectedDeployment, nil)
+	kubeClient.On("GetSingleDeployment", mock.Anything, &injectormodels.GetSingleDeploymentPayload{Namespace: "test-namespace", DeploymentName: "test-deployment"}).Return(expectedDeployment, nil)
 
 	logger := logging.New()
 
-	controller := New(logger, kubeClient)
+	controller := New(logger, kubeClient, newTestAuditor(logger), newTestOperations(logger))
 
 	// Create a new gin context and request
 	// gin.Default()
@@ -137,11 +170,11 @@ func TestGetSingleDeploymentErrorBinding(t *testing.T) {
 	// to test this function. Here's a basic example:
 
 	kubeClient := new(kube.KubeClientMock)
-	kubeClient.On("GetSingleDeployment", "test-namespace", "test-deployment").Return(injectormodels.Deployment{}, assert.AnError)
+	kubeClient.On("GetSingleDeployment", mock.Anything, &injectormodels.GetSingleDeploymentPayload{Namespace: "test-namespace", DeploymentName: "test-deployment"}).Return(injectormodels.Deployment{}, assert.AnError)
 
 	logger := logging.New()
 
-	controller := New(logger, kubeClient)
+	controller := New(logger, kubeClient, newTestAuditor(logger), newTestOperations(logger))
 
 	// Create a new gin context and request
 	// gin.Default()
@@ -153,7 +186,7 @@ func TestGetSingleDeploymentErrorBinding(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 
 	// Check that the HTTP response body contains the expected error message
-	assert.JSONEq(t, `{"error": "Error binding JSON"}`, w.Body.String())
+	assertHTTPError(t, w, http.StatusBadRequest, "Error binding JSON")
 }
 
 func TestGetSingleDeploymentErrorGettingDeployment(t *testing.T) {
@@ -161,11 +194,11 @@ func TestGetSingleDeploymentErrorGettingDeployment(t *testing.T) {
 	// to test this function. Here's a basic example:
 
 	kubeClient := new(kube.KubeClientMock)
-	kubeClient.On("GetSingleDeployment", "test-namespace", "test-deployment").Return(nil, assert.AnError)
+	kubeClient.On("GetSingleDeployment", mock.Anything, &injectormodels.GetSingleDeploymentPayload{Namespace: "test-namespace", DeploymentName: "test-deployment"}).Return(nil, assert.AnError)
 
 	logger := logging.New()
 
-	controller := New(logger, kubeClient)
+	controller := New(logger, kubeClient, newTestAuditor(logger), newTestOperations(logger))
 
 	// Create a new gin context and request
 	// gin.Default()
@@ -173,11 +206,11 @@ func TestGetSingleDeploymentErrorGettingDeployment(t *testing.T) {
 
 	controller.GetSingleDeployment(context)
 
-	// Check that the HTTP response status code is 400
-	assert.Equal(t, http.StatusBadRequest, w.Code)
+	// Check that the HTTP response status code is 500
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
 
 	// Check that the HTTP response body contains the expected error message
-	assert.JSONEq(t, `{"error": "Error getting single deployment: assert.AnError general error for testing"}`, w.Body.String())
+	assert.JSONEq(t, `{"code": 500, "message": "Error getting single deployment", "details": "assert.AnError general error for testing"}`, w.Body.String())
 }
 
 func TestSetSidecar(t *testing.T) {
@@ -186,11 +219,12 @@ func TestSetSidecar(t *testing.T) {
 
 	kubeClient := new(kube.KubeClientMock)
 	expectedDeployment := injectormodels.Deployment{Name: "test-deployment"}
-	kubeClient.On("SetSidecar", mock.Anything).Return(expectedDeployment, nil)
+	kubeClient.On("GetSingleDeployment", mock.Anything, &injectormodels.GetSingleDeploymentPayload{Namespace: "test-namespace", DeploymentName: "test-deployment", Consistent: true}).Return(injectormodels.Deployment{Name: "test-deployment"}, nil)
+	kubeClient.On("SetSidecar", mock.Anything, mock.Anything).Return(expectedDeployment, nil)
 
 	logger := logging.New()
 
-	controller := New(logger, kubeClient)
+	controller := New(logger, kubeClient, newTestAuditor(logger), newTestOperations(logger))
 
 	// Create a new gin context and request
 	// gin.Default()
@@ -213,11 +247,12 @@ func TestSetSidecarErrorBinding(t *testing.T) {
 	// to test this function. Here's a basic example:
 
 	kubeClient := new(kube.KubeClientMock)
-	kubeClient.On("SetSidecar", mock.Anything).Return(injectormodels.Deployment{}, assert.AnError)
+	kubeClient.On("GetSingleDeployment", mock.Anything, &injectormodels.GetSingleDeploymentPayload{Namespace: "test-namespace", DeploymentName: "test-deployment", Consistent: true}).Return(injectormodels.Deployment{Name: "test-deployment"}, nil)
+	kubeClient.On("SetSidecar", mock.Anything, mock.Anything).Return(injectormodels.Deployment{}, assert.AnError)
 
 	logger := logging.New()
 
-	controller := New(logger, kubeClient)
+	controller := New(logger, kubeClient, newTestAuditor(logger), newTestOperations(logger))
 
 	// Create a new gin context and request
 	// gin.Default()
@@ -229,7 +264,7 @@ func TestSetSidecarErrorBinding(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 
 	// Check that the HTTP response body contains the expected error message
-	assert.JSONEq(t, `{"error": "Error binding JSON"}`, w.Body.String())
+	assertHTTPError(t, w, http.StatusBadRequest, "Error binding JSON")
 }
 
 func TestSetSidecarErrorSettingSidecar(t *testing.T) {
@@ -237,11 +272,12 @@ func TestSetSidecarErrorSettingSidecar(t *testing.T) {
 	// to test this function. Here's a basic example:
 
 	kubeClient := new(kube.KubeClientMock)
-	kubeClient.On("SetSidecar", mock.Anything).Return(nil, assert.AnError)
+	kubeClient.On("GetSingleDeployment", mock.Anything, &injectormodels.GetSingleDeploymentPayload{Namespace: "test-namespace", DeploymentName: "test-deployment", Consistent: true}).Return(injectormodels.Deployment{Name: "test-deployment"}, nil)
+	kubeClient.On("SetSidecar", mock.Anything, mock.Anything).Return(nil, assert.AnError)
 
 	logger := logging.New()
 
-	controller := New(logger, kubeClient)
+	controller := New(logger, kubeClient, newTestAuditor(logger), newTestOperations(logger))
 
 	// Create a new gin context and request
 	// gin.Default()
@@ -249,11 +285,353 @@ func TestSetSidecarErrorSettingSidecar(t *testing.T) {
 
 	controller.SetSidecar(context)
 
-	// Check that the HTTP response status code is 400
-	assert.Equal(t, http.StatusBadRequest, w.Code)
+	// Check that the HTTP response status code is 500
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
 
 	// Check that the HTTP response body contains the expected error message
-	assert.JSONEq(t, `{"error": "Error on setting sidecar: assert.AnError general error for testing"}`, w.Body.String())
+	assert.JSONEq(t, `{"code": 500, "message": "Error on setting sidecar", "details": "assert.AnError general error for testing"}`, w.Body.String())
+}
+
+func TestSetSidecarAuthorizedCaller(t *testing.T) {
+	caller := &auth.Identity{Username: "jane", Groups: []string{"developers"}}
+
+	kubeClient := new(kube.KubeClientMock)
+	expectedDeployment := injectormodels.Deployment{Name: "test-deployment"}
+	kubeClient.On("ReviewAccess", mock.Anything, caller, "", "test-namespace", "test-deployment").Return(true, nil)
+	kubeClient.On("GetSingleDeployment", mock.Anything, &injectormodels.GetSingleDeploymentPayload{Namespace: "test-namespace", DeploymentName: "test-deployment", Consistent: true}).Return(injectormodels.Deployment{Name: "test-deployment"}, nil)
+	kubeClient.On("SetSidecar", mock.Anything, mock.Anything).Return(expectedDeployment, nil)
+
+	logger := logging.New()
+
+	controller := New(logger, kubeClient, newTestAuditor(logger), newTestOperations(logger))
+
+	w, context := createPostRequestFor("/api/injector/SetSidecar", strings.NewReader(`{"Namespace": "test-namespace", "DeploymentName": "test-deployment", "SidecarContainerName": "sidecar-container", "SidecarImage": "sidecar-image"}`))
+	auth.SetIdentity(context, caller)
+
+	controller.SetSidecar(context)
+
+	// Check that the HTTP response status code is 200
+	assert.Equal(t, http.StatusOK, w.Code)
+	kubeClient.AssertExpectations(t)
+}
+
+func TestSetSidecarForbiddenCaller(t *testing.T) {
+	caller := &auth.Identity{Username: "jane"}
+
+	kubeClient := new(kube.KubeClientMock)
+	kubeClient.On("ReviewAccess", mock.Anything, caller, "", "test-namespace", "test-deployment").Return(false, nil)
+
+	logger := logging.New()
+
+	controller := New(logger, kubeClient, newTestAuditor(logger), newTestOperations(logger))
+
+	w, context := createPostRequestFor("/api/injector/SetSidecar", strings.NewReader(`{"Namespace": "test-namespace", "DeploymentName": "test-deployment", "SidecarContainerName": "sidecar-container", "SidecarImage": "sidecar-image"}`))
+	auth.SetIdentity(context, caller)
+
+	controller.SetSidecar(context)
+
+	// Check that the HTTP response status code is 403 and the kube client is never asked to update
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.JSONEq(t, `{"code": 403, "message": "Forbidden: jane cannot update deployment test-namespace/test-deployment"}`, w.Body.String())
+	kubeClient.AssertNotCalled(t, "SetSidecar", mock.Anything, mock.Anything)
+}
+
+func TestSetSidecarErrorReviewingAccess(t *testing.T) {
+	caller := &auth.Identity{Username: "jane"}
+
+	kubeClient := new(kube.KubeClientMock)
+	kubeClient.On("ReviewAccess", mock.Anything, caller, "", "test-namespace", "test-deployment").Return(false, assert.AnError)
+
+	logger := logging.New()
+
+	controller := New(logger, kubeClient, newTestAuditor(logger), newTestOperations(logger))
+
+	w, context := createPostRequestFor("/api/injector/SetSidecar", strings.NewReader(`{"Namespace": "test-namespace", "DeploymentName": "test-deployment", "SidecarContainerName": "sidecar-container", "SidecarImage": "sidecar-image"}`))
+	auth.SetIdentity(context, caller)
+
+	controller.SetSidecar(context)
+
+	// Check that the HTTP response status code is 500
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"code": 500, "message": "Error reviewing caller access", "details": "assert.AnError general error for testing"}`, w.Body.String())
+	kubeClient.AssertNotCalled(t, "SetSidecar", mock.Anything, mock.Anything)
+}
+
+func TestSetSidecarAuditRecord(t *testing.T) {
+	caller := &auth.Identity{Username: "jane", Groups: []string{"developers"}}
+
+	kubeClient := new(kube.KubeClientMock)
+	kubeClient.On("ReviewAccess", mock.Anything, caller, "", "test-namespace", "test-deployment").Return(true, nil)
+	kubeClient.On("GetSingleDeployment", mock.Anything, &injectormodels.GetSingleDeploymentPayload{Namespace: "test-namespace", DeploymentName: "test-deployment", Consistent: true}).Return(injectormodels.Deployment{Name: "test-deployment", ContainerNames: []string{"app"}}, nil)
+	kubeClient.On("SetSidecar", mock.Anything, mock.Anything).Return(injectormodels.Deployment{Name: "test-deployment", ContainerNames: []string{"app", "prefix-sidecar-container"}}, nil)
+
+	logger := logging.New()
+	auditor := audit.New(logger, audit.NewWriterSink(io.Discard, 10))
+
+	controller := New(logger, kubeClient, auditor, newTestOperations(logger))
+
+	w, context := createPostRequestFor("/api/injector/SetSidecar", strings.NewReader(`{"Namespace": "test-namespace", "DeploymentName": "test-deployment", "SidecarContainerName": "sidecar-container", "SidecarImage": "sidecar-image"}`))
+	auth.SetIdentity(context, caller)
+
+	controller.SetSidecar(context)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Check that the audit record describes the call
+	records, err := auditor.Query(&auditmodels.Query{})
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "SetSidecar", records[0].Operation)
+	assert.Equal(t, "jane", records[0].Caller)
+	assert.Equal(t, []string{"developers"}, records[0].CallerGroups)
+	assert.Equal(t, "test-namespace", records[0].Namespace)
+	assert.Equal(t, "test-deployment", records[0].DeploymentName)
+	assert.Equal(t, []string{"app"}, records[0].ContainersBefore)
+	assert.Equal(t, []string{"app", "prefix-sidecar-container"}, records[0].ContainersAfter)
+	assert.Equal(t, auditmodels.ResultSucceeded, records[0].Result)
+	assert.Equal(t, http.StatusOK, records[0].StatusCode)
+}
+
+func TestSetSidecarAsync(t *testing.T) {
+	kubeClient := new(kube.KubeClientMock)
+	kubeClient.On("GetSingleDeployment", mock.Anything, mock.Anything).Return(injectormodels.Deployment{Name: "test-deployment", ContainerNames: []string{"app"}}, nil)
+	kubeClient.On("SetSidecar", mock.Anything, mock.Anything).Return(injectormodels.Deployment{Name: "test-deployment", ContainerNames: []string{"app", "prefix-sidecar-container"}}, nil)
+
+	logger := logging.New()
+	auditor := audit.New(logger, audit.NewWriterSink(io.Discard, 10))
+	operationsManager := newTestOperations(logger)
+
+	controller := New(logger, kubeClient, auditor, operationsManager)
+
+	w, context := createPostRequestFor("/api/injector/SetSidecar?async=true", strings.NewReader(`{"Namespace": "test-namespace", "DeploymentName": "test-deployment", "SidecarContainerName": "sidecar-container", "SidecarImage": "sidecar-image"}`))
+
+	controller.SetSidecar(context)
+
+	// Check that the call is accepted as an operation, to be polled at the Location
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	var accepted operationsmodels.Operation
+	err := json.Unmarshal(w.Body.Bytes(), &accepted)
+	assert.NoError(t, err)
+	assert.Equal(t, "SetSidecar", accepted.Operation)
+	assert.Equal(t, "/api/operations/"+accepted.ID, w.Header().Get("Location"))
+
+	operationsManager.Wait()
+
+	// Check that the operation carries the response the call would have had
+	operation, err := operationsManager.Get(accepted.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, operationsmodels.StatusSucceeded, operation.Status)
+	assert.Equal(t, http.StatusOK, operation.StatusCode)
+	assert.Equal(t, []string{"app", "prefix-sidecar-container"}, operation.Result.(injectormodels.Deployment).ContainerNames)
+
+	// Check that the audit record is written once the operation is finished
+	records, err := auditor.Query(&auditmodels.Query{})
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, http.StatusOK, records[0].StatusCode)
+		assert.Equal(t, []string{"app", "prefix-sidecar-container"}, records[0].ContainersAfter)
+	}
+}
+
+func TestSetSidecarAsyncFailed(t *testing.T) {
+	kubeClient := new(kube.KubeClientMock)
+	kubeClient.On("GetSingleDeployment", mock.Anything, mock.Anything).Return(injectormodels.Deployment{Name: "test-deployment"}, nil)
+	kubeClient.On("SetSidecar", mock.Anything, mock.Anything).Return(nil, fmt.Errorf("%w: container 'prefix-sidecar-container' already exists", kube.ErrConflict))
+
+	logger := logging.New()
+	auditor := audit.New(logger, audit.NewWriterSink(io.Discard, 10))
+	operationsManager := newTestOperations(logger)
+
+	controller := New(logger, kubeClient, auditor, operationsManager)
+
+	w, context := createPostRequestFor("/api/injector/SetSidecar?async=true", strings.NewReader(`{"Namespace": "test-namespace", "DeploymentName": "test-deployment", "SidecarContainerName": "sidecar-container", "SidecarImage": "sidecar-image"}`))
+	context.Set("requestId", "test-request-id")
+
+	controller.SetSidecar(context)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	operationsManager.Wait()
+
+	var accepted operationsmodels.Operation
+	err := json.Unmarshal(w.Body.Bytes(), &accepted)
+	assert.NoError(t, err)
+
+	// Check that the operation failed with the error response of the call
+	operation, err := operationsManager.Get(accepted.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, operationsmodels.StatusFailed, operation.Status)
+	assert.Equal(t, http.StatusConflict, operation.StatusCode)
+	assert.Equal(t, "test-request-id", operation.RequestID)
+	assert.Equal(t, "Error on setting sidecar", operation.Result.(httputil.HTTPError).Message)
+
+	records, err := auditor.Query(&auditmodels.Query{})
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, auditmodels.ResultFailed, records[0].Result)
+		assert.Equal(t, http.StatusConflict, records[0].StatusCode)
+	}
+}
+
+func TestSetSidecarOnCluster(t *testing.T) {
+	caller := &auth.Identity{Username: "jane"}
+
+	kubeClient := new(kube.KubeClientMock)
+	kubeClient.On("ReviewAccess", mock.Anything, caller, "staging", "test-namespace", "test-deployment").Return(true, nil)
+	kubeClient.On("GetSingleDeployment", mock.Anything, &injectormodels.GetSingleDeploymentPayload{Cluster: "staging", Namespace: "test-namespace", DeploymentName: "test-deployment", Consistent: true}).Return(injectormodels.Deployment{Cluster: "staging", Name: "test-deployment"}, nil)
+	kubeClient.On("SetSidecar", mock.Anything, mock.MatchedBy(func(payload *injectormodels.SetSidecarPayload) bool {
+		return payload.Cluster == "staging"
+	})).Return(injectormodels.Deployment{Cluster: "staging", Name: "test-deployment"}, nil)
+
+	logger := logging.New()
+	auditor := audit.New(logger, audit.NewWriterSink(io.Discard, 10))
+
+	controller := New(logger, kubeClient, auditor, newTestOperations(logger))
+
+	w, context := createPostRequestFor("/api/injector/SetSidecar", strings.NewReader(`{"Cluster": "staging", "Namespace": "test-namespace", "DeploymentName": "test-deployment", "SidecarContainerName": "sidecar-container", "SidecarImage": "sidecar-image"}`))
+	auth.SetIdentity(context, caller)
+
+	controller.SetSidecar(context)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	kubeClient.AssertExpectations(t)
+
+	// Check that the audit record reports the target cluster
+	records, err := auditor.Query(&auditmodels.Query{Cluster: "staging"})
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+}
+
+func TestSetSidecarAuditRecordFailure(t *testing.T) {
+	kubeClient := new(kube.KubeClientMock)
+	kubeClient.On("GetSingleDeployment", mock.Anything, &injectormodels.GetSingleDeploymentPayload{Namespace: "test-namespace", DeploymentName: "test-deployment", Consistent: true}).Return(nil, assert.AnError)
+	kubeClient.On("SetSidecar", mock.Anything, mock.Anything).Return(nil, assert.AnError)
+
+	logger := logging.New()
+	auditor := audit.New(logger, audit.NewWriterSink(io.Discard, 10))
+
+	controller := New(logger, kubeClient, auditor, newTestOperations(logger))
+
+	w, context := createPostRequestFor("/api/injector/SetSidecar", strings.NewReader(`{"Namespace": "test-namespace", "DeploymentName": "test-deployment", "SidecarContainerName": "sidecar-container", "SidecarImage": "sidecar-image"}`))
+
+	controller.SetSidecar(context)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	// Check that the failure is audited as well
+	records, err := auditor.Query(&auditmodels.Query{})
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "api-key", records[0].Caller)
+	assert.Nil(t, records[0].ContainersBefore)
+	assert.Equal(t, auditmodels.ResultFailed, records[0].Result)
+	assert.Equal(t, http.StatusInternalServerError, records[0].StatusCode)
+	assert.Equal(t, assert.AnError.Error(), records[0].Error)
+}
+
+func TestBulkSetSidecarForbiddenNamespace(t *testing.T) {
+	caller := &auth.Identity{Username: "jane"}
+
+	kubeClient := new(kube.KubeClientMock)
+	kubeClient.On("ReviewAccess", mock.Anything, caller, "", "checkout", "").Return(true, nil)
+	kubeClient.On("ReviewAccess", mock.Anything, caller, "", "payments", "").Return(false, nil)
+
+	logger := logging.New()
+	auditor := audit.New(logger, audit.NewWriterSink(io.Discard, 10))
+
+	controller := New(logger, kubeClient, auditor, newTestOperations(logger))
+
+	w, context := createPostRequestFor("/api/injector/BulkSetSidecar", strings.NewReader(`{"Namespaces": ["checkout", "payments"], "SidecarContainerName": "tracer", "SidecarImage": "tracer:1.0"}`))
+	auth.SetIdentity(context, caller)
+
+	controller.BulkSetSidecar(context)
+
+	// Check that the caller must be allowed on every namespace
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	kubeClient.AssertNotCalled(t, "BulkSetSidecar", mock.Anything, mock.Anything)
+
+	records, err := auditor.Query(&auditmodels.Query{})
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "BulkSetSidecar", records[0].Operation)
+		assert.Equal(t, "checkout,payments", records[0].Namespace)
+		assert.Equal(t, auditmodels.ResultFailed, records[0].Result)
+	}
+}
+
+func TestBulkSetSidecar(t *testing.T) {
+	kubeClient := new(kube.KubeClientMock)
+	kubeClient.On("BulkSetSidecar", mock.Anything, mock.MatchedBy(func(payload *injectormodels.BulkSetSidecarPayload) bool {
+		return payload.LabelSelector == "app=payments" && payload.Canaries == 1 && payload.Concurrency == 8
+	})).Return([]injectormodels.WorkloadResult{
+		{Namespace: "payments", DeploymentName: "payments-api", Containers: []string{"prefix-tracer"}},
+		{Namespace: "payments", DeploymentName: "payments-worker", Containers: []string{"prefix-tracer"}},
+	}, nil)
+
+	logger := logging.New()
+	auditor := audit.New(logger, audit.NewWriterSink(io.Discard, 10))
+
+	controller := New(logger, kubeClient, auditor, newTestOperations(logger))
+
+	w, context := createPostRequestFor("/api/injector/BulkSetSidecar", strings.NewReader(`{"Namespaces": ["payments"], "LabelSelector": "app=payments", "SidecarContainerName": "tracer", "SidecarImage": "tracer:1.0", "Canaries": 1, "Concurrency": 8}`))
+
+	controller.BulkSetSidecar(context)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	kubeClient.AssertExpectations(t)
+
+	records, err := auditor.Query(&auditmodels.Query{})
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, []string{"payments-api/prefix-tracer", "payments-worker/prefix-tracer"}, records[0].ContainersAfter)
+		assert.Empty(t, records[0].Error)
+		assert.Equal(t, auditmodels.ResultSucceeded, records[0].Result)
+	}
+}
+
+func TestAuditWorkloadResults(t *testing.T) {
+	succeeded := injectormodels.WorkloadResult{DeploymentName: "payments-api", Containers: []string{"prefix-tracer"}}
+	failed := injectormodels.WorkloadResult{DeploymentName: "payments-worker", Error: "boom"}
+
+	testCases := []struct {
+		name            string
+		results         []injectormodels.WorkloadResult
+		expectedResult  string
+		expectedError   string
+		expectedChanged []string
+	}{
+		{"all succeeded", []injectormodels.WorkloadResult{succeeded}, auditmodels.ResultSucceeded, "", []string{"payments-api/prefix-tracer"}},
+		{"some failed", []injectormodels.WorkloadResult{succeeded, failed}, auditmodels.ResultPartiallyFailed, "1 of 2 deployments failed", []string{"payments-api/prefix-tracer"}},
+		{"all failed", []injectormodels.WorkloadResult{failed, failed}, auditmodels.ResultFailed, "2 of 2 deployments failed", []string{}},
+		{"no deployments", []injectormodels.WorkloadResult{}, auditmodels.ResultSucceeded, "", []string{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			record := &auditmodels.Record{}
+
+			changed := auditWorkloadResults(record, tc.results)
+
+			assert.Equal(t, tc.expectedChanged, changed)
+			assert.Equal(t, tc.expectedResult, record.Result)
+			assert.Equal(t, tc.expectedError, record.Error)
+		})
+	}
+}
+
+func TestBulkSetSidecarErrorBinding(t *testing.T) {
+	kubeClient := new(kube.KubeClientMock)
+
+	logger := logging.New()
+	controller := New(logger, kubeClient, newTestAuditor(logger), newTestOperations(logger))
+
+	w, context := createPostRequestFor("/api/injector/BulkSetSidecar", strings.NewReader(`{"Namespaces": [], "SidecarContainerName": "tracer", "SidecarImage": "tracer:1.0"}`))
+
+	controller.BulkSetSidecar(context)
+
+	assertHTTPError(t, w, http.StatusBadRequest, "Error binding JSON")
+	kubeClient.AssertNotCalled(t, "BulkSetSidecar", mock.Anything, mock.Anything)
 }
 
 func TestClearSidecar(t *testing.T) {
@@ -262,11 +640,12 @@ func TestClearSidecar(t *testing.T) {
 
 	kubeClient := new(kube.KubeClientMock)
 	expectedDeployment := injectormodels.Deployment{Name: "test-deployment"}
-	kubeClient.On("ClearSidecar", mock.Anything).Return(expectedDeployment, nil)
+	kubeClient.On("GetSingleDeployment", mock.Anything, &injectormodels.GetSingleDeploymentPayload{Namespace: "test-namespace", DeploymentName: "test-deployment", Consistent: true}).Return(injectormodels.Deployment{Name: "test-deployment"}, nil)
+	kubeClient.On("ClearSidecar", mock.Anything, mock.Anything).Return(expectedDeployment, nil)
 
 	logger := logging.New()
 
-	controller := New(logger, kubeClient)
+	controller := New(logger, kubeClient, newTestAuditor(logger), newTestOperations(logger))
 
 	// Create a new gin context and request
 	// gin.Default()
@@ -289,11 +668,12 @@ func TestClearSidecarErrorBinding(t *testing.T) {
 	// to test this function. Here's a basic example:
 
 	kubeClient := new(kube.KubeClientMock)
-	kubeClient.On("ClearSidecar", mock.Anything).Return(injectormodels.Deployment{}, assert.AnError)
+	kubeClient.On("GetSingleDeployment", mock.Anything, &injectormodels.GetSingleDeploymentPayload{Namespace: "test-namespace", DeploymentName: "test-deployment", Consistent: true}).Return(injectormodels.Deployment{Name: "test-deployment"}, nil)
+	kubeClient.On("ClearSidecar", mock.Anything, mock.Anything).Return(injectormodels.Deployment{}, assert.AnError)
 
 	logger := logging.New()
 
-	controller := New(logger, kubeClient)
+	controller := New(logger, kubeClient, newTestAuditor(logger), newTestOperations(logger))
 
 	// Create a new gin context and request
 	// gin.Default()
@@ -305,7 +685,7 @@ func TestClearSidecarErrorBinding(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 
 	// Check that the HTTP response body contains the expected error message
-	assert.JSONEq(t, `{"error": "Error binding JSON"}`, w.Body.String())
+	assertHTTPError(t, w, http.StatusBadRequest, "Error binding JSON")
 }
 
 func TestClearSidecarErrorClearingSidecar(t *testing.T) {
@@ -313,11 +693,12 @@ func TestClearSidecarErrorClearingSidecar(t *testing.T) {
 	// to test this function. Here's a basic example:
 
 	kubeClient := new(kube.KubeClientMock)
-	kubeClient.On("ClearSidecar", mock.Anything).Return(nil, assert.AnError)
+	kubeClient.On("GetSingleDeployment", mock.Anything, &injectormodels.GetSingleDeploymentPayload{Namespace: "test-namespace", DeploymentName: "test-deployment", Consistent: true}).Return(injectormodels.Deployment{Name: "test-deployment"}, nil)
+	kubeClient.On("ClearSidecar", mock.Anything, mock.Anything).Return(nil, assert.AnError)
 
 	logger := logging.New()
 
-	controller := New(logger, kubeClient)
+	controller := New(logger, kubeClient, newTestAuditor(logger), newTestOperations(logger))
 
 	// Create a new gin context and request
 	// gin.Default()
@@ -325,11 +706,39 @@ func TestClearSidecarErrorClearingSidecar(t *testing.T) {
 
 	controller.ClearSidecar(context)
 
-	// Check that the HTTP response status code is 400
-	assert.Equal(t, http.StatusBadRequest, w.Code)
+	// Check that the HTTP response status code is 500
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
 
 	// Check that the HTTP response body contains the expected error message
-	assert.JSONEq(t, `{"error": "Error clearing sidecar: assert.AnError general error for testing"}`, w.Body.String())
+	assert.JSONEq(t, `{"code": 500, "message": "Error clearing sidecar", "details": "assert.AnError general error for testing"}`, w.Body.String())
+}
+
+func TestClearSidecarForbiddenCaller(t *testing.T) {
+	caller := &auth.Identity{Username: "jane"}
+
+	kubeClient := new(kube.KubeClientMock)
+	kubeClient.On("ReviewAccess", mock.Anything, caller, "", "test-namespace", "test-deployment").Return(false, nil)
+
+	logger := logging.New()
+
+	controller := New(logger, kubeClient, newTestAuditor(logger), newTestOperations(logger))
+
+	w, context := createPostRequestFor("/api/injector/ClearSidecar", strings.NewReader(`{"Namespace": "test-namespace", "DeploymentName": "test-deployment", "SidecarContainerName": "sidecar-container"}`))
+	auth.SetIdentity(context, caller)
+
+	controller.ClearSidecar(context)
+
+	// Check that the HTTP response status code is 403 and the kube client is never asked to update
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	kubeClient.AssertNotCalled(t, "ClearSidecar", mock.Anything, mock.Anything)
+}
+
+func newTestAuditor(logger *logging.Logger) *audit.Auditor {
+	return audit.New(logger, audit.NewWriterSink(io.Discard, 0))
+}
+
+func newTestOperations(logger *logging.Logger) *operations.Manager {
+	return operations.New(logger, operations.NewMemoryStore(10))
 }
 
 func createPostRequestFor(url string, bodyReader io.Reader) (*httptest.ResponseRecorder, *gin.Context) {
@@ -340,3 +749,52 @@ func createPostRequestFor(url string, bodyReader io.Reader) (*httptest.ResponseR
 	context.Request = req
 	return w, context
 }
+
+func TestSetSidecarErrorStatus(t *testing.T) {
+	testCases := []struct {
+		name           string
+		err            error
+		expectedStatus int
+	}{
+		{"invalid", fmt.Errorf("%w: TTL must be positive", kube.ErrInvalid), http.StatusUnprocessableEntity},
+		{"not found", fmt.Errorf("%w: deployments.apps \"test-deployment\" not found", kube.ErrNotFound), http.StatusNotFound},
+		{"forbidden", fmt.Errorf("%w: namespace test-namespace is denied", kube.ErrForbidden), http.StatusForbidden},
+		{"conflict", fmt.Errorf("%w: container 'prefix-sidecar-container' already exists", kube.ErrConflict), http.StatusConflict},
+		{"unavailable", fmt.Errorf("%w: connection refused", kube.ErrUnavailable), http.StatusServiceUnavailable},
+		{"unknown", assert.AnError, http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			kubeClient := new(kube.KubeClientMock)
+			kubeClient.On("GetSingleDeployment", mock.Anything, mock.Anything).Return(injectormodels.Deployment{Name: "test-deployment"}, nil)
+			kubeClient.On("SetSidecar", mock.Anything, mock.Anything).Return(nil, tc.err)
+
+			logger := logging.New()
+
+			controller := New(logger, kubeClient, newTestAuditor(logger), newTestOperations(logger))
+
+			w, context := createPostRequestFor("/api/injector/SetSidecar", strings.NewReader(`{"Namespace": "test-namespace", "DeploymentName": "test-deployment", "SidecarContainerName": "sidecar-container", "SidecarImage": "sidecar-image"}`))
+			context.Set("requestId", "test-request-id")
+
+			controller.SetSidecar(context)
+
+			// Check that the error kind is mapped to the http status and the body is structured
+			assert.Equal(t, tc.expectedStatus, w.Code)
+
+			var httpError httputil.HTTPError
+			err := json.Unmarshal(w.Body.Bytes(), &httpError)
+			assert.NoError(t, err)
+			assert.Equal(t, httputil.HTTPError{Code: tc.expectedStatus, Message: "Error on setting sidecar", Details: tc.err.Error(), RequestID: "test-request-id"}, httpError)
+		})
+	}
+}
+
+// assertHTTPError checks the status and the message of the structured error response
+func assertHTTPError(t *testing.T, w *httptest.ResponseRecorder, expectedStatus int, expectedMessage string) {
+	var httpError httputil.HTTPError
+	err := json.Unmarshal(w.Body.Bytes(), &httpError)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedStatus, httpError.Code)
+	assert.Equal(t, expectedMessage, httpError.Message)
+}
@@ -0,0 +1,320 @@
+package injector
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/httputil"
+	injectormodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/injector"
+)
+
+// ListDeployments godoc
+// @Summary      List the Deployment objects of a namespace
+// @Description  Get the deployments of the given namespace
+// @Tags         injector v2
+// @Produce      json
+// @Param        namespace   path      string  true   "Namespace"
+// @Param        cluster     query     string  false  "Target cluster, the default one when omitted"
+// @Param        consistent  query     bool    false  "Read from the API server instead of the cache"
+// @Success      200  {object}  []injectormodels.Deployment
+// @Failure     400  {object}  httputil.HTTPError
+// @Failure     403  {object}  httputil.HTTPError
+// @Failure     404  {object}  httputil.HTTPError
+// @Failure     422  {object}  httputil.HTTPError
+// @Failure     500  {object}  httputil.HTTPError
+// @Failure     503  {object}  httputil.HTTPError
+// @Router       /api/v2/namespaces/{namespace}/deployments [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (ic *InjectorController) ListDeployments(c *gin.Context) {
+
+	payload := injectormodels.GetDeploymentsPayload{
+		Cluster:    c.Query("cluster"),
+		Namespace:  c.Param("namespace"),
+		Consistent: c.Query("consistent") == "true",
+	}
+
+	ic.logger.Log().Info("ListDeployments - Received request", zap.Any("payload", payload))
+
+	ic.getDeployments(c, &payload)
+}
+
+// GetDeployment godoc
+// @Summary      Obtain a specific Deployment object
+// @Description  Get the deployment with the given namespace and name
+// @Tags         injector v2
+// @Produce      json
+// @Param        namespace   path      string  true   "Namespace"
+// @Param        name        path      string  true   "Deployment name"
+// @Param        cluster     query     string  false  "Target cluster, the default one when omitted"
+// @Param        consistent  query     bool    false  "Read from the API server instead of the cache"
+// @Success      200  {object}  injectormodels.Deployment
+// @Failure     400  {object}  httputil.HTTPError
+// @Failure     403  {object}  httputil.HTTPError
+// @Failure     404  {object}  httputil.HTTPError
+// @Failure     422  {object}  httputil.HTTPError
+// @Failure     500  {object}  httputil.HTTPError
+// @Failure     503  {object}  httputil.HTTPError
+// @Router       /api/v2/namespaces/{namespace}/deployments/{name} [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (ic *InjectorController) GetDeployment(c *gin.Context) {
+
+	payload := injectormodels.GetSingleDeploymentPayload{
+		Cluster:        c.Query("cluster"),
+		Namespace:      c.Param("namespace"),
+		DeploymentName: c.Param("name"),
+		Consistent:     c.Query("consistent") == "true",
+	}
+
+	ic.logger.Log().Info("GetDeployment - Received request", zap.Any("payload", payload))
+
+	ic.getSingleDeployment(c, &payload)
+}
+
+// PutSidecar godoc
+// @Summary      Activate the sidecar
+// @Description  Inject the named sidecar in the given deployment. The call is idempotent: when the sidecar is already injected
+// @Description  as described by the spec nothing is changed and the deployment is returned, while the status is 409 when it differs
+// @Tags         injector v2
+// @Accept       json
+// @Produce      json
+// @Param        namespace  path      string                      true   "Namespace"
+// @Param        name       path      string                      true   "Deployment name"
+// @Param        sidecar    path      string                      true   "Sidecar container name, without prefix"
+// @Param        cluster    query     string                      false  "Target cluster, the default one when omitted"
+// @Param        dryRun     query     bool                        false  "Preview the change without persisting it"
+// @Param        async      query     bool                        false  "Run the call as an operation, answering 202 with its ID"
+// @Param        spec       body      injectormodels.SidecarSpec  true   "SidecarSpec type"
+// @Success      200  {object}  injectormodels.Deployment
+// @Success      202  {object}  operationsmodels.Operation
+// @Failure     400  {object}  httputil.HTTPError
+// @Failure     403  {object}  httputil.HTTPError
+// @Failure     404  {object}  httputil.HTTPError
+// @Failure     409  {object}  httputil.HTTPError
+// @Failure     422  {object}  httputil.HTTPError
+// @Failure     500  {object}  httputil.HTTPError
+// @Failure     503  {object}  httputil.HTTPError
+// @Router       /api/v2/namespaces/{namespace}/deployments/{name}/sidecars/{sidecar} [put]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (ic *InjectorController) PutSidecar(c *gin.Context) {
+
+	var spec injectormodels.SidecarSpec
+
+	if err := c.ShouldBindJSON(&spec); err != nil {
+		ic.logger.Log().Error("Error binding JSON", zap.Error(err))
+
+		httputil.NewError(c, http.StatusBadRequest, "Error binding JSON", err)
+		return
+	}
+
+	payload := injectormodels.SetSidecarPayload{
+		Cluster:              c.Query("cluster"),
+		Namespace:            c.Param("namespace"),
+		DeploymentName:       c.Param("name"),
+		SidecarContainerName: c.Param("sidecar"),
+		SidecarImage:         spec.SidecarImage,
+		Command:              spec.Command,
+		VolumeMounts:         spec.VolumeMounts,
+		Profile:              spec.Profile,
+		TTL:                  spec.TTL,
+		Reason:               spec.Reason,
+		DryRun:               c.Query("dryRun") == "true",
+		// a retried PUT finds the sidecar it injected
+		AcceptExisting: true,
+	}
+
+	ic.logger.Log().Info("PutSidecar - Received request", zap.Any("payload", payload))
+
+	ic.setSidecar(c, &payload)
+}
+
+// DeleteSidecar godoc
+// @Summary      Remove the sidecar
+// @Description  Remove the named sidecar from the given deployment
+// @Tags         injector v2
+// @Produce      json
+// @Param        namespace  path      string  true   "Namespace"
+// @Param        name       path      string  true   "Deployment name"
+// @Param        sidecar    path      string  true   "Sidecar container name, without prefix"
+// @Param        cluster    query     string  false  "Target cluster, the default one when omitted"
+// @Param        dryRun     query     bool    false  "Preview the change without persisting it"
+// @Param        async      query     bool    false  "Run the call as an operation, answering 202 with its ID"
+// @Success      200  {object}  injectormodels.Deployment
+// @Success      202  {object}  operationsmodels.Operation
+// @Failure     400  {object}  httputil.HTTPError
+// @Failure     403  {object}  httputil.HTTPError
+// @Failure     404  {object}  httputil.HTTPError
+// @Failure     422  {object}  httputil.HTTPError
+// @Failure     500  {object}  httputil.HTTPError
+// @Failure     503  {object}  httputil.HTTPError
+// @Router       /api/v2/namespaces/{namespace}/deployments/{name}/sidecars/{sidecar} [delete]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (ic *InjectorController) DeleteSidecar(c *gin.Context) {
+
+	payload := injectormodels.ClearSidecarPayload{
+		Cluster:              c.Query("cluster"),
+		Namespace:            c.Param("namespace"),
+		DeploymentName:       c.Param("name"),
+		SidecarContainerName: c.Param("sidecar"),
+		DryRun:               c.Query("dryRun") == "true",
+	}
+
+	ic.logger.Log().Info("DeleteSidecar - Received request", zap.Any("payload", payload))
+
+	ic.clearSidecar(c, &payload)
+}
+
+// ListHistory godoc
+// @Summary      Obtain the history of a Deployment
+// @Description  Get the snapshots of the pod template taken before every change of the injector, from the oldest
+// @Tags         injector v2
+// @Produce      json
+// @Param        namespace   path      string  true   "Namespace"
+// @Param        name        path      string  true   "Deployment name"
+// @Param        cluster     query     string  false  "Target cluster, the default one when omitted"
+// @Param        consistent  query     bool    false  "Read from the API server instead of the cache"
+// @Success      200  {object}  []injectormodels.HistoryEntry
+// @Failure     400  {object}  httputil.HTTPError
+// @Failure     403  {object}  httputil.HTTPError
+// @Failure     404  {object}  httputil.HTTPError
+// @Failure     422  {object}  httputil.HTTPError
+// @Failure     500  {object}  httputil.HTTPError
+// @Failure     503  {object}  httputil.HTTPError
+// @Router       /api/v2/namespaces/{namespace}/deployments/{name}/history [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (ic *InjectorController) ListHistory(c *gin.Context) {
+
+	payload := injectormodels.GetHistoryPayload{
+		Cluster:        c.Query("cluster"),
+		Namespace:      c.Param("namespace"),
+		DeploymentName: c.Param("name"),
+		Consistent:     c.Query("consistent") == "true",
+	}
+
+	ic.logger.Log().Info("ListHistory - Received request", zap.Any("payload", payload))
+
+	ic.getHistory(c, &payload)
+}
+
+// PostRestore godoc
+// @Summary      Restore a previous pod template
+// @Description  Restore the pod template of the given deployment to the given revision of its history
+// @Tags         injector v2
+// @Produce      json
+// @Param        namespace  path      string  true   "Namespace"
+// @Param        name       path      string  true   "Deployment name"
+// @Param        revision   path      int     true   "Revision of the history"
+// @Param        cluster    query     string  false  "Target cluster, the default one when omitted"
+// @Param        dryRun     query     bool    false  "Preview the change without persisting it"
+// @Param        async      query     bool    false  "Run the call as an operation, answering 202 with its ID"
+// @Success      200  {object}  injectormodels.Deployment
+// @Success      202  {object}  operationsmodels.Operation
+// @Failure     400  {object}  httputil.HTTPError
+// @Failure     403  {object}  httputil.HTTPError
+// @Failure     404  {object}  httputil.HTTPError
+// @Failure     409  {object}  httputil.HTTPError
+// @Failure     422  {object}  httputil.HTTPError
+// @Failure     500  {object}  httputil.HTTPError
+// @Failure     503  {object}  httputil.HTTPError
+// @Router       /api/v2/namespaces/{namespace}/deployments/{name}/history/{revision}/restore [post]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (ic *InjectorController) PostRestore(c *gin.Context) {
+
+	revision, err := strconv.Atoi(c.Param("revision"))
+	if err != nil {
+		ic.logger.Log().Error("Error parsing revision", zap.Error(err))
+
+		httputil.NewError(c, http.StatusBadRequest, "Error parsing revision", err)
+		return
+	}
+
+	payload := injectormodels.RestoreSnapshotPayload{
+		Cluster:        c.Query("cluster"),
+		Namespace:      c.Param("namespace"),
+		DeploymentName: c.Param("name"),
+		Revision:       revision,
+		DryRun:         c.Query("dryRun") == "true",
+	}
+
+	ic.logger.Log().Info("PostRestore - Received request", zap.Any("payload", payload))
+
+	ic.restoreSnapshot(c, &payload)
+}
+
+// DeleteAllSidecars godoc
+// @Summary      Remove all the sidecars
+// @Description  Remove every sidecar from the given deployment
+// @Tags         injector v2
+// @Produce      json
+// @Param        namespace  path      string  true   "Namespace"
+// @Param        name       path      string  true   "Deployment name"
+// @Param        cluster    query     string  false  "Target cluster, the default one when omitted"
+// @Param        dryRun     query     bool    false  "Preview the change without persisting it"
+// @Param        async      query     bool    false  "Run the call as an operation, answering 202 with its ID"
+// @Success      200  {object}  []injectormodels.WorkloadResult
+// @Success      202  {object}  operationsmodels.Operation
+// @Failure     400  {object}  httputil.HTTPError
+// @Failure     403  {object}  httputil.HTTPError
+// @Failure     404  {object}  httputil.HTTPError
+// @Failure     422  {object}  httputil.HTTPError
+// @Failure     500  {object}  httputil.HTTPError
+// @Failure     503  {object}  httputil.HTTPError
+// @Router       /api/v2/namespaces/{namespace}/deployments/{name}/sidecars [delete]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (ic *InjectorController) DeleteAllSidecars(c *gin.Context) {
+
+	payload := injectormodels.ClearAllSidecarsPayload{
+		Cluster:        c.Query("cluster"),
+		Namespace:      c.Param("namespace"),
+		DeploymentName: c.Param("name"),
+		DryRun:         c.Query("dryRun") == "true",
+	}
+
+	ic.logger.Log().Info("DeleteAllSidecars - Received request", zap.Any("payload", payload))
+
+	ic.clearAllSidecars(c, &payload)
+}
+
+// DeleteNamespaceSidecars godoc
+// @Summary      Remove all the sidecars of a namespace
+// @Description  Remove every sidecar from all the deployments of the given namespace matching the label selector
+// @Tags         injector v2
+// @Produce      json
+// @Param        namespace      path      string  true   "Namespace"
+// @Param        labelSelector  query     string  false  "Label selector of the deployments, all of them when omitted"
+// @Param        cluster        query     string  false  "Target cluster, the default one when omitted"
+// @Param        dryRun         query     bool    false  "Preview the change without persisting it"
+// @Param        async          query     bool    false  "Run the call as an operation, answering 202 with its ID"
+// @Success      200  {object}  []injectormodels.WorkloadResult
+// @Success      202  {object}  operationsmodels.Operation
+// @Failure     400  {object}  httputil.HTTPError
+// @Failure     403  {object}  httputil.HTTPError
+// @Failure     404  {object}  httputil.HTTPError
+// @Failure     422  {object}  httputil.HTTPError
+// @Failure     500  {object}  httputil.HTTPError
+// @Failure     503  {object}  httputil.HTTPError
+// @Router       /api/v2/namespaces/{namespace}/sidecars [delete]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (ic *InjectorController) DeleteNamespaceSidecars(c *gin.Context) {
+
+	payload := injectormodels.ClearAllSidecarsPayload{
+		Cluster:       c.Query("cluster"),
+		Namespace:     c.Param("namespace"),
+		LabelSelector: c.Query("labelSelector"),
+		DryRun:        c.Query("dryRun") == "true",
+	}
+
+	ic.logger.Log().Info("DeleteNamespaceSidecars - Received request", zap.Any("payload", payload))
+
+	ic.clearAllSidecars(c, &payload)
+}
@@ -0,0 +1,254 @@
+package injector
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/audit"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/kube"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+	auditmodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/audit"
+	injectormodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/injector"
+)
+
+func TestListDeployments(t *testing.T) {
+	kubeClient := new(kube.KubeClientMock)
+	expectedDeployments := []injectormodels.Deployment{{Cluster: "staging", Namespace: "test-namespace", Name: "test-deployment"}}
+	kubeClient.On("GetDeployments", mock.Anything, &injectormodels.GetDeploymentsPayload{Cluster: "staging", Namespace: "test-namespace"}).Return(expectedDeployments, nil)
+
+	logger := logging.New()
+	controller := New(logger, kubeClient, newTestAuditor(logger), newTestOperations(logger))
+
+	w := serveV2Request(http.MethodGet, "/api/v2/namespaces/:namespace/deployments", controller.ListDeployments, "/api/v2/namespaces/test-namespace/deployments?cluster=staging", nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var deployments []injectormodels.Deployment
+	err := json.Unmarshal(w.Body.Bytes(), &deployments)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedDeployments, deployments)
+}
+
+func TestGetDeployment(t *testing.T) {
+	kubeClient := new(kube.KubeClientMock)
+	expectedDeployment := injectormodels.Deployment{Namespace: "test-namespace", Name: "test-deployment"}
+	kubeClient.On("GetSingleDeployment", mock.Anything, &injectormodels.GetSingleDeploymentPayload{Namespace: "test-namespace", DeploymentName: "test-deployment"}).Return(expectedDeployment, nil)
+
+	logger := logging.New()
+	controller := New(logger, kubeClient, newTestAuditor(logger), newTestOperations(logger))
+
+	w := serveV2Request(http.MethodGet, "/api/v2/namespaces/:namespace/deployments/:name", controller.GetDeployment, "/api/v2/namespaces/test-namespace/deployments/test-deployment", nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var deployment injectormodels.Deployment
+	err := json.Unmarshal(w.Body.Bytes(), &deployment)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedDeployment, deployment)
+}
+
+func TestPutSidecar(t *testing.T) {
+	expectedPayload := &injectormodels.SetSidecarPayload{
+		Namespace:            "test-namespace",
+		DeploymentName:       "test-deployment",
+		SidecarContainerName: "debug",
+		SidecarImage:         "busybox",
+		Command:              []string{"sleep", "infinity"},
+		TTL:                  "2h",
+		AcceptExisting:       true,
+	}
+
+	kubeClient := new(kube.KubeClientMock)
+	kubeClient.On("GetSingleDeployment", mock.Anything, &injectormodels.GetSingleDeploymentPayload{Namespace: "test-namespace", DeploymentName: "test-deployment", Consistent: true}).Return(injectormodels.Deployment{Name: "test-deployment"}, nil)
+	kubeClient.On("SetSidecar", mock.Anything, expectedPayload).Return(injectormodels.Deployment{Name: "test-deployment", ContainerNames: []string{"app", "prefix-debug"}}, nil)
+
+	logger := logging.New()
+	auditor := audit.New(logger, audit.NewWriterSink(io.Discard, 10))
+	controller := New(logger, kubeClient, auditor, newTestOperations(logger))
+
+	w := serveV2Request(http.MethodPut, "/api/v2/namespaces/:namespace/deployments/:name/sidecars/:sidecar", controller.PutSidecar, "/api/v2/namespaces/test-namespace/deployments/test-deployment/sidecars/debug", strings.NewReader(`{"SidecarImage": "busybox", "Command": ["sleep", "infinity"], "TTL": "2h"}`))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	kubeClient.AssertExpectations(t)
+
+	// Check that the call is audited as the v1 one
+	records, err := auditor.Query(&auditmodels.Query{})
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "SetSidecar", records[0].Operation)
+}
+
+func TestPutSidecarErrorBinding(t *testing.T) {
+	kubeClient := new(kube.KubeClientMock)
+
+	logger := logging.New()
+	controller := New(logger, kubeClient, newTestAuditor(logger), newTestOperations(logger))
+
+	w := serveV2Request(http.MethodPut, "/api/v2/namespaces/:namespace/deployments/:name/sidecars/:sidecar", controller.PutSidecar, "/api/v2/namespaces/test-namespace/deployments/test-deployment/sidecars/debug", strings.NewReader(`{"Command": ["sleep"]}`))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assertHTTPError(t, w, http.StatusBadRequest, "Error binding JSON")
+	kubeClient.AssertNotCalled(t, "SetSidecar", mock.Anything, mock.Anything)
+}
+
+func TestPutSidecarDryRun(t *testing.T) {
+	expectedPayload := &injectormodels.SetSidecarPayload{
+		Namespace:            "test-namespace",
+		DeploymentName:       "test-deployment",
+		SidecarContainerName: "debug",
+		SidecarImage:         "busybox",
+		DryRun:               true,
+		AcceptExisting:       true,
+	}
+	preview := &injectormodels.DryRunPreview{Diff: "--- current\n+++ dry-run\n"}
+
+	kubeClient := new(kube.KubeClientMock)
+	kubeClient.On("GetSingleDeployment", mock.Anything, mock.Anything).Return(injectormodels.Deployment{Name: "test-deployment"}, nil)
+	kubeClient.On("SetSidecar", mock.Anything, expectedPayload).Return(injectormodels.Deployment{Name: "test-deployment", DryRun: preview}, nil)
+
+	logger := logging.New()
+	controller := New(logger, kubeClient, newTestAuditor(logger), newTestOperations(logger))
+
+	w := serveV2Request(http.MethodPut, "/api/v2/namespaces/:namespace/deployments/:name/sidecars/:sidecar", controller.PutSidecar, "/api/v2/namespaces/test-namespace/deployments/test-deployment/sidecars/debug?dryRun=true", strings.NewReader(`{"SidecarImage": "busybox"}`))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"Diff":"--- current\n+++ dry-run\n"`)
+	kubeClient.AssertExpectations(t)
+}
+
+func TestDeleteSidecar(t *testing.T) {
+	expectedPayload := &injectormodels.ClearSidecarPayload{
+		Cluster:              "staging",
+		Namespace:            "test-namespace",
+		DeploymentName:       "test-deployment",
+		SidecarContainerName: "debug",
+	}
+
+	kubeClient := new(kube.KubeClientMock)
+	kubeClient.On("GetSingleDeployment", mock.Anything, mock.Anything).Return(injectormodels.Deployment{Name: "test-deployment"}, nil)
+	kubeClient.On("ClearSidecar", mock.Anything, expectedPayload).Return(injectormodels.Deployment{Name: "test-deployment"}, nil)
+
+	logger := logging.New()
+	controller := New(logger, kubeClient, newTestAuditor(logger), newTestOperations(logger))
+
+	w := serveV2Request(http.MethodDelete, "/api/v2/namespaces/:namespace/deployments/:name/sidecars/:sidecar", controller.DeleteSidecar, "/api/v2/namespaces/test-namespace/deployments/test-deployment/sidecars/debug?cluster=staging", nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	kubeClient.AssertExpectations(t)
+}
+
+func TestDeleteNamespaceSidecars(t *testing.T) {
+	expectedPayload := &injectormodels.ClearAllSidecarsPayload{
+		Namespace:     "test-namespace",
+		LabelSelector: "app=payments",
+	}
+
+	kubeClient := new(kube.KubeClientMock)
+	kubeClient.On("ClearAllSidecars", mock.Anything, expectedPayload).Return([]injectormodels.WorkloadResult{
+		{Namespace: "test-namespace", DeploymentName: "payments-api", Containers: []string{"prefix-debug"}, Deployment: &injectormodels.Deployment{Name: "payments-api"}},
+		{Namespace: "test-namespace", DeploymentName: "payments-worker", Containers: []string{"prefix-debug"}, Error: "boom"},
+	}, nil)
+
+	logger := logging.New()
+	auditor := audit.New(logger, audit.NewWriterSink(io.Discard, 10))
+	controller := New(logger, kubeClient, auditor, newTestOperations(logger))
+
+	w := serveV2Request(http.MethodDelete, "/api/v2/namespaces/:namespace/sidecars", controller.DeleteNamespaceSidecars, "/api/v2/namespaces/test-namespace/sidecars?labelSelector=app%3Dpayments", nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"Error":"boom"`)
+	kubeClient.AssertExpectations(t)
+
+	// Check that the partial failure is audited along with the removed containers
+	records, err := auditor.Query(&auditmodels.Query{})
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "ClearAllSidecars", records[0].Operation)
+		assert.Equal(t, []string{"payments-api/prefix-debug"}, records[0].ContainersBefore)
+		assert.Equal(t, "1 of 2 deployments failed", records[0].Error)
+		assert.Equal(t, auditmodels.ResultPartiallyFailed, records[0].Result)
+		assert.Equal(t, http.StatusOK, records[0].StatusCode)
+	}
+}
+
+func TestListHistory(t *testing.T) {
+	expectedPayload := &injectormodels.GetHistoryPayload{
+		Namespace:      "test-namespace",
+		DeploymentName: "test-deployment",
+	}
+
+	kubeClient := new(kube.KubeClientMock)
+	kubeClient.On("GetHistory", mock.Anything, expectedPayload).Return([]injectormodels.HistoryEntry{{Revision: 1, Operation: "SetSidecar"}}, nil)
+
+	logger := logging.New()
+	controller := New(logger, kubeClient, newTestAuditor(logger), newTestOperations(logger))
+
+	w := serveV2Request(http.MethodGet, "/api/v2/namespaces/:namespace/deployments/:name/history", controller.ListHistory, "/api/v2/namespaces/test-namespace/deployments/test-deployment/history", nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"Revision":1`)
+	kubeClient.AssertExpectations(t)
+}
+
+func TestPostRestore(t *testing.T) {
+	expectedPayload := &injectormodels.RestoreSnapshotPayload{
+		Cluster:        "staging",
+		Namespace:      "test-namespace",
+		DeploymentName: "test-deployment",
+		Revision:       3,
+	}
+
+	kubeClient := new(kube.KubeClientMock)
+	kubeClient.On("GetSingleDeployment", mock.Anything, mock.Anything).Return(injectormodels.Deployment{Name: "test-deployment", ContainerNames: []string{"app", "prefix-debug"}}, nil)
+	kubeClient.On("RestoreSnapshot", mock.Anything, expectedPayload).Return(injectormodels.Deployment{Name: "test-deployment", ContainerNames: []string{"app"}}, nil)
+
+	logger := logging.New()
+	auditor := audit.New(logger, audit.NewWriterSink(io.Discard, 10))
+	controller := New(logger, kubeClient, auditor, newTestOperations(logger))
+
+	w := serveV2Request(http.MethodPost, "/api/v2/namespaces/:namespace/deployments/:name/history/:revision/restore", controller.PostRestore, "/api/v2/namespaces/test-namespace/deployments/test-deployment/history/3/restore?cluster=staging", nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	kubeClient.AssertExpectations(t)
+
+	// Check that the restore is audited along with the containers it changed
+	records, err := auditor.Query(&auditmodels.Query{})
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "RestoreSnapshot", records[0].Operation)
+		assert.Equal(t, []string{"app", "prefix-debug"}, records[0].ContainersBefore)
+		assert.Equal(t, []string{"app"}, records[0].ContainersAfter)
+	}
+}
+
+func TestPostRestoreErrorRevision(t *testing.T) {
+	kubeClient := new(kube.KubeClientMock)
+
+	logger := logging.New()
+	controller := New(logger, kubeClient, newTestAuditor(logger), newTestOperations(logger))
+
+	w := serveV2Request(http.MethodPost, "/api/v2/namespaces/:namespace/deployments/:name/history/:revision/restore", controller.PostRestore, "/api/v2/namespaces/test-namespace/deployments/test-deployment/history/latest/restore", nil)
+
+	assertHTTPError(t, w, http.StatusBadRequest, "Error parsing revision")
+	kubeClient.AssertNotCalled(t, "RestoreSnapshot", mock.Anything, mock.Anything)
+}
+
+// serveV2Request serves the request by means of a router holding the route, so that the path parameters are parsed
+func serveV2Request(method string, route string, handler gin.HandlerFunc, url string, bodyReader io.Reader) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.Handle(method, route, handler)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, url, bodyReader)
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
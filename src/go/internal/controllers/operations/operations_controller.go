@@ -0,0 +1,63 @@
+package operations
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/auth"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/httputil"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+	operationsmodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/operations"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/operations"
+)
+
+type OperationsController struct {
+	logger     *logging.Logger
+	operations *operations.Manager
+}
+
+// NewOperationsController creates a new instance of the OperationsController
+func New(logger *logging.Logger, operationsManager *operations.Manager) *OperationsController {
+	return &OperationsController{
+		logger:     logger,
+		operations: operationsManager,
+	}
+}
+
+// GetOperation godoc
+// @Summary      Obtain an asynchronous operation
+// @Description  Get the status, the progress, the per-step logs and, once finished, the result of an operation started by a mutating call with async=true.
+// @Description  The result is the response body the call would have had, and statusCode its http status. Operations are visible only to the caller that started them
+// @Tags         operations
+// @Produce      json
+// @Param        id   path      string  true  "Operation ID"
+// @Success      200  {object}  operationsmodels.Operation
+// @Failure     404  {object}  httputil.HTTPError
+// @Failure     500  {object}  httputil.HTTPError
+// @Router       /api/operations/{id} [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (oc *OperationsController) GetOperation(c *gin.Context) {
+
+	var operation *operationsmodels.Operation
+
+	operation, err := oc.operations.Get(c.Param("id"))
+
+	// an operation of another caller is reported as missing, so as not to disclose its existence
+	if errors.Is(err, operations.ErrOperationNotFound) || (err == nil && operation.Caller != auth.CallerName(auth.IdentityFrom(c))) {
+		httputil.NewError(c, http.StatusNotFound, "Operation not found", nil)
+		return
+	}
+
+	if err != nil {
+		oc.logger.Log().Error("Error getting operation", zap.Error(err))
+
+		httputil.NewError(c, http.StatusInternalServerError, "Error getting operation", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, operation)
+}
@@ -0,0 +1,100 @@
+package operations
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/auth"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+	operationsmodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/operations"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/operations"
+)
+
+func TestNew(t *testing.T) {
+	logger := logging.New()
+	operationsManager := operations.New(logger, operations.NewMemoryStore(10))
+
+	controller := New(logger, operationsManager)
+
+	assert.Equal(t, logger, controller.logger)
+	assert.Equal(t, operationsManager, controller.operations)
+}
+
+func TestGetOperation(t *testing.T) {
+	logger := logging.New()
+	operationsManager := operations.New(logger, operations.NewMemoryStore(10))
+
+	started := operationsManager.Start("BulkSetSidecar", auth.APIKeyCaller, "", func(ctx context.Context) (any, int, error) {
+		operations.TrackerFrom(ctx).SetTotal(1)
+		operations.TrackerFrom(ctx).Step("payments/payments-api: injected prefix-tracer")
+		return []string{"done"}, http.StatusOK, nil
+	})
+	operationsManager.Wait()
+
+	controller := New(logger, operationsManager)
+
+	w, context := createGetRequestFor("/api/operations/"+started.ID, started.ID)
+
+	controller.GetOperation(context)
+
+	// Check that the finished operation is returned with its progress, logs and result
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var operation operationsmodels.Operation
+	err := json.Unmarshal(w.Body.Bytes(), &operation)
+	assert.NoError(t, err)
+	assert.Equal(t, operationsmodels.StatusSucceeded, operation.Status)
+	assert.Equal(t, operationsmodels.Progress{Done: 1, Total: 1}, operation.Progress)
+	if assert.Len(t, operation.Logs, 1) {
+		assert.Equal(t, "payments/payments-api: injected prefix-tracer", operation.Logs[0].Message)
+	}
+	assert.Equal(t, []any{"done"}, operation.Result)
+}
+
+func TestGetOperationNotFound(t *testing.T) {
+	logger := logging.New()
+	operationsManager := operations.New(logger, operations.NewMemoryStore(10))
+
+	started := operationsManager.Start("SetSidecar", "jane", "", func(ctx context.Context) (any, int, error) {
+		return nil, http.StatusOK, nil
+	})
+	operationsManager.Wait()
+
+	controller := New(logger, operationsManager)
+
+	testCases := []struct {
+		name   string
+		id     string
+		caller *auth.Identity
+	}{
+		{"unknown", "0123456789abcdef", &auth.Identity{Username: "jane"}},
+		{"other caller", started.ID, &auth.Identity{Username: "john"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w, context := createGetRequestFor("/api/operations/"+tc.id, tc.id)
+			auth.SetIdentity(context, tc.caller)
+
+			controller.GetOperation(context)
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+			assert.JSONEq(t, `{"code": 404, "message": "Operation not found"}`, w.Body.String())
+		})
+	}
+}
+
+func createGetRequestFor(url string, id string) (*httptest.ResponseRecorder, *gin.Context) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", url, nil)
+	context, _ := gin.CreateTestContext(w)
+	context.Request = req
+	context.Params = gin.Params{{Key: "id", Value: id}}
+	return w, context
+}
@@ -0,0 +1,51 @@
+package webhooks
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/httputil"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+	webhooksmodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/webhooks"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/webhooks"
+)
+
+type WebhooksController struct {
+	logger   *logging.Logger
+	notifier *webhooks.Notifier
+}
+
+// NewWebhooksController creates a new instance of the WebhooksController
+func New(logger *logging.Logger, notifier *webhooks.Notifier) *WebhooksController {
+	return &WebhooksController{
+		logger:   logger,
+		notifier: notifier,
+	}
+}
+
+// GetDeliveries godoc
+// @Summary      Query the webhook delivery log
+// @Description  Get the most recent deliveries of the events to the webhooks, oldest first, with their status, attempts and last error
+// @Tags         webhooks
+// @Produce      json
+// @Param        limit  query     int  false  "Maximum number of most recent deliveries to return"
+// @Success      200  {object}  []webhooksmodels.Delivery
+// @Failure     400  {object}  httputil.HTTPError
+// @Router       /api/webhooks/deliveries [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (wc *WebhooksController) GetDeliveries(c *gin.Context) {
+
+	var query webhooksmodels.DeliveryQuery
+
+	if err := c.ShouldBindQuery(&query); err != nil {
+		wc.logger.Log().Error("Error binding query", zap.Error(err))
+
+		httputil.NewError(c, http.StatusBadRequest, "Error binding query", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, wc.notifier.Deliveries(query.Limit))
+}
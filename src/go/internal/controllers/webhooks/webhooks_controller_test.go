@@ -0,0 +1,78 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+	webhooksmodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/webhooks"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/webhooks"
+)
+
+func newTestNotifier(t *testing.T, url string) *webhooks.Notifier {
+
+	notifier, err := webhooks.New(logging.New(), webhooks.Options{Webhooks: []webhooks.Webhook{{Name: "tickets", URL: url}}})
+	assert.NoError(t, err)
+
+	return notifier
+}
+
+func TestNew(t *testing.T) {
+	logger := logging.New()
+	notifier := newTestNotifier(t, "http://localhost")
+
+	controller := New(logger, notifier)
+
+	assert.Equal(t, logger, controller.logger)
+	assert.Equal(t, notifier, controller.notifier)
+}
+
+func TestGetDeliveries(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	notifier := newTestNotifier(t, server.URL)
+	notifier.Notify(&webhooksmodels.Event{Type: "SidecarInjected", Namespace: "payments", DeploymentName: "payments-api"})
+	notifier.Notify(&webhooksmodels.Event{Type: "SidecarRemoved", Namespace: "payments", DeploymentName: "payments-api"})
+	notifier.Wait()
+
+	controller := New(logging.New(), notifier)
+
+	w, context := createGetRequestFor("/api/webhooks/deliveries?limit=1")
+
+	controller.GetDeliveries(context)
+
+	// Check that the most recent delivery is returned
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var deliveries []webhooksmodels.Delivery
+	err := json.Unmarshal(w.Body.Bytes(), &deliveries)
+	assert.NoError(t, err)
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, "SidecarRemoved", deliveries[0].EventType)
+		assert.Equal(t, webhooksmodels.DeliveryDelivered, deliveries[0].Status)
+	}
+}
+
+func TestGetDeliveriesErrorBinding(t *testing.T) {
+	controller := New(logging.New(), newTestNotifier(t, "http://localhost"))
+
+	w, context := createGetRequestFor("/api/webhooks/deliveries?limit=many")
+
+	controller.GetDeliveries(context)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func createGetRequestFor(url string) (*httptest.ResponseRecorder, *gin.Context) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", url, nil)
+	context, _ := gin.CreateTestContext(w)
+	context.Request = req
+	return w, context
+}
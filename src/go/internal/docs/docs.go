@@ -19,11 +19,312 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/api/audit": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the audit records of the injection and removal operations, oldest first, optionally filtered by time range, namespace and deployment",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "audit"
+                ],
+                "summary": "Query the audit log",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "RFC3339 lower bound of the record time",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC3339 upper bound of the record time",
+                        "name": "until",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cluster of the target deployment",
+                        "name": "cluster",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Namespace of the target deployment",
+                        "name": "namespace",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Name of the target deployment",
+                        "name": "deploymentName",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of most recent records to return",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/auditmodels.Record"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/clusters": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the clusters the injector can act on, along with the reachability and the version of their API server",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "clusters"
+                ],
+                "summary": "List the clusters",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/clustermodels.Cluster"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/injector/BulkSetSidecar": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Set the sidecar for all the deployments of the given namespaces matching a label selector, reporting the outcome of each",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "injector"
+                ],
+                "summary": "Activate the sidecar on many deployments",
+                "parameters": [
+                    {
+                        "description": "BulkSetSidecarPayload type",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/injectormodels.BulkSetSidecarPayload"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Run the call as an operation, answering 202 with its ID",
+                        "name": "async",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/injectormodels.WorkloadResult"
+                            }
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/operationsmodels.Operation"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/injector/ClearAllSidecars": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Remove every sidecar from a given deployment, or from all the deployments of a namespace matching a label selector",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "injector"
+                ],
+                "summary": "Remove all the sidecars",
+                "parameters": [
+                    {
+                        "description": "ClearAllSidecarsPayload type",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/injectormodels.ClearAllSidecarsPayload"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Run the call as an operation, answering 202 with its ID",
+                        "name": "async",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/injectormodels.WorkloadResult"
+                            }
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/operationsmodels.Operation"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
         "/api/injector/ClearSidecar": {
             "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
                     }
                 ],
                 "description": "Remove the sidecar from a given deployment",
@@ -46,6 +347,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/injectormodels.ClearSidecarPayload"
                         }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Run the call as an operation, answering 202 with its ID",
+                        "name": "async",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -54,6 +361,48 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/injectormodels.Deployment"
                         }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/operationsmodels.Operation"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
                     }
                 }
             }
@@ -63,6 +412,9 @@ const docTemplate = `{
                 "security": [
                     {
                         "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
                     }
                 ],
                 "description": "Get deployments for a given namespace or all namespaces",
@@ -96,18 +448,57 @@ const docTemplate = `{
                                 "$ref": "#/definitions/injectormodels.Deployment"
                             }
                         }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
                     }
                 }
             }
         },
-        "/api/injector/GetSingleDeployment": {
+        "/api/injector/GetHistory": {
             "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
                     }
                 ],
-                "description": "Get deployments for a given namespace and name",
+                "description": "Get the snapshots of the pod template taken before every change of the injector, from the oldest",
                 "consumes": [
                     "application/json"
                 ],
@@ -117,15 +508,15 @@ const docTemplate = `{
                 "tags": [
                     "injector"
                 ],
-                "summary": "Obtain a specific Deployment objects",
+                "summary": "Obtain the history of a Deployment",
                 "parameters": [
                     {
-                        "description": "GetSingleDeploymentPayload type",
+                        "description": "GetHistoryPayload type",
                         "name": "payload",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/injectormodels.GetSingleDeploymentPayload"
+                            "$ref": "#/definitions/injectormodels.GetHistoryPayload"
                         }
                     }
                 ],
@@ -133,20 +524,62 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/injectormodels.Deployment"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/injectormodels.HistoryEntry"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
                         }
                     }
                 }
             }
         },
-        "/api/injector/SetSidecar": {
+        "/api/injector/GetSingleDeployment": {
             "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
                     }
                 ],
-                "description": "Set the sidecar for a given deployment",
+                "description": "Get deployments for a given namespace and name",
                 "consumes": [
                     "application/json"
                 ],
@@ -156,15 +589,15 @@ const docTemplate = `{
                 "tags": [
                     "injector"
                 ],
-                "summary": "Activate the sidecar",
+                "summary": "Obtain a specific Deployment objects",
                 "parameters": [
                     {
-                        "description": "SetSidecarPayload type",
+                        "description": "GetSingleDeploymentPayload type",
                         "name": "payload",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/injectormodels.SetSidecarPayload"
+                            "$ref": "#/definitions/injectormodels.GetSingleDeploymentPayload"
                         }
                     }
                 ],
@@ -174,34 +607,1541 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/injectormodels.Deployment"
                         }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "injectormodels.ClearSidecarPayload": {
-            "type": "object",
-            "required": [
-                "DeploymentName",
-                "Namespace",
-                "SidecarContainerName"
-            ],
-            "properties": {
-                "DeploymentName": {
-                    "type": "string"
-                },
-                "Namespace": {
-                    "type": "string"
-                },
-                "SidecarContainerName": {
-                    "type": "string"
+        },
+        "/api/injector/RestoreSnapshot": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Restore the pod template of a given deployment to a revision of its history",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "injector"
+                ],
+                "summary": "Restore a previous pod template",
+                "parameters": [
+                    {
+                        "description": "RestoreSnapshotPayload type",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/injectormodels.RestoreSnapshotPayload"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Run the call as an operation, answering 202 with its ID",
+                        "name": "async",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/injectormodels.Deployment"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/operationsmodels.Operation"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/injector/SetSidecar": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Set the sidecar for a given deployment",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "injector"
+                ],
+                "summary": "Activate the sidecar",
+                "parameters": [
+                    {
+                        "description": "SetSidecarPayload type",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/injectormodels.SetSidecarPayload"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Run the call as an operation, answering 202 with its ID",
+                        "name": "async",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/injectormodels.Deployment"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/operationsmodels.Operation"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/operations/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the status, the progress, the per-step logs and, once finished, the result of an operation started by a mutating call with async=true.\nThe result is the response body the call would have had, and statusCode its http status. Operations are visible only to the caller that started them",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "operations"
+                ],
+                "summary": "Obtain an asynchronous operation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Operation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/operationsmodels.Operation"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/namespaces/{namespace}/deployments": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the deployments of the given namespace",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "injector v2"
+                ],
+                "summary": "List the Deployment objects of a namespace",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Namespace",
+                        "name": "namespace",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Target cluster, the default one when omitted",
+                        "name": "cluster",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Read from the API server instead of the cache",
+                        "name": "consistent",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/injectormodels.Deployment"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/namespaces/{namespace}/deployments/{name}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the deployment with the given namespace and name",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "injector v2"
+                ],
+                "summary": "Obtain a specific Deployment object",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Namespace",
+                        "name": "namespace",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Deployment name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Target cluster, the default one when omitted",
+                        "name": "cluster",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Read from the API server instead of the cache",
+                        "name": "consistent",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/injectormodels.Deployment"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/namespaces/{namespace}/deployments/{name}/history": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the snapshots of the pod template taken before every change of the injector, from the oldest",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "injector v2"
+                ],
+                "summary": "Obtain the history of a Deployment",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Namespace",
+                        "name": "namespace",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Deployment name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Target cluster, the default one when omitted",
+                        "name": "cluster",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Read from the API server instead of the cache",
+                        "name": "consistent",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/injectormodels.HistoryEntry"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/namespaces/{namespace}/deployments/{name}/history/{revision}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Restore the pod template of the given deployment to the given revision of its history",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "injector v2"
+                ],
+                "summary": "Restore a previous pod template",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Namespace",
+                        "name": "namespace",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Deployment name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Revision of the history",
+                        "name": "revision",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Target cluster, the default one when omitted",
+                        "name": "cluster",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Preview the change without persisting it",
+                        "name": "dryRun",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Run the call as an operation, answering 202 with its ID",
+                        "name": "async",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/injectormodels.Deployment"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/operationsmodels.Operation"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/namespaces/{namespace}/deployments/{name}/sidecars": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Remove every sidecar from the given deployment",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "injector v2"
+                ],
+                "summary": "Remove all the sidecars",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Namespace",
+                        "name": "namespace",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Deployment name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Target cluster, the default one when omitted",
+                        "name": "cluster",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Preview the change without persisting it",
+                        "name": "dryRun",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Run the call as an operation, answering 202 with its ID",
+                        "name": "async",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/injectormodels.WorkloadResult"
+                            }
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/operationsmodels.Operation"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/namespaces/{namespace}/deployments/{name}/sidecars/{sidecar}": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Inject the named sidecar in the given deployment. The call is idempotent: when the sidecar is already injected\nas described by the spec nothing is changed and the deployment is returned, while the status is 409 when it differs",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "injector v2"
+                ],
+                "summary": "Activate the sidecar",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Namespace",
+                        "name": "namespace",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Deployment name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sidecar container name, without prefix",
+                        "name": "sidecar",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Target cluster, the default one when omitted",
+                        "name": "cluster",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Preview the change without persisting it",
+                        "name": "dryRun",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Run the call as an operation, answering 202 with its ID",
+                        "name": "async",
+                        "in": "query"
+                    },
+                    {
+                        "description": "SidecarSpec type",
+                        "name": "spec",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/injectormodels.SidecarSpec"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/injectormodels.Deployment"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/operationsmodels.Operation"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Remove the named sidecar from the given deployment",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "injector v2"
+                ],
+                "summary": "Remove the sidecar",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Namespace",
+                        "name": "namespace",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Deployment name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sidecar container name, without prefix",
+                        "name": "sidecar",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Target cluster, the default one when omitted",
+                        "name": "cluster",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Preview the change without persisting it",
+                        "name": "dryRun",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Run the call as an operation, answering 202 with its ID",
+                        "name": "async",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/injectormodels.Deployment"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/operationsmodels.Operation"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/namespaces/{namespace}/sidecars": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Remove every sidecar from all the deployments of the given namespace matching the label selector",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "injector v2"
+                ],
+                "summary": "Remove all the sidecars of a namespace",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Namespace",
+                        "name": "namespace",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Label selector of the deployments, all of them when omitted",
+                        "name": "labelSelector",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Target cluster, the default one when omitted",
+                        "name": "cluster",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Preview the change without persisting it",
+                        "name": "dryRun",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Run the call as an operation, answering 202 with its ID",
+                        "name": "async",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/injectormodels.WorkloadResult"
+                            }
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/operationsmodels.Operation"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/webhooks/deliveries": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the most recent deliveries of the events to the webhooks, oldest first, with their status, attempts and last error",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Query the webhook delivery log",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of most recent deliveries to return",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/webhooksmodels.Delivery"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "Report that the injector process is alive and serving requests, with no dependency checked",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Report whether the injector is ready to serve requests, that is when the Deployment caches, if enabled, are synced\nand the API server of the default cluster is reachable within 800 milliseconds; the other clusters are not checked.\nThe configuration is loaded before serving any request.\nThe injector is no longer ready once shutting down, so that the endpoints stop targeting it",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/httputil.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/status": {
+            "get": {
+                "description": "Get the readiness of the injector along with the outcome of every check, the uptime, the state of every cluster\nand the identity of the replica and of the leader running the background loops.\nThe status is 503 when not ready, like the readiness probe",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Detailed status",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/healthmodels.Status"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/healthmodels.Status"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "auditmodels.Record": {
+            "type": "object",
+            "properties": {
+                "Caller": {
+                    "type": "string"
+                },
+                "CallerGroups": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "Cluster": {
+                    "type": "string"
+                },
+                "ContainersAfter": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "ContainersBefore": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "DeploymentName": {
+                    "type": "string"
+                },
+                "DurationMs": {
+                    "type": "integer"
+                },
+                "Error": {
+                    "type": "string"
+                },
+                "Namespace": {
+                    "type": "string"
+                },
+                "Operation": {
+                    "type": "string"
+                },
+                "Payload": {
+                    "type": "object"
+                },
+                "RequestID": {
+                    "type": "string"
+                },
+                "Result": {
+                    "type": "string"
+                },
+                "SourceIP": {
+                    "type": "string"
+                },
+                "StatusCode": {
+                    "type": "integer"
+                },
+                "Time": {
+                    "type": "string"
+                }
+            }
+        },
+        "clustermodels.Cluster": {
+            "type": "object",
+            "properties": {
+                "Default": {
+                    "type": "boolean"
+                },
+                "Error": {
+                    "type": "string"
+                },
+                "Host": {
+                    "type": "string"
+                },
+                "Name": {
+                    "type": "string"
+                },
+                "Reachable": {
+                    "type": "boolean"
+                },
+                "Version": {
+                    "type": "string"
+                }
+            }
+        },
+        "healthmodels.Status": {
+            "type": "object",
+            "properties": {
+                "CacheSynced": {
+                    "type": "boolean"
+                },
+                "Clusters": {
+                    "description": "the clusters with the reachability and the version of their API server, their hosts omitted",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/clustermodels.Cluster"
+                    }
+                },
+                "IsLeader": {
+                    "type": "boolean"
+                },
+                "Leader": {
+                    "type": "string",
+                    "example": "kube-ondemand-sidecar-injector-7d9f8b6c5-q8j2m"
+                },
+                "Ready": {
+                    "description": "true when the readiness probe succeeds",
+                    "type": "boolean"
+                },
+                "Reason": {
+                    "description": "why the injector is not ready, empty when ready",
+                    "type": "string",
+                    "example": "Deployment cache not synced"
+                },
+                "Replica": {
+                    "description": "identity of this replica, and of the leader running the background loops like the removal of the expired sidecars,\nempty while not known",
+                    "type": "string",
+                    "example": "kube-ondemand-sidecar-injector-7d9f8b6c5-x2k4p"
+                },
+                "StartedAt": {
+                    "type": "string"
+                },
+                "Uptime": {
+                    "type": "string",
+                    "example": "26h3m4s"
+                }
+            }
+        },
+        "httputil.HTTPError": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "integer",
+                    "example": 404
+                },
+                "details": {
+                    "type": "string",
+                    "example": "deployments.apps \"payments-api\" not found"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "Error getting single deployment"
+                },
+                "requestId": {
+                    "type": "string",
+                    "example": "8f14e45fceea167a5a36dedd4bea2543"
+                }
+            }
+        },
+        "injectormodels.BulkSetSidecarPayload": {
+            "type": "object",
+            "required": [
+                "Namespaces",
+                "SidecarContainerName",
+                "SidecarImage"
+            ],
+            "properties": {
+                "Canaries": {
+                    "description": "deployments injected first, one at a time, before the others; the others are skipped if any of them fails",
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "Cluster": {
+                    "type": "string"
+                },
+                "Command": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "Concurrency": {
+                    "description": "deployments injected at the same time, 4 when zero",
+                    "type": "integer",
+                    "maximum": 16,
+                    "minimum": 0
+                },
+                "DryRun": {
+                    "type": "boolean"
+                },
+                "LabelSelector": {
+                    "type": "string",
+                    "example": "app.kubernetes.io/part-of=payments"
+                },
+                "Namespaces": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "Profile": {
+                    "type": "string"
+                },
+                "Reason": {
+                    "type": "string",
+                    "example": "INC-1234 troubleshooting"
+                },
+                "SidecarContainerName": {
+                    "type": "string"
+                },
+                "SidecarImage": {
+                    "type": "string"
+                },
+                "TTL": {
+                    "type": "string",
+                    "example": "2h"
+                },
+                "VolumeMounts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/injectormodels.Volume"
+                    }
+                }
+            }
+        },
+        "injectormodels.ClearAllSidecarsPayload": {
+            "type": "object",
+            "required": [
+                "Namespace"
+            ],
+            "properties": {
+                "Cluster": {
+                    "type": "string"
+                },
+                "DeploymentName": {
+                    "type": "string"
+                },
+                "DryRun": {
+                    "type": "boolean"
+                },
+                "LabelSelector": {
+                    "type": "string",
+                    "example": "app.kubernetes.io/part-of=payments"
+                },
+                "Namespace": {
+                    "type": "string"
+                }
+            }
+        },
+        "injectormodels.ClearSidecarPayload": {
+            "type": "object",
+            "required": [
+                "DeploymentName",
+                "Namespace",
+                "SidecarContainerName"
+            ],
+            "properties": {
+                "Cluster": {
+                    "type": "string"
+                },
+                "DeploymentName": {
+                    "type": "string"
+                },
+                "DryRun": {
+                    "type": "boolean"
+                },
+                "Namespace": {
+                    "type": "string"
+                },
+                "SidecarContainerName": {
+                    "type": "string"
                 }
             }
         },
         "injectormodels.Deployment": {
             "type": "object",
             "properties": {
+                "Cluster": {
+                    "type": "string"
+                },
+                "ContainerNames": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "DryRun": {
+                    "$ref": "#/definitions/injectormodels.DryRunPreview"
+                },
+                "InjectedSidecars": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/injectormodels.InjectedSidecar"
+                    }
+                },
                 "Name": {
                     "type": "string"
                 },
@@ -216,12 +2156,31 @@ const docTemplate = `{
                 }
             }
         },
+        "injectormodels.DryRunPreview": {
+            "type": "object",
+            "properties": {
+                "Diff": {
+                    "description": "unified diff of the current pod template, in YAML, against the resulting one",
+                    "type": "string"
+                },
+                "PodTemplate": {
+                    "description": "pod template of the Deployment as it would result from the call",
+                    "type": "object"
+                }
+            }
+        },
         "injectormodels.GetDeploymentsPayload": {
             "type": "object",
             "required": [
                 "Namespace"
             ],
             "properties": {
+                "Cluster": {
+                    "type": "string"
+                },
+                "Consistent": {
+                    "type": "boolean"
+                },
                 "DeploymentNameSubstringPattern": {
                     "type": "string"
                 },
@@ -233,6 +2192,27 @@ const docTemplate = `{
                 }
             }
         },
+        "injectormodels.GetHistoryPayload": {
+            "type": "object",
+            "required": [
+                "DeploymentName",
+                "Namespace"
+            ],
+            "properties": {
+                "Cluster": {
+                    "type": "string"
+                },
+                "Consistent": {
+                    "type": "boolean"
+                },
+                "DeploymentName": {
+                    "type": "string"
+                },
+                "Namespace": {
+                    "type": "string"
+                }
+            }
+        },
         "injectormodels.GetSingleDeploymentPayload": {
             "type": "object",
             "required": [
@@ -240,11 +2220,104 @@ const docTemplate = `{
                 "Namespace"
             ],
             "properties": {
+                "Cluster": {
+                    "type": "string"
+                },
+                "Consistent": {
+                    "type": "boolean"
+                },
+                "DeploymentName": {
+                    "type": "string"
+                },
+                "Namespace": {
+                    "type": "string"
+                }
+            }
+        },
+        "injectormodels.HistoryEntry": {
+            "type": "object",
+            "properties": {
+                "ContainerName": {
+                    "type": "string"
+                },
+                "ContainerNames": {
+                    "description": "containers of the pod template in the snapshot",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "Operation": {
+                    "description": "operation whose change followed the snapshot",
+                    "type": "string",
+                    "example": "SetSidecar"
+                },
+                "PerformedBy": {
+                    "type": "string"
+                },
+                "PodTemplate": {
+                    "type": "object"
+                },
+                "Revision": {
+                    "type": "integer"
+                },
+                "Time": {
+                    "type": "string"
+                }
+            }
+        },
+        "injectormodels.InjectedSidecar": {
+            "type": "object",
+            "properties": {
+                "ContainerName": {
+                    "type": "string"
+                },
+                "ExpiresAt": {
+                    "type": "string"
+                },
+                "Image": {
+                    "type": "string"
+                },
+                "InjectedAt": {
+                    "type": "string"
+                },
+                "InjectedBy": {
+                    "type": "string"
+                },
+                "Profile": {
+                    "type": "string"
+                },
+                "Reason": {
+                    "type": "string"
+                },
+                "TTL": {
+                    "type": "string"
+                }
+            }
+        },
+        "injectormodels.RestoreSnapshotPayload": {
+            "type": "object",
+            "required": [
+                "DeploymentName",
+                "Namespace",
+                "Revision"
+            ],
+            "properties": {
+                "Cluster": {
+                    "type": "string"
+                },
                 "DeploymentName": {
                     "type": "string"
                 },
+                "DryRun": {
+                    "type": "boolean"
+                },
                 "Namespace": {
                     "type": "string"
+                },
+                "Revision": {
+                    "type": "integer",
+                    "example": 3
                 }
             }
         },
@@ -257,6 +2330,9 @@ const docTemplate = `{
                 "SidecarImage"
             ],
             "properties": {
+                "Cluster": {
+                    "type": "string"
+                },
                 "Command": {
                     "type": "array",
                     "items": {
@@ -266,15 +2342,63 @@ const docTemplate = `{
                 "DeploymentName": {
                     "type": "string"
                 },
+                "DryRun": {
+                    "type": "boolean"
+                },
                 "Namespace": {
                     "type": "string"
                 },
+                "Profile": {
+                    "type": "string"
+                },
+                "Reason": {
+                    "type": "string",
+                    "example": "INC-1234 troubleshooting"
+                },
                 "SidecarContainerName": {
                     "type": "string"
                 },
                 "SidecarImage": {
                     "type": "string"
                 },
+                "TTL": {
+                    "type": "string",
+                    "example": "2h"
+                },
+                "VolumeMounts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/injectormodels.Volume"
+                    }
+                }
+            }
+        },
+        "injectormodels.SidecarSpec": {
+            "type": "object",
+            "required": [
+                "SidecarImage"
+            ],
+            "properties": {
+                "Command": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "Profile": {
+                    "type": "string"
+                },
+                "Reason": {
+                    "type": "string",
+                    "example": "INC-1234 troubleshooting"
+                },
+                "SidecarImage": {
+                    "type": "string"
+                },
+                "TTL": {
+                    "type": "string",
+                    "example": "2h"
+                },
                 "VolumeMounts": {
                     "type": "array",
                     "items": {
@@ -297,6 +2421,153 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "injectormodels.WorkloadResult": {
+            "type": "object",
+            "properties": {
+                "Cluster": {
+                    "type": "string"
+                },
+                "Containers": {
+                    "description": "sidecar containers changed by the operation on the deployment",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "Deployment": {
+                    "description": "resulting deployment, missing on error",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/injectormodels.Deployment"
+                        }
+                    ]
+                },
+                "DeploymentName": {
+                    "type": "string"
+                },
+                "Error": {
+                    "type": "string"
+                },
+                "Namespace": {
+                    "type": "string"
+                },
+                "Skipped": {
+                    "description": "the operation did not act on the deployment, as reported by Error",
+                    "type": "boolean"
+                }
+            }
+        },
+        "operationsmodels.LogEntry": {
+            "type": "object",
+            "properties": {
+                "Message": {
+                    "type": "string"
+                },
+                "Time": {
+                    "type": "string"
+                }
+            }
+        },
+        "operationsmodels.Operation": {
+            "type": "object",
+            "properties": {
+                "Caller": {
+                    "type": "string"
+                },
+                "CreatedAt": {
+                    "type": "string"
+                },
+                "Error": {
+                    "type": "string"
+                },
+                "ID": {
+                    "type": "string",
+                    "example": "1f0c6b9a4e2d8c7b5a3f1e0d9c8b7a6f"
+                },
+                "Logs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/operationsmodels.LogEntry"
+                    }
+                },
+                "Operation": {
+                    "type": "string",
+                    "example": "BulkSetSidecar"
+                },
+                "Progress": {
+                    "$ref": "#/definitions/operationsmodels.Progress"
+                },
+                "RequestID": {
+                    "type": "string"
+                },
+                "Result": {
+                    "description": "response body the call would have had if run synchronously, set once finished",
+                    "type": "object"
+                },
+                "Status": {
+                    "type": "string",
+                    "example": "Running"
+                },
+                "StatusCode": {
+                    "description": "http status the call would have had if run synchronously, set once finished",
+                    "type": "integer"
+                },
+                "UpdatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "operationsmodels.Progress": {
+            "type": "object",
+            "properties": {
+                "Done": {
+                    "type": "integer"
+                },
+                "Total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "webhooksmodels.Delivery": {
+            "type": "object",
+            "properties": {
+                "Attempts": {
+                    "type": "integer"
+                },
+                "CreatedAt": {
+                    "type": "string"
+                },
+                "Error": {
+                    "type": "string"
+                },
+                "EventID": {
+                    "type": "string"
+                },
+                "EventType": {
+                    "type": "string",
+                    "example": "SidecarInjected"
+                },
+                "ID": {
+                    "type": "string"
+                },
+                "Status": {
+                    "type": "string",
+                    "example": "Delivered"
+                },
+                "StatusCode": {
+                    "description": "http status of the last attempt, zero when no response was received",
+                    "type": "integer"
+                },
+                "UpdatedAt": {
+                    "type": "string"
+                },
+                "Webhook": {
+                    "description": "name of the webhook the event is delivered to",
+                    "type": "string",
+                    "example": "chatops"
+                }
+            }
         }
     },
     "securityDefinitions": {
@@ -304,6 +2575,11 @@ const docTemplate = `{
             "type": "apiKey",
             "name": "X-API-KEY",
             "in": "header"
+        },
+        "BearerAuth": {
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     },
     "externalDocs": {
@@ -0,0 +1,54 @@
+package httputil
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Drainer refuses the new mutating requests once the server is shutting down, so that no change is started
+// that could be interrupted half applied, while the in-flight ones complete and the reads are still served.
+// All its methods do nothing on a nil Drainer, which never drains
+type Drainer struct {
+	draining   atomic.Bool
+	retryAfter time.Duration
+}
+
+// NewDrainer creates a new instance of the Drainer, suggesting to the refused callers to retry after the given duration,
+// the time for another replica to take over
+func NewDrainer(retryAfter time.Duration) *Drainer {
+	return &Drainer{
+		retryAfter: retryAfter,
+	}
+}
+
+// Drain starts refusing the mutating requests
+func (d *Drainer) Drain() {
+
+	if d == nil {
+		return
+	}
+
+	d.draining.Store(true)
+}
+
+// Draining reports whether the server is shutting down
+func (d *Drainer) Draining() bool {
+	return d != nil && d.draining.Load()
+}
+
+// Middleware returns the middleware of the mutating routes, refusing the requests with http status 503 once draining
+func (d *Drainer) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if d.Draining() {
+			c.Header("Retry-After", strconv.Itoa(int(d.retryAfter.Round(time.Second).Seconds())))
+			AbortWithError(c, http.StatusServiceUnavailable, "Server shutting down, retry later", nil)
+			return
+		}
+
+		c.Next()
+	}
+}
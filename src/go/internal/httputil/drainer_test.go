@@ -0,0 +1,45 @@
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrainerMiddleware(t *testing.T) {
+	drainer := NewDrainer(5 * time.Second)
+
+	router := gin.New()
+	router.POST("/api/test", drainer.Middleware(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/test", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, drainer.Draining())
+
+	drainer.Drain()
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.True(t, drainer.Draining())
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "5", w.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"code": 503, "message": "Server shutting down, retry later"}`, w.Body.String())
+}
+
+func TestNilDrainer(t *testing.T) {
+	var drainer *Drainer
+
+	drainer.Drain()
+
+	assert.False(t, drainer.Draining())
+}
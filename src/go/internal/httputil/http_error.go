@@ -0,0 +1,41 @@
+package httputil
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPError is the body of every API error response
+type HTTPError struct {
+	Code      int    `json:"code" example:"404"`
+	Message   string `json:"message" example:"Error getting single deployment"`
+	Details   string `json:"details,omitempty" example:"deployments.apps \"payments-api\" not found"`
+	RequestID string `json:"requestId,omitempty" example:"8f14e45fceea167a5a36dedd4bea2543"`
+}
+
+// NewError writes the error response with the given status and message, detailed by err when not nil
+func NewError(c *gin.Context, status int, message string, err error) {
+	c.JSON(status, NewHTTPError(status, message, RequestIDFrom(c), err))
+}
+
+// NewHTTPError returns the body of the error response with the given status and message, detailed by err when not nil.
+// It permits to build the error outside of the request, as for the result of a background operation
+func NewHTTPError(status int, message string, requestID string, err error) HTTPError {
+
+	httpError := HTTPError{
+		Code:      status,
+		Message:   message,
+		RequestID: requestID,
+	}
+
+	if err != nil {
+		httpError.Details = err.Error()
+	}
+
+	return httpError
+}
+
+// AbortWithError writes the error response as NewError and stops the handlers chain
+func AbortWithError(c *gin.Context, status int, message string, err error) {
+	NewError(c, status, message, err)
+	c.Abort()
+}
@@ -0,0 +1,66 @@
+package httputil
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader is the header carrying the request ID, both in the request and in the response
+const RequestIDHeader = "X-Request-ID"
+
+const requestIDKey = "requestId"
+
+// maxRequestIDLength bounds the IDs provided by the callers, which are echoed in the responses, logs and audit records
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware assigns every request an ID, the one of the RequestIDHeader if provided by the caller and made of
+// up to 128 letters, digits, dots, underscores and hyphens, a new one otherwise, and returns it in the response header
+// so that callers can correlate responses, logs and audit records
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+
+		c.Set(requestIDKey, requestID)
+		c.Header(RequestIDHeader, requestID)
+
+		c.Next()
+	}
+}
+
+// RequestIDFrom returns the ID of the request, or an empty string if not assigned
+func RequestIDFrom(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// private functions and methods
+
+// validRequestID reports whether the ID provided by a caller is safe to echo, being neither empty nor too long
+// and holding only letters, digits, dots, underscores and hyphens
+func validRequestID(requestID string) bool {
+
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+
+	for _, char := range requestID {
+		switch {
+		case char >= 'a' && char <= 'z', char >= 'A' && char <= 'Z', char >= '0' && char <= '9':
+		case char == '.', char == '_', char == '-':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
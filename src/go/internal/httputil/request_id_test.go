@@ -0,0 +1,63 @@
+package httputil
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	testCases := []struct {
+		name      string
+		requestID string
+		kept      bool
+	}{
+		{"generated", "", false},
+		{"provided by caller", "caller-request-id", true},
+		{"provided by caller with every allowed character", "Caller_request.ID-42", true},
+		{"longest provided by caller", strings.Repeat("a", 128), true},
+		{"too long", strings.Repeat("a", 129), false},
+		{"forbidden characters", `id"><script>`, false},
+		{"spaces", "caller request id", false},
+		{"not ascii", "caller-requèst-id", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(RequestIDMiddleware())
+			router.GET("/api/test", func(c *gin.Context) {
+				NewError(c, http.StatusNotFound, "Error getting test", errors.New("test not found"))
+			})
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/api/test", nil)
+			if tc.requestID != "" {
+				req.Header.Set(RequestIDHeader, tc.requestID)
+			}
+			router.ServeHTTP(w, req)
+
+			requestID := w.Header().Get(RequestIDHeader)
+			assert.NotEmpty(t, requestID)
+			if tc.kept {
+				assert.Equal(t, tc.requestID, requestID)
+			} else {
+				// Check that the IDs not provided, or not valid, are replaced by a generated one
+				assert.NotEqual(t, tc.requestID, requestID)
+				assert.Len(t, requestID, 32)
+			}
+
+			// Check that the error body carries the same request ID
+			var httpError HTTPError
+			err := json.Unmarshal(w.Body.Bytes(), &httpError)
+			assert.NoError(t, err)
+			assert.Equal(t, HTTPError{Code: http.StatusNotFound, Message: "Error getting test", Details: "test not found", RequestID: requestID}, httpError)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package kube
+
+import (
+	"encoding/json"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+
+	injectormodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/injector"
+)
+
+const (
+	annotationPrefix = "ondemand-sidecar-injector.alesspanms.github.io/"
+
+	// SidecarsAnnotation holds, as JSON object keyed by container name, the metadata of the sidecars injected on demand
+	SidecarsAnnotation = annotationPrefix + "sidecars"
+
+	// HistoryAnnotation holds, as JSON array from the oldest, the snapshots of the pod template taken before every change
+	HistoryAnnotation = annotationPrefix + "history"
+)
+
+// readInjectedSidecars returns the injected sidecars metadata recorded on the deployment, keyed by container name
+func readInjectedSidecars(deployment *appsv1.Deployment) map[string]injectormodels.InjectedSidecar {
+
+	sidecars := make(map[string]injectormodels.InjectedSidecar)
+
+	value, found := deployment.Annotations[SidecarsAnnotation]
+	if !found || value == "" {
+		return sidecars
+	}
+
+	if err := json.Unmarshal([]byte(value), &sidecars); err != nil {
+		// a malformed annotation, probably edited by hand, is treated as empty
+		return make(map[string]injectormodels.InjectedSidecar)
+	}
+
+	return sidecars
+}
+
+// writeInjectedSidecars records the injected sidecars metadata on the deployment, removing the annotation when empty
+func writeInjectedSidecars(deployment *appsv1.Deployment, sidecars map[string]injectormodels.InjectedSidecar) error {
+
+	if len(sidecars) == 0 {
+		delete(deployment.Annotations, SidecarsAnnotation)
+		return nil
+	}
+
+	value, err := json.Marshal(sidecars)
+	if err != nil {
+		return err
+	}
+
+	if deployment.Annotations == nil {
+		deployment.Annotations = make(map[string]string)
+	}
+
+	deployment.Annotations[SidecarsAnnotation] = string(value)
+
+	return nil
+}
+
+// injectedSidecarsOf returns the injected sidecars metadata recorded on the deployment, sorted by container name
+func injectedSidecarsOf(deployment *appsv1.Deployment) []injectormodels.InjectedSidecar {
+
+	sidecars := readInjectedSidecars(deployment)
+
+	result := make([]injectormodels.InjectedSidecar, 0, len(sidecars))
+	for name, sidecar := range sidecars {
+		sidecar.ContainerName = name
+		result = append(result, sidecar)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ContainerName < result[j].ContainerName
+	})
+
+	return result
+}
@@ -0,0 +1,74 @@
+package kube
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	injectormodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/injector"
+)
+
+func TestInjectedSidecarsAnnotationRoundTrip(t *testing.T) {
+	deployment := &appsv1.Deployment{}
+	injectedAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	expiresAt := injectedAt.Add(2 * time.Hour)
+
+	sidecars := readInjectedSidecars(deployment)
+	assert.Empty(t, sidecars)
+
+	sidecars["prefix-debug"] = injectormodels.InjectedSidecar{ContainerName: "prefix-debug", Image: "busybox", InjectedBy: "jane", InjectedAt: injectedAt, TTL: "2h", ExpiresAt: &expiresAt, Reason: "INC-1234"}
+	sidecars["prefix-tracer"] = injectormodels.InjectedSidecar{ContainerName: "prefix-tracer", Image: "tracer", InjectedBy: "john", InjectedAt: injectedAt, Profile: "tracing"}
+
+	assert.NoError(t, writeInjectedSidecars(deployment, sidecars))
+	assert.Contains(t, deployment.Annotations, SidecarsAnnotation)
+
+	// Check that the metadata are read back sorted by container name
+	result := injectedSidecarsOf(deployment)
+	assert.Len(t, result, 2)
+	assert.Equal(t, "prefix-debug", result[0].ContainerName)
+	assert.Equal(t, "INC-1234", result[0].Reason)
+	assert.Equal(t, expiresAt, *result[0].ExpiresAt)
+	assert.Equal(t, "tracing", result[1].Profile)
+
+	// Check that the annotation is removed along with the last sidecar
+	assert.NoError(t, writeInjectedSidecars(deployment, map[string]injectormodels.InjectedSidecar{}))
+	assert.NotContains(t, deployment.Annotations, SidecarsAnnotation)
+}
+
+func TestReadInjectedSidecarsMalformedAnnotation(t *testing.T) {
+	deployment := &appsv1.Deployment{ObjectMeta: v1.ObjectMeta{Annotations: map[string]string{SidecarsAnnotation: "not json"}}}
+
+	assert.Empty(t, readInjectedSidecars(deployment))
+}
+
+func TestConvertToInternalModel(t *testing.T) {
+	deployment := appsv1.Deployment{
+		ObjectMeta: v1.ObjectMeta{
+			Name:        "payments-api",
+			Namespace:   "payments",
+			Annotations: map[string]string{SidecarsAnnotation: `{"prefix-debug":{"ContainerName":"prefix-debug","Image":"busybox","InjectedBy":"jane","InjectedAt":"2024-05-01T10:00:00Z"}}`},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "app"}, {Name: "prefix-debug"}},
+					Volumes:    []corev1.Volume{{Name: "data"}},
+				},
+			},
+		},
+	}
+
+	result := convertToInternalModel("staging", deployment)
+
+	assert.Equal(t, "staging", result.Cluster)
+	assert.Equal(t, "payments-api", result.Name)
+	assert.Equal(t, "payments", result.Namespace)
+	assert.Equal(t, []string{"data"}, result.VolumeNames)
+	assert.Equal(t, []string{"app", "prefix-debug"}, result.ContainerNames)
+	assert.Len(t, result.InjectedSidecars, 1)
+	assert.Equal(t, "jane", result.InjectedSidecars[0].InjectedBy)
+}
@@ -0,0 +1,267 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	injectormodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/injector"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/operations"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/tracing"
+)
+
+const (
+	defaultBulkConcurrency = 4
+	maxBulkConcurrency     = 16
+)
+
+var errCanaryFailed = errors.New("skipped, a canary deployment failed")
+
+// BulkSetSidecar injects the sidecar described by the payload into all the deployments of the namespaces matching the label selector,
+// ordered by namespace and name. The canaries, the first deployments of the order, are injected one at a time before the others,
+// which are skipped if any canary fails. The failure on a deployment does not stop the others, and is reported in its result.
+// The operation timeout bounds the injection of every deployment, rather than the whole operation
+func (kc *KubeClient) BulkSetSidecar(ctx context.Context, payload *injectormodels.BulkSetSidecarPayload) (result []injectormodels.WorkloadResult, err error) {
+
+	defer func() { err = classifyError(err) }()
+
+	ctx, span := tracing.Start(ctx, "KubeClient.BulkSetSidecar", tracing.Target(payload.Cluster, strings.Join(payload.Namespaces, ","), "")...)
+	defer func() { tracing.End(span, err) }()
+
+	kc.logger.Log().Info("BulkSetSidecar ", zap.Strings("Namespaces", payload.Namespaces), zap.String("LabelSelector", payload.LabelSelector), zap.String("SidecarImage", payload.SidecarImage))
+
+	if len(payload.Namespaces) == 0 {
+		err = invalidError("at least a namespace is required")
+		return
+	}
+
+	if payload.SidecarContainerName == "" {
+		err = invalidError("SidecarContainerName is required")
+		return
+	}
+
+	if payload.SidecarImage == "" {
+		err = invalidError("SidecarImage is required")
+		return
+	}
+
+	if _, err = kc.parseTTL(payload.TTL); err != nil {
+		return
+	}
+
+	if payload.Concurrency < 0 || payload.Concurrency > maxBulkConcurrency {
+		err = invalidError("Concurrency must be between 0 and 16")
+		return
+	}
+
+	if payload.Canaries < 0 {
+		err = invalidError("Canaries cannot be negative")
+		return
+	}
+
+	if _, parseErr := labels.Parse(payload.LabelSelector); parseErr != nil {
+		err = invalidError("LabelSelector '" + payload.LabelSelector + "' is not valid: " + parseErr.Error())
+		return
+	}
+
+	for _, namespace := range payload.Namespaces {
+		err = kc.checkNamespace(namespace)
+		if err != nil {
+			return
+		}
+	}
+
+	deployments, err := kc.bulkTargets(ctx, payload)
+	if err != nil {
+		return
+	}
+
+	result = make([]injectormodels.WorkloadResult, len(deployments))
+
+	tracker := operations.TrackerFrom(ctx)
+	tracker.SetTotal(len(deployments))
+
+	canaries := min(payload.Canaries, len(deployments))
+	for i := 0; i < canaries; i++ {
+		result[i] = kc.bulkSetSidecarOn(ctx, &deployments[i], payload)
+		tracker.Step(workloadStep(&result[i], "injected"))
+	}
+
+	for i := 0; i < canaries; i++ {
+		if result[i].Error != "" {
+			for j := canaries; j < len(deployments); j++ {
+				result[j] = skippedWorkload(kc.clusterNameOf(payload.Cluster), &deployments[j], errCanaryFailed)
+				tracker.Step(workloadStep(&result[j], "injected"))
+			}
+
+			kc.logger.Log().Warn("BulkSetSidecar - Canary failed, skipping the other deployments", zap.String("name", deployments[i].Name), zap.String("namespace", deployments[i].Namespace), zap.String("error", result[i].Error))
+			return
+		}
+	}
+
+	concurrency := payload.Concurrency
+	if concurrency == 0 {
+		concurrency = defaultBulkConcurrency
+	}
+
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i := canaries; i < len(deployments); i++ {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			result[i] = kc.bulkSetSidecarOn(ctx, &deployments[i], payload)
+			tracker.Step(workloadStep(&result[i], "injected"))
+		}(i)
+	}
+	wg.Wait()
+
+	return
+}
+
+// private functions and methods
+
+// bulkTargets returns the deployments targeted by the payload, ordered by namespace and name
+func (kc *KubeClient) bulkTargets(ctx context.Context, payload *injectormodels.BulkSetSidecarPayload) ([]appsv1.Deployment, error) {
+
+	ctx, cancel := kc.withTimeout(ctx)
+	defer cancel()
+
+	bound, err := kc.onCluster(payload.Cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	deployments := make([]appsv1.Deployment, 0)
+
+	for _, namespace := range sortedNames(setOf(payload.Namespaces)) {
+
+		list, err := bound.clientset.AppsV1().Deployments(namespace).List(ctx, v1.ListOptions{LabelSelector: payload.LabelSelector})
+		if err != nil {
+			return nil, err
+		}
+
+		namespaceOptedIn := bound.namespaceOptedIn(ctx, namespace)
+
+		for _, deployment := range list.Items {
+			// only the deployments allowed to receive sidecars are touched
+			if !namespaceOptedIn && !bound.currentPolicy().OptedIn(deployment.Labels, deployment.Annotations) {
+				continue
+			}
+
+			deployments = append(deployments, deployment)
+		}
+	}
+
+	// the API server lists by name already, but the order is the one of the canaries, so it is not left to chance
+	sortDeployments(deployments)
+
+	return deployments, nil
+}
+
+// bulkSetSidecarOn injects the sidecar of the payload into the deployment by means of SetSidecar
+func (kc *KubeClient) bulkSetSidecarOn(ctx context.Context, deployment *appsv1.Deployment, payload *injectormodels.BulkSetSidecarPayload) injectormodels.WorkloadResult {
+
+	workload := injectormodels.WorkloadResult{
+		Cluster:        kc.clusterNameOf(payload.Cluster),
+		Namespace:      deployment.Namespace,
+		DeploymentName: deployment.Name,
+		Containers:     make([]string, 0),
+	}
+
+	updated, err := kc.SetSidecar(ctx, &injectormodels.SetSidecarPayload{
+		Cluster:              payload.Cluster,
+		Namespace:            deployment.Namespace,
+		DeploymentName:       deployment.Name,
+		SidecarContainerName: payload.SidecarContainerName,
+		SidecarImage:         payload.SidecarImage,
+		Command:              payload.Command,
+		VolumeMounts:         payload.VolumeMounts,
+		Profile:              payload.Profile,
+		TTL:                  payload.TTL,
+		Reason:               payload.Reason,
+		DryRun:               payload.DryRun,
+	})
+
+	if err != nil {
+		kc.logger.Log().Error("BulkSetSidecar - Error setting sidecar", zap.String("name", deployment.Name), zap.String("namespace", deployment.Namespace), zap.Error(err))
+
+		workload.Error = err.Error()
+		return workload
+	}
+
+	workload.Containers = append(workload.Containers, kc.sidecarNamePrefix+payload.SidecarContainerName)
+	workload.Deployment = &updated
+
+	return workload
+}
+
+// workloadStep describes the outcome of an operation on a deployment, for the progress of the operation
+func workloadStep(workload *injectormodels.WorkloadResult, done string) string {
+
+	step := workload.Namespace + "/" + workload.DeploymentName + ": "
+
+	if workload.Skipped {
+		return step + workload.Error
+	}
+
+	if workload.Error != "" {
+		return step + "failed, " + workload.Error
+	}
+
+	if len(workload.Containers) == 0 {
+		return step + "nothing to do"
+	}
+
+	return step + done + " " + strings.Join(workload.Containers, ", ")
+}
+
+// clusterNameOf returns the name of the named cluster, or of the default one when name is empty
+func (kc *KubeClient) clusterNameOf(name string) string {
+
+	if name == "" {
+		return kc.defaultCluster
+	}
+
+	return name
+}
+
+func skippedWorkload(cluster string, deployment *appsv1.Deployment, reason error) injectormodels.WorkloadResult {
+	return injectormodels.WorkloadResult{
+		Cluster:        cluster,
+		Namespace:      deployment.Namespace,
+		DeploymentName: deployment.Name,
+		Containers:     make([]string, 0),
+		Error:          reason.Error(),
+		Skipped:        true,
+	}
+}
+
+func sortDeployments(deployments []appsv1.Deployment) {
+	sort.Slice(deployments, func(i, j int) bool {
+		if deployments[i].Namespace != deployments[j].Namespace {
+			return deployments[i].Namespace < deployments[j].Namespace
+		}
+		return deployments[i].Name < deployments[j].Name
+	})
+}
+
+func setOf(items []string) map[string]struct{} {
+
+	set := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		set[item] = struct{}{}
+	}
+
+	return set
+}
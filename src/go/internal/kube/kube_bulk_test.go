@@ -0,0 +1,154 @@
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	injectormodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/injector"
+	operationsmodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/operations"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/operations"
+)
+
+func TestBulkSetSidecar(t *testing.T) {
+	kubeClient, clientset, recorder := newFakeKubeClient(t, nil,
+		newTestDeploymentWithSidecars("payments", "payments-worker", "payments"),
+		newTestDeploymentWithSidecars("payments", "payments-api", "payments"),
+		newTestDeploymentWithSidecars("payments", "billing-api", "billing"),
+		newTestDeploymentWithSidecars("checkout", "checkout-api", "payments"),
+		newTestDeploymentWithSidecars("orders", "orders-api", "payments"))
+
+	results, err := kubeClient.BulkSetSidecar(context.Background(), &injectormodels.BulkSetSidecarPayload{
+		Namespaces:           []string{"payments", "checkout", "payments"},
+		LabelSelector:        "app.kubernetes.io/part-of=payments",
+		SidecarContainerName: "tracer",
+		SidecarImage:         "tracer:1.0",
+		Concurrency:          2,
+	})
+	assert.NoError(t, err)
+
+	// Check that the matching deployments of the namespaces are reported once, by namespace and name
+	if assert.Len(t, results, 3) {
+		for i, name := range []string{"checkout/checkout-api", "payments/payments-api", "payments/payments-worker"} {
+			assert.Equal(t, name, results[i].Namespace+"/"+results[i].DeploymentName)
+			assert.Equal(t, DefaultClusterName, results[i].Cluster)
+			assert.Empty(t, results[i].Error)
+			assert.Equal(t, []string{"prefix-tracer"}, results[i].Containers)
+			assert.Equal(t, []string{"app", "prefix-tracer"}, results[i].Deployment.ContainerNames)
+		}
+	}
+
+	for namespace, name := range map[string]string{"payments": "billing-api", "orders": "orders-api"} {
+		stored, err := clientset.AppsV1().Deployments(namespace).Get(context.Background(), name, v1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Len(t, stored.Spec.Template.Spec.Containers, 1, name)
+	}
+
+	assert.Len(t, recorder.Events, 3)
+}
+
+func TestBulkSetSidecarCanaryFailed(t *testing.T) {
+	kubeClient, clientset, _ := newFakeKubeClient(t, nil,
+		// the canary fails, having the sidecar already
+		newTestDeploymentWithSidecars("payments", "payments-api", "payments", "prefix-tracer"),
+		newTestDeploymentWithSidecars("payments", "payments-worker", "payments"))
+
+	results, err := kubeClient.BulkSetSidecar(context.Background(), &injectormodels.BulkSetSidecarPayload{
+		Namespaces:           []string{"payments"},
+		SidecarContainerName: "tracer",
+		SidecarImage:         "tracer:1.0",
+		Canaries:             1,
+	})
+	assert.NoError(t, err)
+
+	if assert.Len(t, results, 2) {
+		assert.Contains(t, results[0].Error, "already exists")
+		assert.False(t, results[0].Skipped)
+		assert.Equal(t, "payments-worker", results[1].DeploymentName)
+		assert.True(t, results[1].Skipped)
+		assert.Nil(t, results[1].Deployment)
+	}
+
+	stored, err := clientset.AppsV1().Deployments("payments").Get(context.Background(), "payments-worker", v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, stored.Spec.Template.Spec.Containers, 1)
+}
+
+func TestBulkSetSidecarCanarySucceeded(t *testing.T) {
+	kubeClient, _, _ := newFakeKubeClient(t, nil,
+		newTestDeploymentWithSidecars("payments", "payments-api", "payments"),
+		newTestDeploymentWithSidecars("payments", "payments-worker", "payments", "prefix-tracer"),
+		newTestDeploymentWithSidecars("payments", "payments-web", "payments"))
+
+	results, err := kubeClient.BulkSetSidecar(context.Background(), &injectormodels.BulkSetSidecarPayload{
+		Namespaces:           []string{"payments"},
+		SidecarContainerName: "tracer",
+		SidecarImage:         "tracer:1.0",
+		Canaries:             1,
+	})
+	assert.NoError(t, err)
+
+	// Check that a failure after the canaries does not stop the others
+	if assert.Len(t, results, 3) {
+		assert.Empty(t, results[0].Error)
+		assert.Empty(t, results[1].Error)
+		assert.Equal(t, "payments-worker", results[2].DeploymentName)
+		assert.Contains(t, results[2].Error, "already exists")
+	}
+}
+
+func TestBulkSetSidecarProgress(t *testing.T) {
+	kubeClient, _, _ := newFakeKubeClient(t, nil,
+		newTestDeploymentWithSidecars("payments", "payments-api", "payments", "prefix-tracer"),
+		newTestDeploymentWithSidecars("payments", "payments-worker", "payments"))
+
+	manager := operations.New(kubeClient.logger, operations.NewMemoryStore(10))
+
+	started := manager.Start("BulkSetSidecar", "jane", "", func(ctx context.Context) (any, int, error) {
+		results, err := kubeClient.BulkSetSidecar(ctx, &injectormodels.BulkSetSidecarPayload{
+			Namespaces:           []string{"payments"},
+			SidecarContainerName: "tracer",
+			SidecarImage:         "tracer:1.0",
+			Canaries:             1,
+		})
+		return results, 200, err
+	})
+	manager.Wait()
+
+	// Check that every deployment is a step of the operation, the skipped ones included
+	operation, err := manager.Get(started.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, operationsmodels.Progress{Done: 2, Total: 2}, operation.Progress)
+	if assert.Len(t, operation.Logs, 2) {
+		assert.Contains(t, operation.Logs[0].Message, "payments/payments-api: failed, ")
+		assert.Equal(t, "payments/payments-worker: skipped, a canary deployment failed", operation.Logs[1].Message)
+	}
+}
+
+func TestBulkSetSidecarErrors(t *testing.T) {
+	testCases := []struct {
+		name            string
+		payload         injectormodels.BulkSetSidecarPayload
+		expectedErrKind error
+	}{
+		{"missing namespaces", injectormodels.BulkSetSidecarPayload{SidecarContainerName: "tracer", SidecarImage: "tracer"}, ErrInvalid},
+		{"missing image", injectormodels.BulkSetSidecarPayload{Namespaces: []string{"payments"}, SidecarContainerName: "tracer"}, ErrInvalid},
+		{"invalid TTL", injectormodels.BulkSetSidecarPayload{Namespaces: []string{"payments"}, SidecarContainerName: "tracer", SidecarImage: "tracer", TTL: "soon"}, ErrInvalid},
+		{"negative TTL", injectormodels.BulkSetSidecarPayload{Namespaces: []string{"payments"}, SidecarContainerName: "tracer", SidecarImage: "tracer", TTL: "-5m"}, ErrInvalid},
+		{"zero TTL", injectormodels.BulkSetSidecarPayload{Namespaces: []string{"payments"}, SidecarContainerName: "tracer", SidecarImage: "tracer", TTL: "0s"}, ErrInvalid},
+		{"too much concurrency", injectormodels.BulkSetSidecarPayload{Namespaces: []string{"payments"}, SidecarContainerName: "tracer", SidecarImage: "tracer", Concurrency: 100}, ErrInvalid},
+		{"invalid selector", injectormodels.BulkSetSidecarPayload{Namespaces: []string{"payments"}, SidecarContainerName: "tracer", SidecarImage: "tracer", LabelSelector: "app in ("}, ErrInvalid},
+		{"unknown cluster", injectormodels.BulkSetSidecarPayload{Cluster: "mars", Namespaces: []string{"payments"}, SidecarContainerName: "tracer", SidecarImage: "tracer"}, ErrNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			kubeClient, _, _ := newFakeKubeClient(t, nil, newTestDeployment("payments", "payments-api"))
+
+			_, err := kubeClient.BulkSetSidecar(context.Background(), &tc.payload)
+			assert.ErrorIs(t, err, tc.expectedErrKind)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package kube
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// deploymentCache keeps in memory the Deployments of all the namespaces of a cluster, fed by a shared informer
+type deploymentCache struct {
+	factory  informers.SharedInformerFactory
+	informer cache.SharedIndexInformer
+	lister   appslisters.DeploymentLister
+}
+
+func newDeploymentCache(clientset kubernetes.Interface) *deploymentCache {
+
+	factory := informers.NewSharedInformerFactory(clientset, 0)
+	deployments := factory.Apps().V1().Deployments()
+
+	return &deploymentCache{
+		factory:  factory,
+		informer: deployments.Informer(),
+		lister:   deployments.Lister(),
+	}
+}
+
+// start runs the informers until stopCh is closed
+func (dc *deploymentCache) start(stopCh <-chan struct{}) {
+	dc.factory.Start(stopCh)
+}
+
+// synced reports whether the cache holds the full initial list of Deployments
+func (dc *deploymentCache) synced() bool {
+	return dc.informer.HasSynced()
+}
+
+// CacheSynced reports whether the Deployment caches of all the clusters are synced, always true when caching is disabled
+func (kc *KubeClient) CacheSynced() bool {
+
+	for _, target := range kc.clusters {
+		if target.cache != nil && !target.cache.synced() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// cacheReadable reports whether a read can be served by the cache of the bound cluster
+func (kc *KubeClient) cacheReadable(consistent bool) bool {
+	return !consistent && kc.cache != nil && kc.cache.synced()
+}
+
+// listDeployments lists the deployments of the namespace from the cache, or from the API server when
+// a consistent read is requested or the cache is not usable
+func (kc *KubeClient) listDeployments(ctx context.Context, namespace string, consistent bool) ([]appsv1.Deployment, error) {
+
+	if !kc.cacheReadable(consistent) {
+		deployments, err := kc.clientset.AppsV1().Deployments(namespace).List(ctx, v1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return deployments.Items, nil
+	}
+
+	kc.logger.Log().Debug("Listing deployments from cache", zap.String("cluster", kc.clusterName), zap.String("namespace", namespace))
+
+	cached, err := kc.cache.lister.Deployments(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	deployments := make([]appsv1.Deployment, len(cached))
+	for i, deployment := range cached {
+		deployments[i] = *deployment.DeepCopy()
+	}
+
+	return deployments, nil
+}
+
+// getDeployment gets the named deployment from the cache, or from the API server when
+// a consistent read is requested or the cache is not usable
+func (kc *KubeClient) getDeployment(ctx context.Context, namespace string, name string, consistent bool) (*appsv1.Deployment, error) {
+
+	if !kc.cacheReadable(consistent) {
+		return kc.clientset.AppsV1().Deployments(namespace).Get(ctx, name, v1.GetOptions{})
+	}
+
+	kc.logger.Log().Debug("Getting deployment from cache", zap.String("cluster", kc.clusterName), zap.String("namespace", namespace), zap.String("name", name))
+
+	deployment, err := kc.cache.lister.Deployments(namespace).Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return deployment.DeepCopy(), nil
+}
@@ -0,0 +1,64 @@
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+)
+
+func TestDeploymentCache(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&appsv1.Deployment{ObjectMeta: v1.ObjectMeta{Name: "payments-api", Namespace: "payments"}},
+		&appsv1.Deployment{ObjectMeta: v1.ObjectMeta{Name: "orders-api", Namespace: "orders"}},
+	)
+
+	deploymentCache := newDeploymentCache(clientset)
+	kubeClient := &KubeClient{
+		logger:   logging.New(),
+		clusters: map[string]*cluster{"test": {name: "test", cache: deploymentCache}},
+		cache:    deploymentCache,
+	}
+
+	// not synced caches are not readable
+	assert.False(t, kubeClient.CacheSynced())
+	assert.False(t, kubeClient.cacheReadable(false))
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	deploymentCache.start(stopCh)
+	assert.True(t, cache.WaitForCacheSync(stopCh, deploymentCache.informer.HasSynced))
+
+	assert.True(t, kubeClient.CacheSynced())
+	assert.True(t, kubeClient.cacheReadable(false))
+	assert.False(t, kubeClient.cacheReadable(true))
+
+	// reads are served by the cache, without a clientset
+	deployments, err := kubeClient.listDeployments(context.Background(), "payments", false)
+	assert.NoError(t, err)
+	assert.Len(t, deployments, 1)
+	assert.Equal(t, "payments-api", deployments[0].Name)
+
+	deployment, err := kubeClient.getDeployment(context.Background(), "orders", "orders-api", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "orders-api", deployment.Name)
+
+	_, err = kubeClient.getDeployment(context.Background(), "orders", "missing", false)
+	assert.True(t, apierrors.IsNotFound(err))
+	assert.ErrorIs(t, classifyError(err), ErrNotFound)
+}
+
+func TestCacheSyncedWithoutCaches(t *testing.T) {
+	kubeClient := &KubeClient{clusters: map[string]*cluster{"test": {name: "test"}}}
+
+	assert.True(t, kubeClient.CacheSynced())
+	assert.False(t, kubeClient.cacheReadable(false))
+}
@@ -0,0 +1,204 @@
+package kube
+
+import (
+	"context"
+	"strings"
+
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	injectormodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/injector"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/operations"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/tracing"
+)
+
+// ClearAllSidecars removes every sidecar container, named with the sidecar name prefix, from the deployment named by the payload
+// or from all the deployments of the namespace matching the label selector. The failure on a deployment does not stop the others,
+// and is reported in its result. The operation timeout bounds the clearing of every deployment, rather than the whole operation
+func (kc *KubeClient) ClearAllSidecars(ctx context.Context, payload *injectormodels.ClearAllSidecarsPayload) (result []injectormodels.WorkloadResult, err error) {
+
+	defer func() { err = classifyError(err) }()
+
+	ctx, span := tracing.Start(ctx, "KubeClient.ClearAllSidecars", tracing.Target(payload.Cluster, payload.Namespace, payload.DeploymentName)...)
+	defer func() { tracing.End(span, err) }()
+
+	kc.logger.Log().Info("ClearAllSidecars ", zap.String("DeploymentName", payload.DeploymentName), zap.String("Namespace", payload.Namespace), zap.String("LabelSelector", payload.LabelSelector))
+
+	if payload.Namespace == "" {
+		err = invalidError("namespace is required")
+		return
+	}
+
+	if payload.DeploymentName != "" && payload.LabelSelector != "" {
+		err = invalidError("LabelSelector cannot be combined with DeploymentName")
+		return
+	}
+
+	if _, parseErr := labels.Parse(payload.LabelSelector); parseErr != nil {
+		err = invalidError("LabelSelector '" + payload.LabelSelector + "' is not valid: " + parseErr.Error())
+		return
+	}
+
+	err = kc.checkNamespace(payload.Namespace)
+	if err != nil {
+		return
+	}
+
+	kc, err = kc.onCluster(payload.Cluster)
+	if err != nil {
+		return
+	}
+
+	if payload.DryRun {
+		// dry runs leave no trace, not even Events
+		kc.recorder = nil
+	}
+
+	deployments, err := kc.clearAllTargets(ctx, payload)
+	if err != nil {
+		return
+	}
+
+	tracker := operations.TrackerFrom(ctx)
+	tracker.SetTotal(len(deployments))
+
+	result = make([]injectormodels.WorkloadResult, 0, len(deployments))
+	for i := range deployments {
+		result = append(result, kc.clearAllOn(ctx, &deployments[i], payload.DryRun))
+		tracker.Step(workloadStep(&result[i], "removed"))
+	}
+
+	return
+}
+
+// private functions and methods
+
+// clearAllTargets returns the deployments targeted by the payload, read from the API server as they are going to be updated
+func (kc *KubeClient) clearAllTargets(ctx context.Context, payload *injectormodels.ClearAllSidecarsPayload) ([]appsv1.Deployment, error) {
+
+	ctx, cancel := kc.withTimeout(ctx)
+	defer cancel()
+
+	if payload.DeploymentName != "" {
+
+		deployment, err := kc.clientset.AppsV1().Deployments(payload.Namespace).Get(ctx, payload.DeploymentName, v1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		err = kc.checkOptIn(ctx, deployment)
+		if err != nil {
+			return nil, err
+		}
+
+		return []appsv1.Deployment{*deployment}, nil
+	}
+
+	list, err := kc.clientset.AppsV1().Deployments(payload.Namespace).List(ctx, v1.ListOptions{LabelSelector: payload.LabelSelector})
+	if err != nil {
+		return nil, err
+	}
+
+	namespaceOptedIn := kc.namespaceOptedIn(ctx, payload.Namespace)
+
+	deployments := make([]appsv1.Deployment, 0, len(list.Items))
+	for _, deployment := range list.Items {
+		// only the deployments allowed to receive sidecars are touched
+		if !namespaceOptedIn && !kc.currentPolicy().OptedIn(deployment.Labels, deployment.Annotations) {
+			continue
+		}
+
+		deployments = append(deployments, deployment)
+	}
+
+	return deployments, nil
+}
+
+// clearAllOn removes every sidecar container from the deployment, which is left untouched when there is none
+func (kc *KubeClient) clearAllOn(ctx context.Context, deployment *appsv1.Deployment, dryRun bool) (workload injectormodels.WorkloadResult) {
+
+	ctx, cancel := kc.withTimeout(ctx)
+	defer cancel()
+
+	workload = injectormodels.WorkloadResult{
+		Cluster:        kc.clusterName,
+		Namespace:      deployment.Namespace,
+		DeploymentName: deployment.Name,
+		Containers:     make([]string, 0),
+	}
+
+	current := deployment.DeepCopy()
+
+	sidecars := readInjectedSidecars(deployment)
+	removed := make([]corev1.Container, 0)
+	containers := make([]corev1.Container, 0, len(deployment.Spec.Template.Spec.Containers))
+
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		if strings.HasPrefix(container.Name, kc.sidecarNamePrefix) {
+			removed = append(removed, container)
+			workload.Containers = append(workload.Containers, container.Name)
+			delete(sidecars, container.Name)
+			continue
+		}
+
+		containers = append(containers, container)
+	}
+
+	if len(removed) == 0 {
+		unchanged := convertToInternalModel(kc.clusterName, *deployment)
+		workload.Deployment = &unchanged
+		return
+	}
+
+	deployment.Spec.Template.Spec.Containers = containers
+
+	fail := func(err error) injectormodels.WorkloadResult {
+		for _, container := range removed {
+			kc.recordSidecarFailed(deployment, "Removal", container.Name, container.Image, err)
+		}
+
+		kc.logger.Log().Error("ClearAllSidecars - Error clearing sidecars", zap.String("name", deployment.Name), zap.String("namespace", deployment.Namespace), zap.Error(err))
+
+		workload.Error = classifyError(err).Error()
+		return workload
+	}
+
+	err := writeInjectedSidecars(deployment, sidecars)
+	if err != nil {
+		return fail(err)
+	}
+
+	if dryRun {
+		preview, err := kc.previewUpdate(ctx, current, deployment)
+		if err != nil {
+			return fail(err)
+		}
+
+		workload.Deployment = &preview
+		return
+	}
+
+	err = kc.recordHistory(deployment, current, "ClearAllSidecars", "")
+	if err != nil {
+		return fail(err)
+	}
+
+	updatedDeployment, err := kc.clientset.AppsV1().Deployments(deployment.Namespace).Update(ctx, deployment, v1.UpdateOptions{})
+	if err != nil {
+		return fail(err)
+	}
+
+	for _, container := range removed {
+		kc.recordSidecarRemoved(deployment, container.Name, container.Image)
+	}
+
+	updated := convertToInternalModel(kc.clusterName, *updatedDeployment)
+	workload.Deployment = &updated
+
+	kc.logger.Log().Info("ClearAllSidecars - Updated Deployment", zap.String("name", updatedDeployment.Name), zap.String("namespace", updatedDeployment.Namespace), zap.Strings("removed", workload.Containers))
+
+	return
+}
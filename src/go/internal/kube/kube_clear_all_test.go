@@ -0,0 +1,212 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	appsv1client "k8s.io/client-go/kubernetes/typed/apps/v1"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+	injectormodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/injector"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/policy"
+)
+
+// newTestDeploymentWithSidecars returns a test deployment labeled with the part-of label and holding the named sidecars
+func newTestDeploymentWithSidecars(namespace string, name string, partOf string, sidecars ...string) *appsv1.Deployment {
+
+	deployment := newTestDeployment(namespace, name)
+	deployment.Labels = map[string]string{"app.kubernetes.io/part-of": partOf}
+
+	for _, sidecar := range sidecars {
+		deployment.Spec.Template.Spec.Containers = append(deployment.Spec.Template.Spec.Containers, corev1.Container{Name: sidecar, Image: "busybox"})
+	}
+
+	return deployment
+}
+
+func TestClearAllSidecarsOnDeployment(t *testing.T) {
+	kubeClient, clientset, recorder := newFakeKubeClient(t, nil, newTestDeploymentWithSidecars("payments", "payments-api", "payments", "prefix-debug", "prefix-tracer"))
+
+	results, err := kubeClient.ClearAllSidecars(context.Background(), &injectormodels.ClearAllSidecarsPayload{Namespace: "payments", DeploymentName: "payments-api"})
+	assert.NoError(t, err)
+
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "payments-api", results[0].DeploymentName)
+		assert.Equal(t, []string{"prefix-debug", "prefix-tracer"}, results[0].Containers)
+		assert.Empty(t, results[0].Error)
+		assert.Equal(t, []string{"app"}, results[0].Deployment.ContainerNames)
+	}
+
+	// Check the stored deployment
+	stored, err := clientset.AppsV1().Deployments("payments").Get(context.Background(), "payments-api", v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, stored.Spec.Template.Spec.Containers, 1)
+
+	assert.Len(t, recorder.Events, 2)
+}
+
+func TestClearAllSidecarsOnNamespace(t *testing.T) {
+	namespacePolicy := &policy.Policy{OptInRequired: true, OptInKey: "sidecars", OptInValue: "allowed"}
+
+	optedIn := newTestDeploymentWithSidecars("payments", "payments-api", "payments", "prefix-debug")
+	optedIn.Labels["sidecars"] = "allowed"
+	clean := newTestDeploymentWithSidecars("payments", "payments-db", "payments")
+	clean.Labels["sidecars"] = "allowed"
+	notOptedIn := newTestDeploymentWithSidecars("payments", "payments-worker", "payments", "prefix-debug")
+	notSelected := newTestDeploymentWithSidecars("payments", "billing-api", "billing", "prefix-debug")
+	notSelected.Labels["sidecars"] = "allowed"
+
+	kubeClient, clientset, _ := newFakeKubeClient(t, namespacePolicy, optedIn, clean, notOptedIn, notSelected)
+
+	results, err := kubeClient.ClearAllSidecars(context.Background(), &injectormodels.ClearAllSidecarsPayload{Namespace: "payments", LabelSelector: "app.kubernetes.io/part-of=payments"})
+	assert.NoError(t, err)
+
+	// Check that only the selected deployments allowed to receive sidecars are reported, the clean one without changes
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, "payments-api", results[0].DeploymentName)
+		assert.Equal(t, []string{"prefix-debug"}, results[0].Containers)
+		assert.Equal(t, "payments-db", results[1].DeploymentName)
+		assert.Empty(t, results[1].Containers)
+		assert.NotNil(t, results[1].Deployment)
+	}
+
+	for name, expectedContainers := range map[string]int{"payments-api": 1, "payments-worker": 2, "billing-api": 2} {
+		stored, err := clientset.AppsV1().Deployments("payments").Get(context.Background(), name, v1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Len(t, stored.Spec.Template.Spec.Containers, expectedContainers, name)
+	}
+}
+
+func TestClearAllSidecarsPartialFailure(t *testing.T) {
+	kubeClient, clientset, recorder := newFakeKubeClient(t, nil,
+		newTestDeploymentWithSidecars("payments", "payments-api", "payments", "prefix-debug"),
+		newTestDeploymentWithSidecars("payments", "payments-worker", "payments", "prefix-debug"))
+
+	clientset.PrependReactor("update", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.UpdateActionImpl).GetObject().(*appsv1.Deployment).Name == "payments-api" {
+			return true, nil, errors.New("boom")
+		}
+		return false, nil, nil
+	})
+
+	results, err := kubeClient.ClearAllSidecars(context.Background(), &injectormodels.ClearAllSidecarsPayload{Namespace: "payments"})
+	assert.NoError(t, err)
+
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, "boom", results[0].Error)
+		assert.Nil(t, results[0].Deployment)
+		assert.Empty(t, results[1].Error)
+		assert.Equal(t, []string{"app"}, results[1].Deployment.ContainerNames)
+	}
+
+	// one failure and one removal
+	assert.Len(t, recorder.Events, 2)
+}
+
+// slowUpdates is a clientset taking the given delay to update a deployment, unless the context is done earlier
+type slowUpdates struct {
+	kubernetes.Interface
+	delay time.Duration
+}
+
+func (c *slowUpdates) AppsV1() appsv1client.AppsV1Interface {
+	return &slowUpdatesApps{AppsV1Interface: c.Interface.AppsV1(), delay: c.delay}
+}
+
+type slowUpdatesApps struct {
+	appsv1client.AppsV1Interface
+	delay time.Duration
+}
+
+func (c *slowUpdatesApps) Deployments(namespace string) appsv1client.DeploymentInterface {
+	return &slowUpdatesDeployments{DeploymentInterface: c.AppsV1Interface.Deployments(namespace), delay: c.delay}
+}
+
+type slowUpdatesDeployments struct {
+	appsv1client.DeploymentInterface
+	delay time.Duration
+}
+
+func (c *slowUpdatesDeployments) Update(ctx context.Context, deployment *appsv1.Deployment, options v1.UpdateOptions) (*appsv1.Deployment, error) {
+	select {
+	case <-time.After(c.delay):
+		return c.DeploymentInterface.Update(ctx, deployment, options)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestClearAllSidecarsTimeoutPerDeployment(t *testing.T) {
+	objects := make([]runtime.Object, 0)
+	for _, name := range []string{"payments-api", "payments-worker", "payments-gateway", "payments-scheduler"} {
+		objects = append(objects, newTestDeploymentWithSidecars("payments", name, "payments", "prefix-debug"))
+	}
+
+	// every update takes less than the timeout, while all of them together take longer
+	clientset := &slowUpdates{Interface: fake.NewSimpleClientset(objects...), delay: 100 * time.Millisecond}
+
+	kubeClient, err := NewForClientset(logging.New(), clientset, Options{SidecarNamePrefix: "prefix", OperationTimeout: 250 * time.Millisecond})
+	assert.NoError(t, err)
+	t.Cleanup(kubeClient.(*KubeClient).clusters[DefaultClusterName].broadcaster.Shutdown)
+
+	results, err := kubeClient.ClearAllSidecars(context.Background(), &injectormodels.ClearAllSidecarsPayload{Namespace: "payments", LabelSelector: "app.kubernetes.io/part-of=payments"})
+	assert.NoError(t, err)
+
+	// Check that the timeout bounds the clearing of every deployment, rather than the whole operation
+	if assert.Len(t, results, 4) {
+		for _, result := range results {
+			assert.Empty(t, result.Error, result.DeploymentName)
+			assert.Equal(t, []string{"prefix-debug"}, result.Containers)
+		}
+	}
+}
+
+func TestClearAllSidecarsDryRun(t *testing.T) {
+	kubeClient, clientset, recorder := newFakeKubeClient(t, nil, newTestDeploymentWithSidecars("payments", "payments-api", "payments", "prefix-debug"))
+	simulateDryRun(clientset)
+
+	results, err := kubeClient.ClearAllSidecars(context.Background(), &injectormodels.ClearAllSidecarsPayload{Namespace: "payments", DryRun: true})
+	assert.NoError(t, err)
+
+	if assert.Len(t, results, 1) && assert.NotNil(t, results[0].Deployment.DryRun) {
+		assert.Contains(t, results[0].Deployment.DryRun.Diff, "-    name: prefix-debug\n")
+	}
+
+	stored, err := clientset.AppsV1().Deployments("payments").Get(context.Background(), "payments-api", v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, stored.Spec.Template.Spec.Containers, 2)
+
+	assert.Empty(t, recorder.Events)
+}
+
+func TestClearAllSidecarsErrors(t *testing.T) {
+	testCases := []struct {
+		name            string
+		payload         injectormodels.ClearAllSidecarsPayload
+		expectedErrKind error
+	}{
+		{"missing namespace", injectormodels.ClearAllSidecarsPayload{}, ErrInvalid},
+		{"deployment and selector", injectormodels.ClearAllSidecarsPayload{Namespace: "payments", DeploymentName: "payments-api", LabelSelector: "app=payments"}, ErrInvalid},
+		{"invalid selector", injectormodels.ClearAllSidecarsPayload{Namespace: "payments", LabelSelector: "app in ("}, ErrInvalid},
+		{"missing deployment", injectormodels.ClearAllSidecarsPayload{Namespace: "payments", DeploymentName: "payments-worker"}, ErrNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			kubeClient, _, _ := newFakeKubeClient(t, nil, newTestDeployment("payments", "payments-api"))
+
+			_, err := kubeClient.ClearAllSidecars(context.Background(), &tc.payload)
+			assert.ErrorIs(t, err, tc.expectedErrKind)
+		})
+	}
+}
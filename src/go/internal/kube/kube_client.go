@@ -3,8 +3,9 @@ package kube
 import (
 	"context"
 	"errors"
-	"flag"
-	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"time"
 
 	"go.uber.org/zap"
 	appsv1 "k8s.io/api/apps/v1"
@@ -12,91 +13,258 @@ import (
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
+	"k8s.io/client-go/tools/record"
 
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/auth"
 	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/metrics"
+	clustermodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/clusters"
 	injectormodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/injector"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/policy"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/tracing"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/webhooks"
 )
 
 type IKubeClient interface {
-	GetDeployments(namespace string) ([]injectormodels.Deployment, error)
-	GetSingleDeployment(namespace string, name string) (injectormodels.Deployment, error)
-	SetSidecar(payload *injectormodels.SetSidecarPayload) (injectormodels.Deployment, error)
-	ClearSidecar(payload *injectormodels.ClearSidecarPayload) (injectormodels.Deployment, error)
+	GetDeployments(ctx context.Context, payload *injectormodels.GetDeploymentsPayload) ([]injectormodels.Deployment, error)
+	GetSingleDeployment(ctx context.Context, payload *injectormodels.GetSingleDeploymentPayload) (injectormodels.Deployment, error)
+	SetSidecar(ctx context.Context, payload *injectormodels.SetSidecarPayload) (injectormodels.Deployment, error)
+	ClearSidecar(ctx context.Context, payload *injectormodels.ClearSidecarPayload) (injectormodels.Deployment, error)
+	BulkSetSidecar(ctx context.Context, payload *injectormodels.BulkSetSidecarPayload) ([]injectormodels.WorkloadResult, error)
+	ClearAllSidecars(ctx context.Context, payload *injectormodels.ClearAllSidecarsPayload) ([]injectormodels.WorkloadResult, error)
+	GetHistory(ctx context.Context, payload *injectormodels.GetHistoryPayload) ([]injectormodels.HistoryEntry, error)
+	RestoreSnapshot(ctx context.Context, payload *injectormodels.RestoreSnapshotPayload) (injectormodels.Deployment, error)
+	ReviewToken(ctx context.Context, token string) (*auth.Identity, error)
+	ReviewAccess(ctx context.Context, caller *auth.Identity, cluster string, namespace string, name string) (bool, error)
+	ForCaller(caller *auth.Identity) (IKubeClient, error)
+	ListClusters(ctx context.Context) []clustermodels.Cluster
+	CheckDefaultCluster(ctx context.Context, timeout time.Duration) clustermodels.Cluster
+	CacheSynced() bool
+	CountSidecars(ctx context.Context) ([]metrics.SidecarCount, error)
+	SetPolicy(namespacePolicy *policy.Policy)
+	ReapExpiredSidecars(ctx context.Context, now time.Time) (int, error)
+	DefaultClientset() kubernetes.Interface
+}
+
+// Options configures the KubeClient
+type Options struct {
+	SidecarNamePrefix string
+	// perform every call of the clients returned by ForCaller as the caller
+	Impersonate bool
+	// restricts the workloads every method can act on, replaceable later by SetPolicy
+	Policy *policy.Policy
+	// kubeconfig file, ~/.kube/config when empty
+	Kubeconfig string
+	// contexts of the kubeconfig file to load as clusters, AllContexts for all of them
+	KubeconfigContexts []string
+	// folder holding one kubeconfig file per cluster, named after the cluster
+	KubeconfigDir string
+	// cluster targeted by the payloads not naming one
+	DefaultCluster string
+	// serve GetDeployments and GetSingleDeployment from Deployment caches fed by informers
+	CacheEnabled bool
+	// maximum duration of every operation, including all its Kubernetes API calls; no limit when zero
+	OperationTimeout time.Duration
+	// snapshots of the pod template kept in the history of every deployment; no history when zero
+	HistoryLimit int
+	// accept the TTL of the sidecars, which are removed once expired by ReapExpiredSidecars; any TTL is refused when false
+	ExpirationEnabled bool
+	// notified of every Event recorded along the sidecar lifecycle; no notifications when nil
+	Notifier *webhooks.Notifier
+	// counts every Event recorded along the sidecar lifecycle; no metrics when nil
+	Metrics *metrics.Metrics
 }
 
 type KubeClient struct {
 	logger            *logging.Logger
 	sidecarNamePrefix string
-	clientset         *kubernetes.Clientset
-	config            *rest.Config
+	impersonate       bool
+	// shared with the bound copies, so that the policy can be replaced while serving
+	policy           *atomic.Pointer[policy.Policy]
+	operationTimeout time.Duration
+	historyLimit     int
+	expiration       bool
+	notifier         *webhooks.Notifier
+	metrics          *metrics.Metrics
+	caller           *auth.Identity
+	clusters         map[string]*cluster
+	clusterNames     []string
+	defaultCluster   string
+	// bound by onCluster to the cluster targeted by the call
+	clusterName  string
+	clientset    kubernetes.Interface
+	ownClientset kubernetes.Interface
+	config       *rest.Config
+	recorder     record.EventRecorder
+	cache        *deploymentCache
+}
+
+// NewKubeClient creates a new instance of the KubeClient acting on the clusters configured by the options
+func New(logger *logging.Logger, options Options) (IKubeClient, error) {
+	kubeClient := newKubeClient(logger, &options)
+
+	err := kubeClient.init(&options)
+	if err != nil {
+		return nil, err
+	}
+
+	return kubeClient, nil
+}
+
+// NewForClientset creates a new instance of the KubeClient acting on the single cluster reached by the given clientset,
+// named after options.DefaultCluster or DefaultClusterName. Impersonation is not available, lacking the rest config
+func NewForClientset(logger *logging.Logger, clientset kubernetes.Interface, options Options) (IKubeClient, error) {
+
+	if options.Impersonate {
+		return nil, errors.New("impersonation requires the rest config of the cluster")
+	}
+
+	name := options.DefaultCluster
+	if name == "" {
+		name = DefaultClusterName
+	}
+
+	kubeClient := newKubeClient(logger, &options)
+	kubeClient.clusters = make(map[string]*cluster, 1)
+	kubeClient.defaultCluster = name
+	kubeClient.addCluster(newClusterForClientset(name, nil, clientset, options.CacheEnabled))
+
+	return kubeClient, nil
 }
 
-// NewKubeClient creates a new instance of the KubeClient
-func New(logger *logging.Logger, sidecarNamePrefix string) IKubeClient {
-	kubeClient := &KubeClient{
+func newKubeClient(logger *logging.Logger, options *Options) *KubeClient {
+	return &KubeClient{
 		logger:            logger,
-		sidecarNamePrefix: sidecarNamePrefix + "-",
+		sidecarNamePrefix: options.SidecarNamePrefix + "-",
+		impersonate:       options.Impersonate,
+		policy:            newPolicyHolder(options.Policy),
+		operationTimeout:  options.OperationTimeout,
+		historyLimit:      options.HistoryLimit,
+		expiration:        options.ExpirationEnabled,
+		notifier:          options.Notifier,
+		metrics:           options.Metrics,
 	}
-	kubeClient.init()
-	return kubeClient
 }
 
-func (kc *KubeClient) init() {
-	var err error // Declare err variable
+func (kc *KubeClient) init(options *Options) error {
 
-	// Create a Kubernetes client
-	kc.config, err = rest.InClusterConfig()
+	// Create a Kubernetes client for every configured cluster
+	configs, defaultName, err := loadClusterConfigs(options)
 	if err != nil {
-		// panic(err.Error())
-		// fallback to local kubeconfig, if any
-
-		var kubeconfig *string
-		if home := homedir.HomeDir(); home != "" {
-			kubeconfig = flag.String("kubeconfig", filepath.Join(home, ".kube", "config"), "(optional) absolute path to the kubeconfig file")
-		} else {
-			kubeconfig = flag.String("kubeconfig", "", "absolute path to the kubeconfig file")
-		}
-		flag.Parse()
+		return err
+	}
+
+	kc.clusters = make(map[string]*cluster, len(configs))
+	kc.defaultCluster = defaultName
 
-		// use the current context in kubeconfig
-		kc.config, err = clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	for _, name := range sortedNames(configs) {
+		target, err := newCluster(name, configs[name], options.CacheEnabled)
 		if err != nil {
-			panic(err.Error())
+			return err
 		}
+
+		kc.addCluster(target)
 	}
 
-	clientset, err := kubernetes.NewForConfig(kc.config)
-	if err != nil {
-		panic(err.Error())
+	return nil
+}
+
+// addCluster registers the cluster and starts its cache, if any
+func (kc *KubeClient) addCluster(target *cluster) {
+
+	kc.clusters[target.name] = target
+	kc.clusterNames = append(kc.clusterNames, target.name)
+
+	if target.cache != nil {
+		// the informers live as long as the process
+		target.cache.start(make(chan struct{}))
+	}
+
+	kc.logger.Log().Info("Cluster configured", zap.String("cluster", target.name), zap.String("host", target.host()), zap.Bool("default", target.name == kc.defaultCluster))
+}
+
+// ForCaller returns a client acting on behalf of the given caller.
+// With impersonation enabled every call of the returned client impersonates the caller user and groups
+func (kc *KubeClient) ForCaller(caller *auth.Identity) (IKubeClient, error) {
+
+	if caller == nil {
+		return kc, nil
+	}
+
+	if kc.impersonate && caller.Username == "" {
+		return nil, errors.New("cannot impersonate a caller without username")
+	}
+
+	callerClient := *kc
+	callerClient.caller = caller
+
+	return &callerClient, nil
+}
+
+// withTimeout bounds the context with the operation timeout, if configured
+func (kc *KubeClient) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+
+	if kc.operationTimeout <= 0 {
+		return context.WithCancel(ctx)
 	}
 
-	kc.clientset = clientset
+	return context.WithTimeout(ctx, kc.operationTimeout)
 }
 
-func (kc *KubeClient) GetDeployments(namespace string) (result []injectormodels.Deployment, err error) {
+// callerName returns the name of the caller this client acts on behalf of
+func (kc *KubeClient) callerName() string {
+	return auth.CallerName(kc.caller)
+}
+
+func (kc *KubeClient) GetDeployments(ctx context.Context, payload *injectormodels.GetDeploymentsPayload) (result []injectormodels.Deployment, err error) {
+
+	defer func() { err = classifyError(err) }()
+
+	ctx, span := tracing.Start(ctx, "KubeClient.GetDeployments", tracing.Target(payload.Cluster, payload.Namespace, "")...)
+	defer func() { tracing.End(span, err) }()
+
+	ctx, cancel := kc.withTimeout(ctx)
+	defer cancel()
+
+	namespace := payload.Namespace
 
 	if namespace == "" {
-		err = errors.New("namespace is required")
+		err = invalidError("namespace is required")
 		return
 	}
 
-	kc.logger.Log().Info("Getting deployments", zap.String("namespace", namespace))
+	err = kc.checkNamespace(namespace)
+	if err != nil {
+		return
+	}
 
-	deployments, err := kc.clientset.AppsV1().Deployments(namespace).List(context.Background(), v1.ListOptions{})
+	kc, err = kc.onCluster(payload.Cluster)
+	if err != nil {
+		return
+	}
+
+	kc.logger.Log().Info("Getting deployments", zap.String("cluster", kc.clusterName), zap.String("namespace", namespace))
+
+	deployments, err := kc.listDeployments(ctx, namespace, payload.Consistent)
 
 	if err != nil {
 		//panic(err.Error())
 		return
 	}
 
+	namespaceOptedIn := kc.namespaceOptedIn(ctx, namespace)
+
 	result = make([]injectormodels.Deployment, 0)
-	for _, deployment := range deployments.Items {
+	for _, deployment := range deployments {
 
 		kc.logger.Log().Info("GetDeployments - Found Deployment", zap.String("name", deployment.Spec.Template.Name), zap.String("namespace", deployment.Namespace))
 
-		internalDeployment := convertToInternalModel(deployment)
+		// only the deployments allowed to receive sidecars are returned
+		if !namespaceOptedIn && !kc.currentPolicy().OptedIn(deployment.Labels, deployment.Annotations) {
+			continue
+		}
+
+		internalDeployment := convertToInternalModel(kc.clusterName, deployment)
 
 		result = append(result, internalDeployment)
 	}
@@ -104,16 +272,37 @@ func (kc *KubeClient) GetDeployments(namespace string) (result []injectormodels.
 	return
 }
 
-func (kc *KubeClient) GetSingleDeployment(namespace string, name string) (result injectormodels.Deployment, err error) {
+func (kc *KubeClient) GetSingleDeployment(ctx context.Context, payload *injectormodels.GetSingleDeploymentPayload) (result injectormodels.Deployment, err error) {
+
+	defer func() { err = classifyError(err) }()
+
+	ctx, span := tracing.Start(ctx, "KubeClient.GetSingleDeployment", tracing.Target(payload.Cluster, payload.Namespace, payload.DeploymentName)...)
+	defer func() { tracing.End(span, err) }()
+
+	ctx, cancel := kc.withTimeout(ctx)
+	defer cancel()
+
+	namespace := payload.Namespace
+	name := payload.DeploymentName
 
 	if namespace == "" {
-		err = errors.New("namespace is required")
+		err = invalidError("namespace is required")
 		return
 	}
 
-	kc.logger.Log().Info("Getting Single deployment", zap.String("namespace", namespace), zap.String("name", name))
+	err = kc.checkNamespace(namespace)
+	if err != nil {
+		return
+	}
+
+	kc, err = kc.onCluster(payload.Cluster)
+	if err != nil {
+		return
+	}
 
-	deployment, err := kc.clientset.AppsV1().Deployments(namespace).Get(context.Background(), name, v1.GetOptions{})
+	kc.logger.Log().Info("Getting Single deployment", zap.String("cluster", kc.clusterName), zap.String("namespace", namespace), zap.String("name", name))
+
+	deployment, err := kc.getDeployment(ctx, namespace, name, payload.Consistent)
 
 	if err != nil {
 		//panic(err.Error())
@@ -122,44 +311,84 @@ func (kc *KubeClient) GetSingleDeployment(namespace string, name string) (result
 
 	kc.logger.Log().Info("GetSingleDeployment - Found Deployment", zap.String("name", deployment.Spec.Template.Name), zap.String("namespace", deployment.Namespace))
 
-	result = convertToInternalModel(*deployment)
+	err = kc.checkOptIn(ctx, deployment)
+	if err != nil {
+		return
+	}
+
+	result = convertToInternalModel(kc.clusterName, *deployment)
 
 	return
 }
 
-func (kc *KubeClient) SetSidecar(payload *injectormodels.SetSidecarPayload) (result injectormodels.Deployment, err error) {
+func (kc *KubeClient) SetSidecar(ctx context.Context, payload *injectormodels.SetSidecarPayload) (result injectormodels.Deployment, err error) {
+
+	defer func() { err = classifyError(err) }()
+
+	ctx, span := tracing.Start(ctx, "KubeClient.SetSidecar", tracing.Target(payload.Cluster, payload.Namespace, payload.DeploymentName)...)
+	defer func() { tracing.End(span, err) }()
+
+	ctx, cancel := kc.withTimeout(ctx)
+	defer cancel()
 
 	kc.logger.Log().Info("SetSidecar ", zap.String("DeploymentName", payload.DeploymentName), zap.String("Namespace", payload.Namespace), zap.String("SidecarImage", payload.SidecarImage))
 
 	if payload.Namespace == "" {
-		err = errors.New("namespace is required")
+		err = invalidError("namespace is required")
 		return
 	}
 
 	if payload.DeploymentName == "" {
-		err = errors.New("DeploymentName is required")
+		err = invalidError("DeploymentName is required")
 		return
 	}
 
 	if payload.SidecarImage == "" {
-		err = errors.New("SidecarImage is required")
+		err = invalidError("SidecarImage is required")
 		return
 	}
 
+	err = kc.checkNamespace(payload.Namespace)
+	if err != nil {
+		return
+	}
+
+	ttl, err := kc.parseTTL(payload.TTL)
+	if err != nil {
+		return
+	}
+
+	kc, err = kc.onCluster(payload.Cluster)
+	if err != nil {
+		return
+	}
+
+	if payload.DryRun {
+		// dry runs leave no trace, not even Events
+		kc.recorder = nil
+	}
+
 	// will be used the container's image ENTRYPOINT if not provided
 	// if payload.Command == nil {
 	// 	payload.Command = []string{"/bin/sh", "-c", "while true; do sleep 10; done"}
 	// }
 
-	deployment, err := kc.clientset.AppsV1().Deployments(payload.Namespace).Get(context.Background(), payload.DeploymentName, v1.GetOptions{})
+	deployment, err := kc.clientset.AppsV1().Deployments(payload.Namespace).Get(ctx, payload.DeploymentName, v1.GetOptions{})
 
 	if err != nil {
 		//panic(err.Error())
 		return
 	}
 
+	current := deployment.DeepCopy()
+
 	kc.logger.Log().Info("SetSidecar - Found Deployment", zap.String("name", deployment.Spec.Template.Name), zap.String("namespace", deployment.Namespace))
 
+	err = kc.checkOptIn(ctx, deployment)
+	if err != nil {
+		return
+	}
+
 	v1Container := corev1.Container{
 		Name:         kc.sidecarNamePrefix + payload.SidecarContainerName,
 		Image:        payload.SidecarImage,
@@ -170,6 +399,21 @@ func (kc *KubeClient) SetSidecar(payload *injectormodels.SetSidecarPayload) (res
 		v1Container.Command = payload.Command
 	}
 
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		if container.Name == v1Container.Name {
+			if payload.AcceptExisting && sidecarMatches(deployment, &container, payload) {
+				kc.logger.Log().Info("SetSidecar - Sidecar already injected as requested", zap.String("name", deployment.Name), zap.String("namespace", deployment.Namespace), zap.String("container", container.Name))
+
+				result = convertToInternalModel(kc.clusterName, *deployment)
+				return
+			}
+
+			err = withKind(ErrConflict, errors.New("container '"+v1Container.Name+"' already exists"))
+			kc.recordSidecarFailed(deployment, "Injection", v1Container.Name, v1Container.Image, err)
+			return
+		}
+	}
+
 	for i, volumeMount := range payload.VolumeMounts {
 
 		found := false
@@ -181,7 +425,8 @@ func (kc *KubeClient) SetSidecar(payload *injectormodels.SetSidecarPayload) (res
 		}
 
 		if !found {
-			err = errors.New("volume '" + volumeMount.Name + "' not found. cannot continue.")
+			err = invalidError("volume '" + volumeMount.Name + "' not found. cannot continue.")
+			kc.recordSidecarFailed(deployment, "Injection", v1Container.Name, v1Container.Image, err)
 			return
 		}
 
@@ -193,82 +438,179 @@ func (kc *KubeClient) SetSidecar(payload *injectormodels.SetSidecarPayload) (res
 
 	deployment.Spec.Template.Spec.Containers = append(deployment.Spec.Template.Spec.Containers, v1Container)
 
-	_, err = kc.clientset.AppsV1().Deployments(payload.Namespace).Update(context.Background(), deployment, v1.UpdateOptions{})
+	injectedAt := time.Now().UTC()
+	injectedSidecar := injectormodels.InjectedSidecar{
+		ContainerName: v1Container.Name,
+		Image:         v1Container.Image,
+		InjectedBy:    kc.callerName(),
+		InjectedAt:    injectedAt,
+		Profile:       payload.Profile,
+		TTL:           payload.TTL,
+		Reason:        payload.Reason,
+	}
+
+	if ttl > 0 {
+		expiresAt := injectedAt.Add(ttl)
+		injectedSidecar.ExpiresAt = &expiresAt
+	}
+
+	sidecars := readInjectedSidecars(deployment)
+	sidecars[v1Container.Name] = injectedSidecar
+
+	err = writeInjectedSidecars(deployment, sidecars)
+	if err != nil {
+		kc.recordSidecarFailed(deployment, "Injection", v1Container.Name, v1Container.Image, err)
+		return
+	}
+
+	if payload.DryRun {
+		result, err = kc.previewUpdate(ctx, current, deployment)
+		return
+	}
+
+	err = kc.recordHistory(deployment, current, "SetSidecar", v1Container.Name)
+	if err != nil {
+		kc.recordSidecarFailed(deployment, "Injection", v1Container.Name, v1Container.Image, err)
+		return
+	}
+
+	_, err = kc.clientset.AppsV1().Deployments(payload.Namespace).Update(ctx, deployment, v1.UpdateOptions{})
 	if err != nil {
 		//panic(err.Error())
+		kc.recordSidecarFailed(deployment, "Injection", v1Container.Name, v1Container.Image, err)
 		return
 	}
 
-	updatedDeployment, err := kc.clientset.AppsV1().Deployments(payload.Namespace).Get(context.Background(), payload.DeploymentName, v1.GetOptions{})
+	kc.recordSidecarInjected(deployment, v1Container.Name, v1Container.Image)
+
+	updatedDeployment, err := kc.clientset.AppsV1().Deployments(payload.Namespace).Get(ctx, payload.DeploymentName, v1.GetOptions{})
 	if err != nil {
 		//panic(err.Error())
 		return
 	}
 
-	result = convertToInternalModel(*updatedDeployment)
+	result = convertToInternalModel(kc.clusterName, *updatedDeployment)
 
 	kc.logger.Log().Info("SetSidecar - Updated Deployment", zap.String("name", updatedDeployment.Spec.Template.Name), zap.String("namespace", updatedDeployment.Namespace))
 
 	return
 }
 
-func (kc *KubeClient) ClearSidecar(payload *injectormodels.ClearSidecarPayload) (result injectormodels.Deployment, err error) {
+func (kc *KubeClient) ClearSidecar(ctx context.Context, payload *injectormodels.ClearSidecarPayload) (result injectormodels.Deployment, err error) {
+
+	defer func() { err = classifyError(err) }()
+
+	ctx, span := tracing.Start(ctx, "KubeClient.ClearSidecar", tracing.Target(payload.Cluster, payload.Namespace, payload.DeploymentName)...)
+	defer func() { tracing.End(span, err) }()
+
+	ctx, cancel := kc.withTimeout(ctx)
+	defer cancel()
 
 	kc.logger.Log().Info("ClearSidecar ", zap.String("DeploymentName", payload.DeploymentName), zap.String("Namespace", payload.Namespace), zap.String("SidecarContainerName", payload.SidecarContainerName))
 
 	if payload.Namespace == "" {
-		err = errors.New("namespace is required")
+		err = invalidError("namespace is required")
 		return
 	}
 
 	if payload.DeploymentName == "" {
-		err = errors.New("DeploymentName is required")
+		err = invalidError("DeploymentName is required")
 		return
 	}
 
 	if payload.SidecarContainerName == "" {
-		err = errors.New("SidecarContainerName is required")
+		err = invalidError("SidecarContainerName is required")
 		return
 	}
 
-	deployment, err := kc.clientset.AppsV1().Deployments(payload.Namespace).Get(context.Background(), payload.DeploymentName, v1.GetOptions{})
+	err = kc.checkNamespace(payload.Namespace)
+	if err != nil {
+		return
+	}
+
+	kc, err = kc.onCluster(payload.Cluster)
+	if err != nil {
+		return
+	}
+
+	if payload.DryRun {
+		// dry runs leave no trace, not even Events
+		kc.recorder = nil
+	}
+
+	deployment, err := kc.clientset.AppsV1().Deployments(payload.Namespace).Get(ctx, payload.DeploymentName, v1.GetOptions{})
 
 	if err != nil {
 		//panic(err.Error())
 		return
 	}
 
+	current := deployment.DeepCopy()
+
 	kc.logger.Log().Info("SetSidecar - Found Deployment", zap.String("name", deployment.Spec.Template.Name), zap.String("namespace", deployment.Namespace))
 
+	err = kc.checkOptIn(ctx, deployment)
+	if err != nil {
+		return
+	}
+
+	containerName := kc.sidecarNamePrefix + payload.SidecarContainerName
+
 	//find index of named container
 	index := -1
+	image := ""
 	for i, container := range deployment.Spec.Template.Spec.Containers {
-		if container.Name == kc.sidecarNamePrefix+payload.SidecarContainerName {
+		if container.Name == containerName {
 			index = i
+			image = container.Image
 			break
 		}
 	}
 
 	if index == -1 {
-		err = errors.New("container ' " + payload.SidecarContainerName + "' not found ")
+		err = withKind(ErrNotFound, errors.New("container '"+payload.SidecarContainerName+"' not found"))
+		kc.recordSidecarFailed(deployment, "Removal", containerName, image, err)
 		return
 	} else {
 		deployment.Spec.Template.Spec.Containers = append(deployment.Spec.Template.Spec.Containers[:index], deployment.Spec.Template.Spec.Containers[index+1:]...)
 	}
 
-	_, err = kc.clientset.AppsV1().Deployments(payload.Namespace).Update(context.Background(), deployment, v1.UpdateOptions{})
+	sidecars := readInjectedSidecars(deployment)
+	delete(sidecars, containerName)
+
+	err = writeInjectedSidecars(deployment, sidecars)
+	if err != nil {
+		kc.recordSidecarFailed(deployment, "Removal", containerName, image, err)
+		return
+	}
+
+	if payload.DryRun {
+		result, err = kc.previewUpdate(ctx, current, deployment)
+		return
+	}
+
+	err = kc.recordHistory(deployment, current, "ClearSidecar", containerName)
+	if err != nil {
+		kc.recordSidecarFailed(deployment, "Removal", containerName, image, err)
+		return
+	}
+
+	_, err = kc.clientset.AppsV1().Deployments(payload.Namespace).Update(ctx, deployment, v1.UpdateOptions{})
 	if err != nil {
 		//panic(err.Error())
+		kc.recordSidecarFailed(deployment, "Removal", containerName, image, err)
 		return
 	}
 
-	updatedDeployment, err := kc.clientset.AppsV1().Deployments(payload.Namespace).Get(context.Background(), payload.DeploymentName, v1.GetOptions{})
+	kc.recordSidecarRemoved(deployment, containerName, image)
+
+	updatedDeployment, err := kc.clientset.AppsV1().Deployments(payload.Namespace).Get(ctx, payload.DeploymentName, v1.GetOptions{})
 	if err != nil {
 		//panic(err.Error())
 		return
 	}
 
-	result = convertToInternalModel(*updatedDeployment)
+	result = convertToInternalModel(kc.clusterName, *updatedDeployment)
 
 	kc.logger.Log().Info("SetSidecar - Updated Deployment", zap.String("name", updatedDeployment.Spec.Template.Name), zap.String("namespace", updatedDeployment.Namespace))
 
@@ -277,16 +619,67 @@ func (kc *KubeClient) ClearSidecar(payload *injectormodels.ClearSidecarPayload)
 
 // private functions and methods
 
-func convertToInternalModel(deployment appsv1.Deployment) injectormodels.Deployment {
+// parseTTL parses the TTL of a payload, zero when not given, failing with ErrInvalid when not a positive duration
+// or when the expired sidecars are not removed, so that no caller relies on a TTL never applied
+func (kc *KubeClient) parseTTL(ttl string) (time.Duration, error) {
+
+	if ttl == "" {
+		return 0, nil
+	}
+
+	if !kc.expiration {
+		return 0, invalidError("TTL not accepted, the removal of the expired sidecars being disabled")
+	}
+
+	duration, err := time.ParseDuration(ttl)
+	if err != nil {
+		return 0, invalidError("TTL '" + ttl + "' is not a valid duration: " + err.Error())
+	}
+
+	if duration <= 0 {
+		return 0, invalidError("TTL must be positive")
+	}
+
+	return duration, nil
+}
+
+// sidecarMatches reports whether the sidecar container of the deployment is the one described by the payload,
+// along with the metadata recorded when injected
+func sidecarMatches(deployment *appsv1.Deployment, container *corev1.Container, payload *injectormodels.SetSidecarPayload) bool {
+
+	if container.Image != payload.SidecarImage || !slices.Equal(container.Command, payload.Command) || len(container.VolumeMounts) != len(payload.VolumeMounts) {
+		return false
+	}
+
+	for i, volumeMount := range payload.VolumeMounts {
+		if container.VolumeMounts[i].Name != volumeMount.Name || container.VolumeMounts[i].MountPath != volumeMount.MountPath {
+			return false
+		}
+	}
+
+	sidecar := readInjectedSidecars(deployment)[container.Name]
+
+	return sidecar.Profile == payload.Profile && sidecar.TTL == payload.TTL && sidecar.Reason == payload.Reason
+}
+
+func convertToInternalModel(cluster string, deployment appsv1.Deployment) injectormodels.Deployment {
 
 	volumeNames := make([]string, len(deployment.Spec.Template.Spec.Volumes))
 	for i, volume := range deployment.Spec.Template.Spec.Volumes {
 		volumeNames[i] = volume.Name
 	}
 
+	containerNames := make([]string, len(deployment.Spec.Template.Spec.Containers))
+	for i, container := range deployment.Spec.Template.Spec.Containers {
+		containerNames[i] = container.Name
+	}
+
 	return injectormodels.Deployment{
-		Name:        deployment.Name,
-		Namespace:   deployment.Namespace,
-		VolumeNames: volumeNames,
+		Cluster:          cluster,
+		Name:             deployment.Name,
+		Namespace:        deployment.Namespace,
+		VolumeNames:      volumeNames,
+		ContainerNames:   containerNames,
+		InjectedSidecars: injectedSidecarsOf(&deployment),
 	}
 }
@@ -1,17 +1,25 @@
 package kube
 
 import (
+	"context"
+	"time"
+
 	"github.com/stretchr/testify/mock"
+	"k8s.io/client-go/kubernetes"
 
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/auth"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/metrics"
+	clustermodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/clusters"
 	injectormodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/injector"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/policy"
 )
 
 type KubeClientMock struct {
 	mock.Mock
 }
 
-func (m *KubeClientMock) GetDeployments(namespace string) ([]injectormodels.Deployment, error) {
-	args := m.Called(namespace)
+func (m *KubeClientMock) GetDeployments(ctx context.Context, payload *injectormodels.GetDeploymentsPayload) ([]injectormodels.Deployment, error) {
+	args := m.Called(ctx, payload)
 	res := args.Get(0)
 	if res == nil {
 		return nil, args.Error(1)
@@ -19,8 +27,8 @@ func (m *KubeClientMock) GetDeployments(namespace string) ([]injectormodels.Depl
 	return res.([]injectormodels.Deployment), args.Error(1)
 }
 
-func (m *KubeClientMock) GetSingleDeployment(namespace string, name string) (injectormodels.Deployment, error) {
-	args := m.Called(namespace, name)
+func (m *KubeClientMock) GetSingleDeployment(ctx context.Context, payload *injectormodels.GetSingleDeploymentPayload) (injectormodels.Deployment, error) {
+	args := m.Called(ctx, payload)
 	res := args.Get(0)
 	if res == nil {
 		return injectormodels.Deployment{}, args.Error(1)
@@ -28,8 +36,8 @@ func (m *KubeClientMock) GetSingleDeployment(namespace string, name string) (inj
 	return res.(injectormodels.Deployment), args.Error(1)
 }
 
-func (m *KubeClientMock) SetSidecar(payload *injectormodels.SetSidecarPayload) (injectormodels.Deployment, error) {
-	args := m.Called(payload)
+func (m *KubeClientMock) SetSidecar(ctx context.Context, payload *injectormodels.SetSidecarPayload) (injectormodels.Deployment, error) {
+	args := m.Called(ctx, payload)
 	res := args.Get(0)
 	if res == nil {
 		return injectormodels.Deployment{}, args.Error(1)
@@ -37,11 +45,104 @@ func (m *KubeClientMock) SetSidecar(payload *injectormodels.SetSidecarPayload) (
 	return args.Get(0).(injectormodels.Deployment), args.Error(1)
 }
 
-func (m *KubeClientMock) ClearSidecar(payload *injectormodels.ClearSidecarPayload) (injectormodels.Deployment, error) {
-	args := m.Called(payload)
+func (m *KubeClientMock) ClearSidecar(ctx context.Context, payload *injectormodels.ClearSidecarPayload) (injectormodels.Deployment, error) {
+	args := m.Called(ctx, payload)
 	res := args.Get(0)
 	if res == nil {
 		return injectormodels.Deployment{}, args.Error(1)
 	}
 	return args.Get(0).(injectormodels.Deployment), args.Error(1)
 }
+
+func (m *KubeClientMock) BulkSetSidecar(ctx context.Context, payload *injectormodels.BulkSetSidecarPayload) ([]injectormodels.WorkloadResult, error) {
+	args := m.Called(ctx, payload)
+	res := args.Get(0)
+	if res == nil {
+		return nil, args.Error(1)
+	}
+	return res.([]injectormodels.WorkloadResult), args.Error(1)
+}
+
+func (m *KubeClientMock) ClearAllSidecars(ctx context.Context, payload *injectormodels.ClearAllSidecarsPayload) ([]injectormodels.WorkloadResult, error) {
+	args := m.Called(ctx, payload)
+	res := args.Get(0)
+	if res == nil {
+		return nil, args.Error(1)
+	}
+	return res.([]injectormodels.WorkloadResult), args.Error(1)
+}
+
+func (m *KubeClientMock) GetHistory(ctx context.Context, payload *injectormodels.GetHistoryPayload) ([]injectormodels.HistoryEntry, error) {
+	args := m.Called(ctx, payload)
+	res := args.Get(0)
+	if res == nil {
+		return nil, args.Error(1)
+	}
+	return res.([]injectormodels.HistoryEntry), args.Error(1)
+}
+
+func (m *KubeClientMock) RestoreSnapshot(ctx context.Context, payload *injectormodels.RestoreSnapshotPayload) (injectormodels.Deployment, error) {
+	args := m.Called(ctx, payload)
+	res := args.Get(0)
+	if res == nil {
+		return injectormodels.Deployment{}, args.Error(1)
+	}
+	return res.(injectormodels.Deployment), args.Error(1)
+}
+
+func (m *KubeClientMock) ReviewToken(ctx context.Context, token string) (*auth.Identity, error) {
+	args := m.Called(ctx, token)
+	res := args.Get(0)
+	if res == nil {
+		return nil, args.Error(1)
+	}
+	return res.(*auth.Identity), args.Error(1)
+}
+
+func (m *KubeClientMock) ReviewAccess(ctx context.Context, caller *auth.Identity, cluster string, namespace string, name string) (bool, error) {
+	args := m.Called(ctx, caller, cluster, namespace, name)
+	return args.Bool(0), args.Error(1)
+}
+
+// ForCaller returns the mock itself, so that expectations set on it apply to caller clients as well
+func (m *KubeClientMock) ForCaller(caller *auth.Identity) (IKubeClient, error) {
+	return m, nil
+}
+
+func (m *KubeClientMock) ListClusters(ctx context.Context) []clustermodels.Cluster {
+	args := m.Called(ctx)
+	return args.Get(0).([]clustermodels.Cluster)
+}
+
+func (m *KubeClientMock) CheckDefaultCluster(ctx context.Context, timeout time.Duration) clustermodels.Cluster {
+	args := m.Called(ctx, timeout)
+	return args.Get(0).(clustermodels.Cluster)
+}
+
+func (m *KubeClientMock) CacheSynced() bool {
+	args := m.Called()
+	return args.Bool(0)
+}
+
+func (m *KubeClientMock) CountSidecars(ctx context.Context) ([]metrics.SidecarCount, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]metrics.SidecarCount), args.Error(1)
+}
+
+func (m *KubeClientMock) SetPolicy(namespacePolicy *policy.Policy) {
+	m.Called(namespacePolicy)
+}
+
+func (m *KubeClientMock) ReapExpiredSidecars(ctx context.Context, now time.Time) (int, error) {
+	args := m.Called(ctx, now)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *KubeClientMock) DefaultClientset() kubernetes.Interface {
+	args := m.Called()
+	res := args.Get(0)
+	if res == nil {
+		return nil
+	}
+	return res.(kubernetes.Interface)
+}
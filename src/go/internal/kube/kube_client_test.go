@@ -0,0 +1,695 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/auth"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+	injectormodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/injector"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/policy"
+)
+
+func newTestKubeClient(t *testing.T, impersonate bool) *KubeClient {
+
+	kubeClient := &KubeClient{
+		logger:         logging.New(),
+		impersonate:    impersonate,
+		clusters:       make(map[string]*cluster),
+		clusterNames:   []string{"production", "staging"},
+		defaultCluster: "production",
+	}
+
+	for _, name := range kubeClient.clusterNames {
+		target, err := newCluster(name, &rest.Config{Host: "https://" + name + ".example:6443"}, true)
+		assert.NoError(t, err)
+		kubeClient.clusters[name] = target
+	}
+
+	return kubeClient
+}
+
+func TestForCaller(t *testing.T) {
+	caller := &auth.Identity{Username: "jane", UID: "1234", Groups: []string{"developers"}}
+
+	testCases := []struct {
+		name        string
+		impersonate bool
+		caller      *auth.Identity
+		expectError bool
+	}{
+		{"no caller", true, nil, false},
+		{"caller without impersonation", false, caller, false},
+		{"caller with impersonation", true, caller, false},
+		{"caller without username", true, &auth.Identity{}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			kubeClient := newTestKubeClient(t, tc.impersonate)
+
+			result, err := kubeClient.ForCaller(tc.caller)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			callerClient := result.(*KubeClient)
+
+			if tc.caller == nil {
+				assert.Same(t, kubeClient, callerClient)
+				return
+			}
+
+			assert.NotSame(t, kubeClient, callerClient)
+			assert.Equal(t, tc.caller, callerClient.caller)
+			assert.Nil(t, kubeClient.caller)
+		})
+	}
+}
+
+func TestOnCluster(t *testing.T) {
+	caller := &auth.Identity{Username: "jane", UID: "1234", Groups: []string{"developers"}, Extra: map[string][]string{"scopes": {"a"}}}
+
+	testCases := []struct {
+		name            string
+		cluster         string
+		impersonate     bool
+		caller          *auth.Identity
+		expectedCluster string
+		expectError     bool
+	}{
+		{"default cluster", "", false, nil, "production", false},
+		{"named cluster", "staging", false, caller, "staging", false},
+		{"unknown cluster", "testing", false, nil, "", true},
+		{"impersonated caller", "staging", true, caller, "staging", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			kubeClient := newTestKubeClient(t, tc.impersonate)
+			kubeClient.caller = tc.caller
+
+			bound, err := kubeClient.onCluster(tc.cluster)
+			if tc.expectError {
+				assert.ErrorIs(t, err, ErrClusterNotFound)
+				return
+			}
+			assert.NoError(t, err)
+
+			target := kubeClient.clusters[tc.expectedCluster]
+
+			assert.NotSame(t, kubeClient, bound)
+			assert.Empty(t, kubeClient.clusterName)
+			assert.Equal(t, tc.expectedCluster, bound.clusterName)
+			assert.Same(t, target.clientset, bound.ownClientset)
+			assert.Same(t, target.recorder, bound.recorder)
+			assert.Empty(t, target.config.Impersonate.UserName)
+
+			if tc.impersonate {
+				assert.Equal(t, rest.ImpersonationConfig{UserName: "jane", UID: "1234", Groups: []string{"developers"}, Extra: map[string][]string{"scopes": {"a"}}}, bound.config.Impersonate)
+				assert.NotSame(t, target.clientset, bound.clientset)
+				assert.Nil(t, bound.cache)
+			} else {
+				assert.Same(t, target.config, bound.config)
+				assert.Same(t, target.clientset, bound.clientset)
+				assert.Same(t, target.cache, bound.cache)
+			}
+		})
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	kubeClient := &KubeClient{operationTimeout: time.Minute}
+
+	ctx, cancel := kubeClient.withTimeout(context.Background())
+	defer cancel()
+
+	deadline, found := ctx.Deadline()
+	assert.True(t, found)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+
+	// without timeout the context is only cancellable
+	kubeClient.operationTimeout = 0
+
+	ctx, cancel = kubeClient.withTimeout(context.Background())
+	_, found = ctx.Deadline()
+	assert.False(t, found)
+
+	cancel()
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+}
+
+// newFakeKubeClient creates a KubeClient acting on a fake clientset holding the given objects,
+// whose events are recorded by the returned fake recorder
+func newFakeKubeClient(t *testing.T, namespacePolicy *policy.Policy, objects ...runtime.Object) (*KubeClient, *fake.Clientset, *record.FakeRecorder) {
+
+	clientset := fake.NewSimpleClientset(objects...)
+
+	result, err := NewForClientset(logging.New(), clientset, Options{SidecarNamePrefix: "prefix", Policy: namespacePolicy, ExpirationEnabled: true})
+	assert.NoError(t, err)
+
+	kubeClient := result.(*KubeClient)
+
+	target := kubeClient.clusters[DefaultClusterName]
+	t.Cleanup(target.broadcaster.Shutdown)
+
+	recorder := record.NewFakeRecorder(10)
+	target.recorder = recorder
+
+	return kubeClient, clientset, recorder
+}
+
+func newTestDeployment(namespace string, name string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: v1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "app", Image: "payments-api:1.0"}},
+					Volumes:    []corev1.Volume{{Name: "data"}},
+				},
+			},
+		},
+	}
+}
+
+func TestNewForClientset(t *testing.T) {
+	kubeClient, _, _ := newFakeKubeClient(t, nil)
+
+	assert.Equal(t, DefaultClusterName, kubeClient.defaultCluster)
+	assert.Equal(t, []string{DefaultClusterName}, kubeClient.clusterNames)
+
+	clusters := kubeClient.ListClusters(context.Background())
+	assert.Len(t, clusters, 1)
+	assert.Equal(t, DefaultClusterName, clusters[0].Name)
+	assert.True(t, clusters[0].Default)
+	assert.True(t, clusters[0].Reachable)
+
+	defaultCluster := kubeClient.CheckDefaultCluster(context.Background(), time.Second)
+	assert.Equal(t, clusters[0], defaultCluster)
+
+	// impersonation needs the rest config for creating the impersonated clientsets
+	_, err := NewForClientset(logging.New(), fake.NewSimpleClientset(), Options{Impersonate: true})
+	assert.Error(t, err)
+}
+
+func TestCheckDefaultClusterTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	target, err := newCluster("production", &rest.Config{Host: server.URL}, false)
+	assert.NoError(t, err)
+	t.Cleanup(target.broadcaster.Shutdown)
+
+	kubeClient := &KubeClient{
+		logger:         logging.New(),
+		clusters:       map[string]*cluster{"production": target},
+		clusterNames:   []string{"production"},
+		defaultCluster: "production",
+	}
+
+	// Check that an API server not answering is reported within the timeout
+	started := time.Now()
+	status := kubeClient.CheckDefaultCluster(context.Background(), 100*time.Millisecond)
+
+	assert.Less(t, time.Since(started), time.Second)
+	assert.Equal(t, "production", status.Name)
+	assert.True(t, status.Default)
+	assert.False(t, status.Reachable)
+	assert.NotEmpty(t, status.Error)
+}
+
+func TestGetDeployments(t *testing.T) {
+	optedIn := newTestDeployment("payments", "payments-worker")
+	optedIn.Labels = map[string]string{policy.DefaultOptInKey: "true"}
+
+	testCases := []struct {
+		name            string
+		policy          *policy.Policy
+		payload         injectormodels.GetDeploymentsPayload
+		expectedNames   []string
+		expectedErrKind error
+	}{
+		{"namespace deployments", nil, injectormodels.GetDeploymentsPayload{Namespace: "payments"}, []string{"payments-api", "payments-worker"}, nil},
+		{"opted in deployments", &policy.Policy{OptInRequired: true}, injectormodels.GetDeploymentsPayload{Namespace: "payments"}, []string{"payments-worker"}, nil},
+		{"missing namespace", nil, injectormodels.GetDeploymentsPayload{}, nil, ErrInvalid},
+		{"denied namespace", &policy.Policy{DeniedNamespaces: []string{"payments"}}, injectormodels.GetDeploymentsPayload{Namespace: "payments"}, nil, ErrForbidden},
+		{"unknown cluster", nil, injectormodels.GetDeploymentsPayload{Cluster: "staging", Namespace: "payments"}, nil, ErrNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.policy != nil {
+				assert.NoError(t, tc.policy.Validate())
+			}
+
+			kubeClient, _, _ := newFakeKubeClient(t, tc.policy,
+				newTestDeployment("payments", "payments-api"), optedIn, newTestDeployment("orders", "orders-api"))
+
+			result, err := kubeClient.GetDeployments(context.Background(), &tc.payload)
+
+			if tc.expectedErrKind != nil {
+				assert.ErrorIs(t, err, tc.expectedErrKind)
+				return
+			}
+			assert.NoError(t, err)
+
+			names := make([]string, len(result))
+			for i, deployment := range result {
+				names[i] = deployment.Name
+				assert.Equal(t, DefaultClusterName, deployment.Cluster)
+			}
+			assert.ElementsMatch(t, tc.expectedNames, names)
+		})
+	}
+}
+
+func TestGetSingleDeployment(t *testing.T) {
+	optedInNamespace := &corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: "orders", Labels: map[string]string{policy.DefaultOptInKey: "true"}}}
+
+	testCases := []struct {
+		name            string
+		policy          *policy.Policy
+		payload         injectormodels.GetSingleDeploymentPayload
+		expectedErrKind error
+	}{
+		{"existing deployment", nil, injectormodels.GetSingleDeploymentPayload{Namespace: "payments", DeploymentName: "payments-api"}, nil},
+		{"deployment of opted in namespace", &policy.Policy{OptInRequired: true}, injectormodels.GetSingleDeploymentPayload{Namespace: "orders", DeploymentName: "orders-api"}, nil},
+		{"missing deployment", nil, injectormodels.GetSingleDeploymentPayload{Namespace: "payments", DeploymentName: "missing"}, ErrNotFound},
+		{"not opted in deployment", &policy.Policy{OptInRequired: true}, injectormodels.GetSingleDeploymentPayload{Namespace: "payments", DeploymentName: "payments-api"}, ErrForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.policy != nil {
+				assert.NoError(t, tc.policy.Validate())
+			}
+
+			kubeClient, _, _ := newFakeKubeClient(t, tc.policy,
+				newTestDeployment("payments", "payments-api"), newTestDeployment("orders", "orders-api"), optedInNamespace)
+
+			result, err := kubeClient.GetSingleDeployment(context.Background(), &tc.payload)
+
+			if tc.expectedErrKind != nil {
+				assert.ErrorIs(t, err, tc.expectedErrKind)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.payload.DeploymentName, result.Name)
+			assert.Equal(t, tc.payload.Namespace, result.Namespace)
+			assert.Equal(t, []string{"app"}, result.ContainerNames)
+		})
+	}
+}
+
+func TestSetSidecar(t *testing.T) {
+	kubeClient, clientset, recorder := newFakeKubeClient(t, nil, newTestDeployment("payments", "payments-api"))
+	kubeClient.caller = &auth.Identity{Username: "jane"}
+
+	result, err := kubeClient.SetSidecar(context.Background(), &injectormodels.SetSidecarPayload{
+		Namespace:            "payments",
+		DeploymentName:       "payments-api",
+		SidecarContainerName: "debug",
+		SidecarImage:         "busybox",
+		Command:              []string{"sleep", "infinity"},
+		VolumeMounts:         []injectormodels.Volume{{Name: "data", MountPath: "/data"}},
+		TTL:                  "2h",
+		Reason:               "INC-1234",
+	})
+	assert.NoError(t, err)
+
+	// Check the returned model
+	assert.Equal(t, DefaultClusterName, result.Cluster)
+	assert.Equal(t, []string{"app", "prefix-debug"}, result.ContainerNames)
+	assert.Len(t, result.InjectedSidecars, 1)
+	assert.Equal(t, "prefix-debug", result.InjectedSidecars[0].ContainerName)
+	assert.Equal(t, "jane", result.InjectedSidecars[0].InjectedBy)
+	assert.Equal(t, "INC-1234", result.InjectedSidecars[0].Reason)
+	assert.NotNil(t, result.InjectedSidecars[0].ExpiresAt)
+	assert.WithinDuration(t, result.InjectedSidecars[0].InjectedAt.Add(2*time.Hour), *result.InjectedSidecars[0].ExpiresAt, time.Second)
+
+	// Check the stored deployment
+	deployment, err := clientset.AppsV1().Deployments("payments").Get(context.Background(), "payments-api", v1.GetOptions{})
+	assert.NoError(t, err)
+
+	containers := deployment.Spec.Template.Spec.Containers
+	assert.Len(t, containers, 2)
+	assert.Equal(t, corev1.Container{
+		Name:         "prefix-debug",
+		Image:        "busybox",
+		Command:      []string{"sleep", "infinity"},
+		VolumeMounts: []corev1.VolumeMount{{Name: "data", MountPath: "/data"}},
+	}, containers[1])
+	assert.Contains(t, readInjectedSidecars(deployment), "prefix-debug")
+
+	event := <-recorder.Events
+	assert.True(t, strings.HasPrefix(event, "Normal SidecarInjected Sidecar container prefix-debug with image busybox injected by jane"), event)
+}
+
+func TestSetSidecarAcceptExisting(t *testing.T) {
+	kubeClient, clientset, recorder := newFakeKubeClient(t, nil, newTestDeployment("payments", "payments-api"))
+
+	payload := injectormodels.SetSidecarPayload{
+		Namespace:            "payments",
+		DeploymentName:       "payments-api",
+		SidecarContainerName: "debug",
+		SidecarImage:         "busybox",
+		Command:              []string{"sleep", "infinity"},
+		VolumeMounts:         []injectormodels.Volume{{Name: "data", MountPath: "/data"}},
+		TTL:                  "2h",
+		Reason:               "INC-1234",
+		AcceptExisting:       true,
+	}
+
+	_, err := kubeClient.SetSidecar(context.Background(), &payload)
+	assert.NoError(t, err)
+	assert.Len(t, recorder.Events, 1)
+	<-recorder.Events
+
+	updates := func() int {
+		count := 0
+		for _, action := range clientset.Actions() {
+			if action.GetVerb() == "update" {
+				count++
+			}
+		}
+		return count
+	}
+
+	// Check that the retry succeeds without changing the deployment
+	result, err := kubeClient.SetSidecar(context.Background(), &payload)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"app", "prefix-debug"}, result.ContainerNames)
+	assert.Equal(t, 1, updates())
+	assert.Empty(t, recorder.Events)
+
+	testCases := []struct {
+		name   string
+		change func(payload *injectormodels.SetSidecarPayload)
+	}{
+		{"other image", func(p *injectormodels.SetSidecarPayload) { p.SidecarImage = "alpine" }},
+		{"other command", func(p *injectormodels.SetSidecarPayload) { p.Command = nil }},
+		{"other volume mount", func(p *injectormodels.SetSidecarPayload) { p.VolumeMounts[0].MountPath = "/other" }},
+		{"other TTL", func(p *injectormodels.SetSidecarPayload) { p.TTL = "4h" }},
+		{"other reason", func(p *injectormodels.SetSidecarPayload) { p.Reason = "INC-5678" }},
+		{"not accepting the existing sidecar", func(p *injectormodels.SetSidecarPayload) { p.AcceptExisting = false }},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			changed := payload
+			changed.VolumeMounts = slices.Clone(payload.VolumeMounts)
+			tc.change(&changed)
+
+			// Check that a sidecar differing from the existing one is a conflict
+			_, err := kubeClient.SetSidecar(context.Background(), &changed)
+			assert.ErrorIs(t, err, ErrConflict)
+			assert.Equal(t, 1, updates())
+			<-recorder.Events
+		})
+	}
+}
+
+func TestSetSidecarErrors(t *testing.T) {
+	existingSidecar := newTestDeployment("payments", "payments-api")
+	existingSidecar.Spec.Template.Spec.Containers = append(existingSidecar.Spec.Template.Spec.Containers, corev1.Container{Name: "prefix-debug", Image: "busybox"})
+
+	validPayload := injectormodels.SetSidecarPayload{Namespace: "payments", DeploymentName: "payments-api", SidecarContainerName: "debug", SidecarImage: "busybox"}
+
+	withChanges := func(change func(payload *injectormodels.SetSidecarPayload)) injectormodels.SetSidecarPayload {
+		payload := validPayload
+		change(&payload)
+		return payload
+	}
+
+	testCases := []struct {
+		name            string
+		deployment      *appsv1.Deployment
+		policy          *policy.Policy
+		payload         injectormodels.SetSidecarPayload
+		expectedErrKind error
+		expectedEvent   bool
+	}{
+		{"missing image", newTestDeployment("payments", "payments-api"), nil, withChanges(func(p *injectormodels.SetSidecarPayload) { p.SidecarImage = "" }), ErrInvalid, false},
+		{"invalid TTL", newTestDeployment("payments", "payments-api"), nil, withChanges(func(p *injectormodels.SetSidecarPayload) { p.TTL = "soon" }), ErrInvalid, false},
+		{"negative TTL", newTestDeployment("payments", "payments-api"), nil, withChanges(func(p *injectormodels.SetSidecarPayload) { p.TTL = "-1h" }), ErrInvalid, false},
+		{"unknown volume", newTestDeployment("payments", "payments-api"), nil, withChanges(func(p *injectormodels.SetSidecarPayload) {
+			p.VolumeMounts = []injectormodels.Volume{{Name: "missing", MountPath: "/missing"}}
+		}), ErrInvalid, true},
+		{"sidecar already injected", existingSidecar, nil, validPayload, ErrConflict, true},
+		{"missing deployment", newTestDeployment("payments", "payments-worker"), nil, validPayload, ErrNotFound, false},
+		{"denied namespace", newTestDeployment("payments", "payments-api"), &policy.Policy{DeniedNamespaces: []string{"payments"}}, validPayload, ErrForbidden, false},
+		{"not opted in deployment", newTestDeployment("payments", "payments-api"), &policy.Policy{OptInRequired: true}, validPayload, ErrForbidden, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.policy != nil {
+				assert.NoError(t, tc.policy.Validate())
+			}
+
+			kubeClient, clientset, recorder := newFakeKubeClient(t, tc.policy, tc.deployment)
+
+			_, err := kubeClient.SetSidecar(context.Background(), &tc.payload)
+			assert.ErrorIs(t, err, tc.expectedErrKind)
+
+			// Check that the deployment has not been updated
+			for _, action := range clientset.Actions() {
+				assert.NotEqual(t, "update", action.GetVerb())
+			}
+
+			if tc.expectedEvent {
+				event := <-recorder.Events
+				assert.True(t, strings.HasPrefix(event, "Warning SidecarFailed"), event)
+			}
+			assert.Empty(t, recorder.Events)
+		})
+	}
+}
+
+func TestSetSidecarTTLWithoutExpiration(t *testing.T) {
+	clientset := fake.NewSimpleClientset(newTestDeployment("payments", "payments-api"))
+
+	kubeClient, err := NewForClientset(logging.New(), clientset, Options{SidecarNamePrefix: "prefix"})
+	assert.NoError(t, err)
+	t.Cleanup(kubeClient.(*KubeClient).clusters[DefaultClusterName].broadcaster.Shutdown)
+
+	// Check that a TTL is refused when the expired sidecars are not removed, by every injection
+	_, err = kubeClient.SetSidecar(context.Background(), &injectormodels.SetSidecarPayload{Namespace: "payments", DeploymentName: "payments-api", SidecarContainerName: "debug", SidecarImage: "busybox", TTL: "2h"})
+	assert.ErrorIs(t, err, ErrInvalid)
+	assert.ErrorContains(t, err, "TTL not accepted, the removal of the expired sidecars being disabled")
+
+	_, err = kubeClient.BulkSetSidecar(context.Background(), &injectormodels.BulkSetSidecarPayload{Namespaces: []string{"payments"}, SidecarContainerName: "debug", SidecarImage: "busybox", TTL: "2h"})
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	for _, action := range clientset.Actions() {
+		assert.NotEqual(t, "update", action.GetVerb())
+	}
+
+	// Check that the injections without TTL are accepted
+	_, err = kubeClient.SetSidecar(context.Background(), &injectormodels.SetSidecarPayload{Namespace: "payments", DeploymentName: "payments-api", SidecarContainerName: "debug", SidecarImage: "busybox"})
+	assert.NoError(t, err)
+}
+
+func TestSetSidecarUpdateConflict(t *testing.T) {
+	kubeClient, clientset, recorder := newFakeKubeClient(t, nil, newTestDeployment("payments", "payments-api"))
+
+	// simulate a concurrent change of the deployment
+	clientset.PrependReactor("update", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewConflict(schema.GroupResource{Group: "apps", Resource: "deployments"}, "payments-api", errors.New("the object has been modified"))
+	})
+
+	_, err := kubeClient.SetSidecar(context.Background(), &injectormodels.SetSidecarPayload{Namespace: "payments", DeploymentName: "payments-api", SidecarContainerName: "debug", SidecarImage: "busybox"})
+	assert.ErrorIs(t, err, ErrConflict)
+
+	event := <-recorder.Events
+	assert.True(t, strings.HasPrefix(event, "Warning SidecarFailed"), event)
+}
+
+// simulateDryRun makes the fake clientset answer dry-run updates like the API server, returning the object without persisting it
+func simulateDryRun(clientset *fake.Clientset) {
+	clientset.PrependReactor("update", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		update := action.(k8stesting.UpdateActionImpl)
+		if len(update.GetUpdateOptions().DryRun) == 0 {
+			return false, nil, nil
+		}
+		return true, update.GetObject(), nil
+	})
+}
+
+func TestSetSidecarDryRun(t *testing.T) {
+	kubeClient, clientset, recorder := newFakeKubeClient(t, nil, newTestDeployment("payments", "payments-api"))
+	simulateDryRun(clientset)
+
+	result, err := kubeClient.SetSidecar(context.Background(), &injectormodels.SetSidecarPayload{Namespace: "payments", DeploymentName: "payments-api", SidecarContainerName: "debug", SidecarImage: "busybox", DryRun: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"app", "prefix-debug"}, result.ContainerNames)
+	if assert.NotNil(t, result.DryRun) {
+		assert.Contains(t, result.DryRun.Diff, "--- current\n+++ dry-run\n")
+		assert.Contains(t, result.DryRun.Diff, "+  - image: busybox\n")
+		assert.Contains(t, result.DryRun.Diff, "+    name: prefix-debug\n")
+		assert.IsType(t, corev1.PodTemplateSpec{}, result.DryRun.PodTemplate)
+	}
+
+	// Check nothing was persisted
+	stored, err := clientset.AppsV1().Deployments("payments").Get(context.Background(), "payments-api", v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, stored.Spec.Template.Spec.Containers, 1)
+	assert.NotContains(t, stored.Annotations, SidecarsAnnotation)
+
+	// Check the update was sent as a dry run
+	var dryRunUpdates int
+	for _, action := range clientset.Actions() {
+		if update, ok := action.(k8stesting.UpdateActionImpl); ok {
+			assert.Equal(t, []string{v1.DryRunAll}, update.GetUpdateOptions().DryRun)
+			dryRunUpdates++
+		}
+	}
+	assert.Equal(t, 1, dryRunUpdates)
+
+	assert.Empty(t, recorder.Events)
+}
+
+func TestClearSidecarDryRun(t *testing.T) {
+	deployment := newTestDeployment("payments", "payments-api")
+	deployment.Spec.Template.Spec.Containers = append(deployment.Spec.Template.Spec.Containers, corev1.Container{Name: "prefix-debug", Image: "busybox"})
+
+	kubeClient, clientset, recorder := newFakeKubeClient(t, nil, deployment)
+	simulateDryRun(clientset)
+
+	result, err := kubeClient.ClearSidecar(context.Background(), &injectormodels.ClearSidecarPayload{Namespace: "payments", DeploymentName: "payments-api", SidecarContainerName: "debug", DryRun: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"app"}, result.ContainerNames)
+	if assert.NotNil(t, result.DryRun) {
+		assert.Contains(t, result.DryRun.Diff, "-    name: prefix-debug\n")
+	}
+
+	// Check nothing was persisted
+	stored, err := clientset.AppsV1().Deployments("payments").Get(context.Background(), "payments-api", v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, stored.Spec.Template.Spec.Containers, 2)
+
+	assert.Empty(t, recorder.Events)
+}
+
+func TestClearSidecar(t *testing.T) {
+	deployment := newTestDeployment("payments", "payments-api")
+	deployment.Spec.Template.Spec.Containers = append(deployment.Spec.Template.Spec.Containers, corev1.Container{Name: "prefix-debug", Image: "busybox"})
+	assert.NoError(t, writeInjectedSidecars(deployment, map[string]injectormodels.InjectedSidecar{
+		"prefix-debug": {ContainerName: "prefix-debug", Image: "busybox", InjectedBy: "jane"},
+	}))
+
+	kubeClient, clientset, recorder := newFakeKubeClient(t, nil, deployment)
+
+	result, err := kubeClient.ClearSidecar(context.Background(), &injectormodels.ClearSidecarPayload{Namespace: "payments", DeploymentName: "payments-api", SidecarContainerName: "debug"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"app"}, result.ContainerNames)
+	assert.Empty(t, result.InjectedSidecars)
+
+	// Check the stored deployment
+	stored, err := clientset.AppsV1().Deployments("payments").Get(context.Background(), "payments-api", v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, stored.Spec.Template.Spec.Containers, 1)
+	assert.NotContains(t, stored.Annotations, SidecarsAnnotation)
+
+	event := <-recorder.Events
+	assert.True(t, strings.HasPrefix(event, "Normal SidecarRemoved Sidecar container prefix-debug with image busybox removed by api-key"), event)
+}
+
+func TestClearSidecarErrors(t *testing.T) {
+	testCases := []struct {
+		name            string
+		payload         injectormodels.ClearSidecarPayload
+		expectedErrKind error
+		expectedEvent   bool
+	}{
+		{"missing sidecar name", injectormodels.ClearSidecarPayload{Namespace: "payments", DeploymentName: "payments-api"}, ErrInvalid, false},
+		{"unknown sidecar", injectormodels.ClearSidecarPayload{Namespace: "payments", DeploymentName: "payments-api", SidecarContainerName: "debug"}, ErrNotFound, true},
+		{"missing deployment", injectormodels.ClearSidecarPayload{Namespace: "payments", DeploymentName: "payments-worker", SidecarContainerName: "debug"}, ErrNotFound, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			kubeClient, _, recorder := newFakeKubeClient(t, nil, newTestDeployment("payments", "payments-api"))
+
+			_, err := kubeClient.ClearSidecar(context.Background(), &tc.payload)
+			assert.ErrorIs(t, err, tc.expectedErrKind)
+
+			if tc.expectedEvent {
+				event := <-recorder.Events
+				assert.True(t, strings.HasPrefix(event, "Warning SidecarFailed"), event)
+			}
+			assert.Empty(t, recorder.Events)
+		})
+	}
+}
+
+func TestClearSidecarTracing(t *testing.T) {
+	spanRecorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	kubeClient, _, _ := newFakeKubeClient(t, nil, newTestDeployment("payments", "payments-api"))
+
+	_, err := kubeClient.ClearSidecar(context.Background(), &injectormodels.ClearSidecarPayload{Namespace: "payments", DeploymentName: "payments-api", SidecarContainerName: "debug"})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Check that the call is traced with its target, and that the classified error is recorded
+	spans := spanRecorder.Ended()
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, "KubeClient.ClearSidecar", spans[0].Name())
+		assert.Contains(t, spans[0].Attributes(), attribute.String("k8s.deployment.name", "payments-api"))
+		assert.Equal(t, codes.Error, spans[0].Status().Code)
+	}
+}
+
+func TestSetPolicy(t *testing.T) {
+	kubeClient, _, _ := newFakeKubeClient(t, nil, newTestDeployment("payments", "payments-api"))
+
+	bound, err := kubeClient.ForCaller(&auth.Identity{Username: "jane"})
+	assert.NoError(t, err)
+
+	payload := injectormodels.GetDeploymentsPayload{Namespace: "payments"}
+
+	_, err = bound.GetDeployments(context.Background(), &payload)
+	assert.NoError(t, err)
+
+	kubeClient.SetPolicy(&policy.Policy{DeniedNamespaces: []string{"payments"}})
+
+	// Check that the policy is replaced for the clients bound to a caller too
+	_, err = bound.GetDeployments(context.Background(), &payload)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	kubeClient.SetPolicy(nil)
+
+	_, err = bound.GetDeployments(context.Background(), &payload)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,359 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/homedir"
+
+	clustermodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/clusters"
+)
+
+// InClusterName names the cluster the injector runs on, when deployed in Kubernetes
+const InClusterName = "in-cluster"
+
+// DefaultClusterName names the cluster of a KubeClient created for a clientset, if not given by the options
+const DefaultClusterName = "default"
+
+// AllContexts, as the only KubeconfigContexts item, loads every context of the kubeconfig file
+const AllContexts = "*"
+
+const clusterHealthTimeout = 5 * time.Second
+
+var ErrClusterNotFound = withKind(ErrNotFound, errors.New("cluster not found"))
+
+type cluster struct {
+	name        string
+	config      *rest.Config // nil when created for a clientset
+	clientset   kubernetes.Interface
+	broadcaster record.EventBroadcaster
+	recorder    record.EventRecorder
+	// nil when caching is disabled
+	cache *deploymentCache
+}
+
+func newCluster(name string, config *rest.Config, cacheEnabled bool) (*cluster, error) {
+
+	// every API server call is a span, child of the span of the operation
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return otelhttp.NewTransport(rt, otelhttp.WithSpanNameFormatter(func(_ string, request *http.Request) string {
+			return "Kubernetes API " + request.Method
+		}))
+	})
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("cluster %s: %w", name, err)
+	}
+
+	return newClusterForClientset(name, config, clientset, cacheEnabled), nil
+}
+
+func newClusterForClientset(name string, config *rest.Config, clientset kubernetes.Interface, cacheEnabled bool) *cluster {
+
+	// events are always recorded by the injector itself, even when impersonating the caller
+	broadcaster, recorder := newEventRecorder(clientset)
+
+	target := &cluster{
+		name:        name,
+		config:      config,
+		clientset:   clientset,
+		broadcaster: broadcaster,
+		recorder:    recorder,
+	}
+
+	if cacheEnabled {
+		target.cache = newDeploymentCache(clientset)
+	}
+
+	return target
+}
+
+// host returns the address of the API server of the cluster, if known
+func (target *cluster) host() string {
+
+	if target.config == nil {
+		return ""
+	}
+
+	return target.config.Host
+}
+
+// onCluster returns a copy of the client bound to the named cluster, or to the default one when name is empty.
+// With impersonation enabled and a known caller, the bound clientset impersonates the caller user and groups,
+// so that API server RBAC and audit logs reflect the real person
+func (kc *KubeClient) onCluster(name string) (*KubeClient, error) {
+
+	target, err := kc.cluster(name)
+	if err != nil {
+		return nil, err
+	}
+
+	bound := *kc
+	bound.clusterName = target.name
+	bound.config = target.config
+	bound.clientset = target.clientset
+	bound.ownClientset = target.clientset
+	bound.recorder = target.recorder
+	bound.cache = target.cache
+
+	if !kc.impersonate || kc.caller == nil {
+		return &bound, nil
+	}
+
+	// the cache is filled by the injector itself, so reads must go to the API server to enforce the caller RBAC
+	bound.cache = nil
+
+	if target.config == nil {
+		return nil, fmt.Errorf("cluster %s: impersonation requires the rest config of the cluster", target.name)
+	}
+
+	config := rest.CopyConfig(target.config)
+	config.Impersonate = rest.ImpersonationConfig{
+		UserName: kc.caller.Username,
+		UID:      kc.caller.UID,
+		Groups:   kc.caller.Groups,
+		Extra:    kc.caller.Extra,
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	kc.logger.Log().Info("Impersonating caller", zap.String("cluster", target.name), zap.String("username", kc.caller.Username), zap.Strings("groups", kc.caller.Groups))
+
+	bound.config = config
+	bound.clientset = clientset
+
+	return &bound, nil
+}
+
+// cluster returns the named cluster, or the default one when name is empty
+func (kc *KubeClient) cluster(name string) (*cluster, error) {
+
+	if name == "" {
+		name = kc.defaultCluster
+	}
+
+	target, found := kc.clusters[name]
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrClusterNotFound, name)
+	}
+
+	return target, nil
+}
+
+// DefaultClientset returns the clientset of the injector itself on the default cluster, never impersonating the caller,
+// as for the coordination of the replicas
+func (kc *KubeClient) DefaultClientset() kubernetes.Interface {
+	return kc.clusters[kc.defaultCluster].clientset
+}
+
+// ListClusters returns the configured clusters along with their health, checked concurrently
+func (kc *KubeClient) ListClusters(ctx context.Context) []clustermodels.Cluster {
+
+	result := make([]clustermodels.Cluster, len(kc.clusterNames))
+
+	var wg sync.WaitGroup
+	for i, name := range kc.clusterNames {
+		wg.Add(1)
+		go func(i int, target *cluster) {
+			defer wg.Done()
+			result[i] = kc.checkCluster(ctx, target, clusterHealthTimeout)
+		}(i, kc.clusters[name])
+	}
+	wg.Wait()
+
+	return result
+}
+
+// CheckDefaultCluster returns the default cluster along with its health, checked within the timeout
+func (kc *KubeClient) CheckDefaultCluster(ctx context.Context, timeout time.Duration) clustermodels.Cluster {
+	return kc.checkCluster(ctx, kc.clusters[kc.defaultCluster], timeout)
+}
+
+// checkCluster reports whether the API server of the cluster is reachable within the timeout and its version
+func (kc *KubeClient) checkCluster(ctx context.Context, target *cluster, timeout time.Duration) clustermodels.Cluster {
+
+	status := clustermodels.Cluster{
+		Name:    target.name,
+		Default: target.name == kc.defaultCluster,
+		Host:    target.host(),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	info, err := target.serverVersion(ctx)
+	if err != nil {
+		kc.logger.Log().Warn("Cluster not reachable", zap.String("cluster", target.name), zap.Error(err))
+		status.Error = err.Error()
+		return status
+	}
+
+	status.Reachable = true
+	status.Version = info.GitVersion
+
+	return status
+}
+
+// serverVersion gets the version of the API server of the cluster
+func (target *cluster) serverVersion(ctx context.Context) (*version.Info, error) {
+
+	if target.config == nil {
+		// created for a clientset, whose REST client may not be available
+		return target.clientset.Discovery().ServerVersion()
+	}
+
+	body, err := target.clientset.Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Raw()
+	if err != nil {
+		return nil, err
+	}
+
+	var info version.Info
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// loadClusterConfigs returns the rest configs of the clusters the injector can act on, keyed by name, and the default cluster name.
+// The clusters are: the cluster the injector runs on, if any; the KubeconfigContexts of the kubeconfig file,
+// or its current context when running outside Kubernetes with no other source; one cluster for each kubeconfig file in KubeconfigDir
+func loadClusterConfigs(options *Options) (configs map[string]*rest.Config, defaultName string, err error) {
+
+	configs = make(map[string]*rest.Config)
+
+	inClusterConfig, inClusterErr := rest.InClusterConfig()
+	if inClusterErr == nil {
+		configs[InClusterName] = inClusterConfig
+		defaultName = InClusterName
+	}
+
+	if len(options.KubeconfigContexts) > 0 || (inClusterErr != nil && options.KubeconfigDir == "") {
+
+		rawConfig, loadErr := clientcmd.LoadFromFile(kubeconfigPath(options.Kubeconfig))
+		if loadErr != nil {
+			err = loadErr
+			return
+		}
+
+		contexts := options.KubeconfigContexts
+		if len(contexts) == 0 {
+			// use the current context in kubeconfig
+			contexts = []string{rawConfig.CurrentContext}
+			defaultName = rawConfig.CurrentContext
+		} else if len(contexts) == 1 && contexts[0] == AllContexts {
+			contexts = make([]string, 0, len(rawConfig.Contexts))
+			for name := range rawConfig.Contexts {
+				contexts = append(contexts, name)
+			}
+		}
+
+		for _, contextName := range contexts {
+			configs[contextName], err = contextConfig(rawConfig, contextName)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	if options.KubeconfigDir != "" {
+
+		entries, readErr := os.ReadDir(options.KubeconfigDir)
+		if readErr != nil {
+			err = readErr
+			return
+		}
+
+		for _, entry := range entries {
+			// skip folders and the hidden entries, like the ones of mounted secrets
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+
+			rawConfig, loadErr := clientcmd.LoadFromFile(filepath.Join(options.KubeconfigDir, entry.Name()))
+			if loadErr != nil {
+				err = fmt.Errorf("kubeconfig %s: %w", entry.Name(), loadErr)
+				return
+			}
+
+			name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+			configs[name], err = contextConfig(rawConfig, rawConfig.CurrentContext)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	if len(configs) == 0 {
+		err = errors.New("no cluster configured")
+		return
+	}
+
+	if options.DefaultCluster != "" {
+		if _, found := configs[options.DefaultCluster]; !found {
+			err = fmt.Errorf("%w: default cluster %s", ErrClusterNotFound, options.DefaultCluster)
+			return
+		}
+		defaultName = options.DefaultCluster
+	}
+
+	if defaultName == "" {
+		defaultName = sortedNames(configs)[0]
+	}
+
+	return
+}
+
+// contextConfig returns the rest config of the named context of the kubeconfig
+func contextConfig(rawConfig *clientcmdapi.Config, contextName string) (*rest.Config, error) {
+
+	if _, found := rawConfig.Contexts[contextName]; !found {
+		return nil, fmt.Errorf("%w: context %s not found in kubeconfig", ErrClusterNotFound, contextName)
+	}
+
+	return clientcmd.NewNonInteractiveClientConfig(*rawConfig, contextName, &clientcmd.ConfigOverrides{}, nil).ClientConfig()
+}
+
+// kubeconfigPath returns the given kubeconfig file, or the one in the home folder when empty
+func kubeconfigPath(kubeconfig string) string {
+
+	if kubeconfig == "" {
+		if home := homedir.HomeDir(); home != "" {
+			kubeconfig = filepath.Join(home, ".kube", "config")
+		}
+	}
+
+	return kubeconfig
+}
+
+func sortedNames[T any](items map[string]T) []string {
+
+	names := make([]string, 0, len(items))
+	for name := range items {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
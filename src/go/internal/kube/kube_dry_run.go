@@ -0,0 +1,57 @@
+package kube
+
+import (
+	"context"
+
+	"github.com/pmezard/go-difflib/difflib"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	injectormodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/injector"
+)
+
+// previewUpdate performs a server-side dry-run update of the changed deployment and returns the resulting one,
+// along with the preview of its pod template compared with the one of the current deployment
+func (kc *KubeClient) previewUpdate(ctx context.Context, current *appsv1.Deployment, changed *appsv1.Deployment) (result injectormodels.Deployment, err error) {
+
+	updated, err := kc.clientset.AppsV1().Deployments(changed.Namespace).Update(ctx, changed, v1.UpdateOptions{DryRun: []string{v1.DryRunAll}})
+	if err != nil {
+		return
+	}
+
+	diff, err := podTemplateDiff(current, updated)
+	if err != nil {
+		return
+	}
+
+	result = convertToInternalModel(kc.clusterName, *updated)
+	result.DryRun = &injectormodels.DryRunPreview{
+		PodTemplate: updated.Spec.Template,
+		Diff:        diff,
+	}
+
+	return
+}
+
+// podTemplateDiff returns the unified diff of the pod templates of the deployments, in YAML
+func podTemplateDiff(current *appsv1.Deployment, updated *appsv1.Deployment) (string, error) {
+
+	currentYaml, err := yaml.Marshal(current.Spec.Template)
+	if err != nil {
+		return "", err
+	}
+
+	updatedYaml, err := yaml.Marshal(updated.Spec.Template)
+	if err != nil {
+		return "", err
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(currentYaml)),
+		B:        difflib.SplitLines(string(updatedYaml)),
+		FromFile: "current",
+		ToFile:   "dry-run",
+		Context:  3,
+	})
+}
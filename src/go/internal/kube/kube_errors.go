@@ -0,0 +1,81 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Kinds of the errors returned by the KubeClient, to be checked with errors.Is
+var (
+	// the request is not valid, like a missing field or a volume not found in the deployment
+	ErrInvalid = errors.New("invalid request")
+	// the deployment, the container or the cluster do not exist
+	ErrNotFound = errors.New("not found")
+	// the policy or the Kubernetes RBAC forbid the operation
+	ErrForbidden = errors.New("forbidden")
+	// the operation conflicts with the current state, like a sidecar already injected
+	ErrConflict = errors.New("conflict")
+	// the Kubernetes API server cannot be reached or is overloaded
+	ErrUnavailable = errors.New("cluster unavailable")
+)
+
+var errorKinds = []error{ErrInvalid, ErrNotFound, ErrForbidden, ErrConflict, ErrUnavailable}
+
+// kindError classifies an error with one of the kinds, keeping its message
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// withKind classifies the error with the given kind
+func withKind(kind error, err error) error {
+	return &kindError{kind: kind, err: err}
+}
+
+// invalidError returns an ErrInvalid error with the given message
+func invalidError(message string) error {
+	return withKind(ErrInvalid, errors.New(message))
+}
+
+// classifyError classifies the errors of the Kubernetes API server, leaving untouched the ones already classified
+func classifyError(err error) error {
+
+	if err == nil {
+		return nil
+	}
+
+	for _, kind := range errorKinds {
+		if errors.Is(err, kind) {
+			return err
+		}
+	}
+
+	var netErr net.Error
+
+	switch {
+	case apierrors.IsNotFound(err):
+		return withKind(ErrNotFound, err)
+	case apierrors.IsForbidden(err):
+		return withKind(ErrForbidden, err)
+	case apierrors.IsConflict(err), apierrors.IsAlreadyExists(err):
+		return withKind(ErrConflict, err)
+	case apierrors.IsInvalid(err), apierrors.IsBadRequest(err):
+		return withKind(ErrInvalid, err)
+	case apierrors.IsServiceUnavailable(err), apierrors.IsServerTimeout(err), apierrors.IsTimeout(err), apierrors.IsTooManyRequests(err),
+		errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr):
+		return withKind(ErrUnavailable, err)
+	}
+
+	return err
+}
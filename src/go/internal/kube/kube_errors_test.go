@@ -0,0 +1,50 @@
+package kube
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/policy"
+)
+
+func TestClassifyError(t *testing.T) {
+	deployments := schema.GroupResource{Group: "apps", Resource: "deployments"}
+	policyErr := (&policy.Policy{DeniedNamespaces: []string{"kube-system"}}).CheckNamespace("kube-system")
+
+	testCases := []struct {
+		name         string
+		err          error
+		expectedKind error
+	}{
+		{"not found", apierrors.NewNotFound(deployments, "payments-api"), ErrNotFound},
+		{"forbidden", apierrors.NewForbidden(deployments, "payments-api", errors.New("rbac")), ErrForbidden},
+		{"conflict", apierrors.NewConflict(deployments, "payments-api", errors.New("modified")), ErrConflict},
+		{"invalid", apierrors.NewBadRequest("bad container name"), ErrInvalid},
+		{"unavailable", apierrors.NewServiceUnavailable("overloaded"), ErrUnavailable},
+		{"unreachable", &url.Error{Op: "Get", URL: "https://kubernetes.example:6443", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}, ErrUnavailable},
+		{"already classified", withKind(ErrForbidden, policyErr), ErrForbidden},
+		{"cluster not found", ErrClusterNotFound, ErrNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := classifyError(tc.err)
+
+			assert.ErrorIs(t, result, tc.expectedKind)
+			assert.ErrorIs(t, result, tc.err)
+			assert.Equal(t, tc.err.Error(), result.Error())
+		})
+	}
+
+	assert.Nil(t, classifyError(nil))
+
+	// unknown errors are left untouched
+	unknownErr := errors.New("unknown")
+	assert.Same(t, unknownErr, classifyError(unknownErr))
+}
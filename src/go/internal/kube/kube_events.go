@@ -0,0 +1,92 @@
+package kube
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	webhooksmodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/webhooks"
+)
+
+// Reasons of the Events recorded on the target Deployment along the sidecar lifecycle
+const (
+	EventReasonSidecarInjected = "SidecarInjected"
+	EventReasonSidecarRemoved  = "SidecarRemoved"
+	EventReasonSidecarExpired  = "SidecarExpired"
+	EventReasonSidecarRollback = "SidecarRollback"
+	EventReasonSidecarFailed   = "SidecarFailed"
+)
+
+const eventComponent = "kube-ondemand-sidecar-injector"
+
+// newEventRecorder creates an event recorder writing Events through the given clientset
+func newEventRecorder(clientset kubernetes.Interface) (record.EventBroadcaster, record.EventRecorder) {
+
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+
+	recorder := broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: eventComponent})
+
+	return broadcaster, recorder
+}
+
+func (kc *KubeClient) recordSidecarInjected(deployment *appsv1.Deployment, containerName string, image string) {
+	kc.recordEvent(deployment, corev1.EventTypeNormal, EventReasonSidecarInjected, containerName, image,
+		fmt.Sprintf("Sidecar container %s with image %s injected by %s", containerName, image, kc.callerName()))
+}
+
+func (kc *KubeClient) recordSidecarRemoved(deployment *appsv1.Deployment, containerName string, image string) {
+	kc.recordEvent(deployment, corev1.EventTypeNormal, EventReasonSidecarRemoved, containerName, image,
+		fmt.Sprintf("Sidecar container %s with image %s removed by %s", containerName, image, kc.callerName()))
+}
+
+func (kc *KubeClient) recordSidecarExpired(deployment *appsv1.Deployment, containerName string, image string) {
+	kc.recordEvent(deployment, corev1.EventTypeNormal, EventReasonSidecarExpired, containerName, image,
+		fmt.Sprintf("Sidecar container %s with image %s removed on TTL expiration", containerName, image))
+}
+
+func (kc *KubeClient) recordSidecarRollback(deployment *appsv1.Deployment, message string) {
+	kc.recordEvent(deployment, corev1.EventTypeNormal, EventReasonSidecarRollback, "", "",
+		fmt.Sprintf("%s by %s", message, kc.callerName()))
+}
+
+func (kc *KubeClient) recordSidecarFailed(deployment *appsv1.Deployment, operation string, containerName string, image string, err error) {
+	kc.recordEvent(deployment, corev1.EventTypeWarning, EventReasonSidecarFailed, containerName, image,
+		fmt.Sprintf("%s of sidecar container %s requested by %s failed: %v", operation, containerName, kc.callerName(), err))
+}
+
+// recordEvent records an Event on the deployment, annotated with caller identity and sidecar image,
+// counts it and notifies it to the webhooks. Without a recorder, as on dry runs, nothing is recorded nor notified
+func (kc *KubeClient) recordEvent(deployment *appsv1.Deployment, eventType string, reason string, containerName string, image string, message string) {
+
+	if kc.recorder == nil || deployment == nil {
+		return
+	}
+
+	annotations := map[string]string{
+		annotationPrefix + "caller": kc.callerName(),
+	}
+	if image != "" {
+		annotations[annotationPrefix+"image"] = image
+	}
+
+	kc.recorder.AnnotatedEventf(deployment, annotations, eventType, reason, "%s", message)
+
+	kc.metrics.ObserveSidecarEvent(kc.clusterName, reason)
+
+	kc.notifier.Notify(&webhooksmodels.Event{
+		Type:           reason,
+		Cluster:        kc.clusterName,
+		Namespace:      deployment.Namespace,
+		DeploymentName: deployment.Name,
+		ContainerName:  containerName,
+		Image:          image,
+		Caller:         kc.callerName(),
+		Message:        message,
+	})
+}
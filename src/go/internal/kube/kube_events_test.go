@@ -0,0 +1,101 @@
+package kube
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/auth"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+	webhooksmodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/webhooks"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/webhooks"
+)
+
+func TestRecordLifecycleEvents(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	kubeClient := &KubeClient{
+		logger:   logging.New(),
+		caller:   &auth.Identity{Username: "jane"},
+		recorder: recorder,
+	}
+	deployment := &appsv1.Deployment{ObjectMeta: v1.ObjectMeta{Name: "payments-api", Namespace: "payments"}}
+
+	kubeClient.recordSidecarInjected(deployment, "prefix-debug", "busybox")
+	kubeClient.recordSidecarRemoved(deployment, "prefix-debug", "busybox")
+	kubeClient.recordSidecarExpired(deployment, "prefix-debug", "busybox")
+	kubeClient.recordSidecarRollback(deployment, "Pod template restored to revision 2")
+	kubeClient.recordSidecarFailed(deployment, "Injection", "prefix-debug", "busybox", assert.AnError)
+
+	expectedEvents := []string{
+		"Normal SidecarInjected Sidecar container prefix-debug with image busybox injected by jane",
+		"Normal SidecarRemoved Sidecar container prefix-debug with image busybox removed by jane",
+		"Normal SidecarExpired Sidecar container prefix-debug with image busybox removed on TTL expiration",
+		"Normal SidecarRollback Pod template restored to revision 2 by jane",
+		"Warning SidecarFailed Injection of sidecar container prefix-debug requested by jane failed: " + assert.AnError.Error(),
+	}
+
+	// the fake recorder appends the annotations, holding caller and image, to the event message
+	for _, expectedEvent := range expectedEvents {
+		event := <-recorder.Events
+		assert.True(t, strings.HasPrefix(event, expectedEvent), event)
+		assert.Contains(t, event, annotationPrefix+"caller:jane")
+	}
+}
+
+func TestRecordEventNotifiesWebhooks(t *testing.T) {
+	received := make(chan webhooksmodels.Event, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event webhooksmodels.Event
+		json.NewDecoder(r.Body).Decode(&event)
+		received <- event
+	}))
+	defer server.Close()
+
+	notifier, err := webhooks.New(logging.New(), webhooks.Options{Webhooks: []webhooks.Webhook{{Name: "tickets", URL: server.URL}}})
+	assert.NoError(t, err)
+
+	kubeClient := &KubeClient{
+		logger:      logging.New(),
+		caller:      &auth.Identity{Username: "jane"},
+		clusterName: "production",
+		recorder:    record.NewFakeRecorder(10),
+		notifier:    notifier,
+	}
+	deployment := &appsv1.Deployment{ObjectMeta: v1.ObjectMeta{Name: "payments-api", Namespace: "payments"}}
+
+	kubeClient.recordSidecarExpired(deployment, "prefix-debug", "busybox")
+	notifier.Wait()
+
+	event := <-received
+	assert.Equal(t, EventReasonSidecarExpired, event.Type)
+	assert.Equal(t, "production", event.Cluster)
+	assert.Equal(t, "payments", event.Namespace)
+	assert.Equal(t, "payments-api", event.DeploymentName)
+	assert.Equal(t, "prefix-debug", event.ContainerName)
+	assert.Equal(t, "busybox", event.Image)
+	assert.Equal(t, "jane", event.Caller)
+	assert.Equal(t, "Sidecar container prefix-debug with image busybox removed on TTL expiration", event.Message)
+
+	// Check that nothing is notified without a recorder, as on dry runs
+	kubeClient.recorder = nil
+	kubeClient.recordSidecarInjected(deployment, "prefix-debug", "busybox")
+	notifier.Wait()
+
+	assert.Len(t, notifier.Deliveries(0), 1)
+}
+
+func TestRecordEventWithoutRecorder(t *testing.T) {
+	kubeClient := &KubeClient{logger: logging.New()}
+
+	// Check that recording is a no-op when no recorder is configured
+	assert.NotPanics(t, func() {
+		kubeClient.recordSidecarInjected(&appsv1.Deployment{}, "prefix-debug", "busybox")
+	})
+}
@@ -0,0 +1,279 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	injectormodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/injector"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/tracing"
+)
+
+// maxHistorySize bounds the size of the history annotation, well below the 256KiB allowed for all the annotations of an object
+const maxHistorySize = 128 * 1024
+
+// historySnapshot is a snapshot of the pod template, as recorded in the history annotation
+type historySnapshot struct {
+	Revision      int       `json:"Revision"`
+	Time          time.Time `json:"Time"`
+	Operation     string    `json:"Operation"`
+	ContainerName string    `json:"ContainerName,omitempty"`
+	PerformedBy   string    `json:"PerformedBy"`
+	// value of the sidecars annotation at the time of the snapshot
+	Sidecars string                 `json:"Sidecars,omitempty"`
+	Template corev1.PodTemplateSpec `json:"Template"`
+}
+
+// GetHistory returns the snapshots of the pod template of the deployment, from the oldest
+func (kc *KubeClient) GetHistory(ctx context.Context, payload *injectormodels.GetHistoryPayload) (result []injectormodels.HistoryEntry, err error) {
+
+	defer func() { err = classifyError(err) }()
+
+	ctx, span := tracing.Start(ctx, "KubeClient.GetHistory", tracing.Target(payload.Cluster, payload.Namespace, payload.DeploymentName)...)
+	defer func() { tracing.End(span, err) }()
+
+	ctx, cancel := kc.withTimeout(ctx)
+	defer cancel()
+
+	if payload.Namespace == "" {
+		err = invalidError("namespace is required")
+		return
+	}
+
+	if payload.DeploymentName == "" {
+		err = invalidError("DeploymentName is required")
+		return
+	}
+
+	err = kc.checkNamespace(payload.Namespace)
+	if err != nil {
+		return
+	}
+
+	kc, err = kc.onCluster(payload.Cluster)
+	if err != nil {
+		return
+	}
+
+	deployment, err := kc.getDeployment(ctx, payload.Namespace, payload.DeploymentName, payload.Consistent)
+	if err != nil {
+		return
+	}
+
+	err = kc.checkOptIn(ctx, deployment)
+	if err != nil {
+		return
+	}
+
+	history := readHistory(deployment)
+
+	result = make([]injectormodels.HistoryEntry, len(history))
+	for i, snapshot := range history {
+		result[i] = historyEntryOf(snapshot)
+	}
+
+	return
+}
+
+// RestoreSnapshot restores the pod template, along with the injected sidecars metadata, of the given revision of the history.
+// The replaced pod template is recorded in the history as well, so that the restore can be reverted in turn
+func (kc *KubeClient) RestoreSnapshot(ctx context.Context, payload *injectormodels.RestoreSnapshotPayload) (result injectormodels.Deployment, err error) {
+
+	defer func() { err = classifyError(err) }()
+
+	ctx, span := tracing.Start(ctx, "KubeClient.RestoreSnapshot", tracing.Target(payload.Cluster, payload.Namespace, payload.DeploymentName)...)
+	defer func() { tracing.End(span, err) }()
+
+	ctx, cancel := kc.withTimeout(ctx)
+	defer cancel()
+
+	kc.logger.Log().Info("RestoreSnapshot ", zap.String("DeploymentName", payload.DeploymentName), zap.String("Namespace", payload.Namespace), zap.Int("Revision", payload.Revision))
+
+	if payload.Namespace == "" {
+		err = invalidError("namespace is required")
+		return
+	}
+
+	if payload.DeploymentName == "" {
+		err = invalidError("DeploymentName is required")
+		return
+	}
+
+	if payload.Revision <= 0 {
+		err = invalidError("Revision must be positive")
+		return
+	}
+
+	err = kc.checkNamespace(payload.Namespace)
+	if err != nil {
+		return
+	}
+
+	kc, err = kc.onCluster(payload.Cluster)
+	if err != nil {
+		return
+	}
+
+	if payload.DryRun {
+		// dry runs leave no trace, not even Events
+		kc.recorder = nil
+	}
+
+	deployment, err := kc.clientset.AppsV1().Deployments(payload.Namespace).Get(ctx, payload.DeploymentName, v1.GetOptions{})
+	if err != nil {
+		return
+	}
+
+	current := deployment.DeepCopy()
+
+	err = kc.checkOptIn(ctx, deployment)
+	if err != nil {
+		return
+	}
+
+	var snapshot *historySnapshot
+	for _, item := range readHistory(deployment) {
+		if item.Revision == payload.Revision {
+			snapshot = &item
+			break
+		}
+	}
+
+	if snapshot == nil {
+		err = withKind(ErrNotFound, fmt.Errorf("revision %d not found in the history", payload.Revision))
+		return
+	}
+
+	deployment.Spec.Template = snapshot.Template
+
+	if snapshot.Sidecars == "" {
+		delete(deployment.Annotations, SidecarsAnnotation)
+	} else {
+		deployment.Annotations[SidecarsAnnotation] = snapshot.Sidecars
+	}
+
+	if payload.DryRun {
+		result, err = kc.previewUpdate(ctx, current, deployment)
+		return
+	}
+
+	err = kc.recordHistory(deployment, current, "RestoreSnapshot", "")
+	if err != nil {
+		return
+	}
+
+	_, err = kc.clientset.AppsV1().Deployments(payload.Namespace).Update(ctx, deployment, v1.UpdateOptions{})
+	if err != nil {
+		return
+	}
+
+	kc.recordSidecarRollback(deployment, fmt.Sprintf("Pod template restored to revision %d", payload.Revision))
+
+	updatedDeployment, err := kc.clientset.AppsV1().Deployments(payload.Namespace).Get(ctx, payload.DeploymentName, v1.GetOptions{})
+	if err != nil {
+		return
+	}
+
+	result = convertToInternalModel(kc.clusterName, *updatedDeployment)
+
+	kc.logger.Log().Info("RestoreSnapshot - Updated Deployment", zap.String("name", updatedDeployment.Name), zap.String("namespace", updatedDeployment.Namespace), zap.Int("revision", payload.Revision))
+
+	return
+}
+
+// private functions and methods
+
+// recordHistory appends to the history of the deployment the snapshot of its current pod template,
+// dropping the oldest snapshots beyond the history limit. Nothing is recorded when the history is disabled
+func (kc *KubeClient) recordHistory(deployment *appsv1.Deployment, current *appsv1.Deployment, operation string, containerName string) error {
+
+	if kc.historyLimit <= 0 {
+		return nil
+	}
+
+	history := readHistory(current)
+
+	revision := 1
+	if len(history) > 0 {
+		revision = history[len(history)-1].Revision + 1
+	}
+
+	history = append(history, historySnapshot{
+		Revision:      revision,
+		Time:          time.Now().UTC(),
+		Operation:     operation,
+		ContainerName: containerName,
+		PerformedBy:   kc.callerName(),
+		Sidecars:      current.Annotations[SidecarsAnnotation],
+		Template:      *current.Spec.Template.DeepCopy(),
+	})
+
+	if len(history) > kc.historyLimit {
+		history = history[len(history)-kc.historyLimit:]
+	}
+
+	for {
+		value, err := json.Marshal(history)
+		if err != nil {
+			return err
+		}
+
+		if len(value) <= maxHistorySize {
+			if deployment.Annotations == nil {
+				deployment.Annotations = make(map[string]string)
+			}
+			deployment.Annotations[HistoryAnnotation] = string(value)
+			return nil
+		}
+
+		if len(history) == 1 {
+			// a pod template too large for the annotation is not worth failing the change for
+			kc.logger.Log().Warn("Pod template too large for the history, snapshot not recorded", zap.String("name", deployment.Name), zap.String("namespace", deployment.Namespace), zap.Int("size", len(value)))
+			return nil
+		}
+
+		history = history[1:]
+	}
+}
+
+// readHistory returns the snapshots recorded on the deployment, from the oldest
+func readHistory(deployment *appsv1.Deployment) []historySnapshot {
+
+	history := make([]historySnapshot, 0)
+
+	value, found := deployment.Annotations[HistoryAnnotation]
+	if !found || value == "" {
+		return history
+	}
+
+	if err := json.Unmarshal([]byte(value), &history); err != nil {
+		// a malformed annotation, probably edited by hand, is treated as empty
+		return make([]historySnapshot, 0)
+	}
+
+	return history
+}
+
+func historyEntryOf(snapshot historySnapshot) injectormodels.HistoryEntry {
+
+	containerNames := make([]string, len(snapshot.Template.Spec.Containers))
+	for i, container := range snapshot.Template.Spec.Containers {
+		containerNames[i] = container.Name
+	}
+
+	return injectormodels.HistoryEntry{
+		Revision:       snapshot.Revision,
+		Time:           snapshot.Time,
+		Operation:      snapshot.Operation,
+		ContainerName:  snapshot.ContainerName,
+		PerformedBy:    snapshot.PerformedBy,
+		ContainerNames: containerNames,
+		PodTemplate:    snapshot.Template,
+	}
+}
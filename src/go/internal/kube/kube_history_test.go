@@ -0,0 +1,120 @@
+package kube
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	injectormodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/injector"
+)
+
+func TestSetSidecarRecordsHistory(t *testing.T) {
+	kubeClient, clientset, _ := newFakeKubeClient(t, nil, newTestDeployment("payments", "payments-api"))
+	kubeClient.historyLimit = 2
+
+	for _, name := range []string{"debug", "tracer", "profiler"} {
+		_, err := kubeClient.SetSidecar(context.Background(), &injectormodels.SetSidecarPayload{Namespace: "payments", DeploymentName: "payments-api", SidecarContainerName: name, SidecarImage: "busybox"})
+		assert.NoError(t, err)
+	}
+
+	history, err := kubeClient.GetHistory(context.Background(), &injectormodels.GetHistoryPayload{Namespace: "payments", DeploymentName: "payments-api"})
+	assert.NoError(t, err)
+
+	// Check that only the most recent snapshots are kept, each holding the pod template preceding the change
+	if assert.Len(t, history, 2) {
+		assert.Equal(t, 2, history[0].Revision)
+		assert.Equal(t, "SetSidecar", history[0].Operation)
+		assert.Equal(t, "prefix-tracer", history[0].ContainerName)
+		assert.Equal(t, "api-key", history[0].PerformedBy)
+		assert.Equal(t, []string{"app", "prefix-debug"}, history[0].ContainerNames)
+		assert.Equal(t, 3, history[1].Revision)
+		assert.Equal(t, []string{"app", "prefix-debug", "prefix-tracer"}, history[1].ContainerNames)
+	}
+
+	// Check that the history is not part of the pod template, so recording it does not roll the pods
+	stored, err := clientset.AppsV1().Deployments("payments").Get(context.Background(), "payments-api", v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Contains(t, stored.Annotations, HistoryAnnotation)
+	assert.NotContains(t, stored.Spec.Template.Annotations, HistoryAnnotation)
+}
+
+func TestHistoryDisabled(t *testing.T) {
+	kubeClient, clientset, _ := newFakeKubeClient(t, nil, newTestDeployment("payments", "payments-api"))
+
+	_, err := kubeClient.SetSidecar(context.Background(), &injectormodels.SetSidecarPayload{Namespace: "payments", DeploymentName: "payments-api", SidecarContainerName: "debug", SidecarImage: "busybox"})
+	assert.NoError(t, err)
+
+	stored, err := clientset.AppsV1().Deployments("payments").Get(context.Background(), "payments-api", v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.NotContains(t, stored.Annotations, HistoryAnnotation)
+}
+
+func TestRestoreSnapshot(t *testing.T) {
+	kubeClient, clientset, recorder := newFakeKubeClient(t, nil, newTestDeployment("payments", "payments-api"))
+	kubeClient.historyLimit = 10
+
+	_, err := kubeClient.SetSidecar(context.Background(), &injectormodels.SetSidecarPayload{Namespace: "payments", DeploymentName: "payments-api", SidecarContainerName: "debug", SidecarImage: "busybox"})
+	assert.NoError(t, err)
+	<-recorder.Events
+
+	result, err := kubeClient.RestoreSnapshot(context.Background(), &injectormodels.RestoreSnapshotPayload{Namespace: "payments", DeploymentName: "payments-api", Revision: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"app"}, result.ContainerNames)
+	assert.Empty(t, result.InjectedSidecars)
+
+	// Check the stored deployment
+	stored, err := clientset.AppsV1().Deployments("payments").Get(context.Background(), "payments-api", v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, stored.Spec.Template.Spec.Containers, 1)
+	assert.NotContains(t, stored.Annotations, SidecarsAnnotation)
+
+	// Check that the replaced pod template has been recorded, so that the restore can be reverted
+	history := readHistory(stored)
+	if assert.Len(t, history, 2) {
+		assert.Equal(t, "RestoreSnapshot", history[1].Operation)
+		assert.Len(t, history[1].Template.Spec.Containers, 2)
+		assert.Contains(t, history[1].Sidecars, "prefix-debug")
+	}
+
+	event := <-recorder.Events
+	assert.True(t, strings.HasPrefix(event, "Normal SidecarRollback Pod template restored to revision 1 by api-key"), event)
+
+	// Check that restoring the latest revision brings the sidecar back along with its metadata
+	result, err = kubeClient.RestoreSnapshot(context.Background(), &injectormodels.RestoreSnapshotPayload{Namespace: "payments", DeploymentName: "payments-api", Revision: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"app", "prefix-debug"}, result.ContainerNames)
+	if assert.Len(t, result.InjectedSidecars, 1) {
+		assert.Equal(t, "busybox", result.InjectedSidecars[0].Image)
+	}
+}
+
+func TestRestoreSnapshotErrors(t *testing.T) {
+	testCases := []struct {
+		name            string
+		payload         injectormodels.RestoreSnapshotPayload
+		expectedErrKind error
+	}{
+		{"missing revision", injectormodels.RestoreSnapshotPayload{Namespace: "payments", DeploymentName: "payments-api"}, ErrInvalid},
+		{"unknown revision", injectormodels.RestoreSnapshotPayload{Namespace: "payments", DeploymentName: "payments-api", Revision: 7}, ErrNotFound},
+		{"missing deployment", injectormodels.RestoreSnapshotPayload{Namespace: "payments", DeploymentName: "payments-worker", Revision: 1}, ErrNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			kubeClient, _, _ := newFakeKubeClient(t, nil, newTestDeployment("payments", "payments-api"))
+
+			_, err := kubeClient.RestoreSnapshot(context.Background(), &tc.payload)
+			assert.ErrorIs(t, err, tc.expectedErrKind)
+		})
+	}
+}
+
+func TestReadHistoryMalformedAnnotation(t *testing.T) {
+	deployment := &appsv1.Deployment{ObjectMeta: v1.ObjectMeta{Annotations: map[string]string{HistoryAnnotation: "not json"}}}
+
+	assert.Empty(t, readHistory(deployment))
+}
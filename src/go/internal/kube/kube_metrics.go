@@ -0,0 +1,67 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/metrics"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/tracing"
+)
+
+// CountSidecars counts the sidecars currently injected in the deployments of all the clusters, by namespace and profile,
+// as recorded in the deployment annotations. The deployments of all the namespaces are read from the cache, or listed at once
+// from the API server until it is synced, which the injector is granted only along with the cache: so the sidecar gauge is
+// registered only when the cache is enabled.
+// The failure on a cluster does not stop the others, whose counts are returned along with the error
+func (kc *KubeClient) CountSidecars(ctx context.Context) (result []metrics.SidecarCount, err error) {
+
+	defer func() { err = classifyError(err) }()
+
+	ctx, span := tracing.Start(ctx, "KubeClient.CountSidecars")
+	defer func() { tracing.End(span, err) }()
+
+	result = make([]metrics.SidecarCount, 0)
+	errs := make([]error, 0)
+
+	for _, name := range kc.clusterNames {
+
+		bound, boundErr := kc.onCluster(name)
+		if boundErr != nil {
+			errs = append(errs, boundErr)
+			continue
+		}
+
+		deployments, listErr := bound.listDeployments(ctx, "", false)
+		if listErr != nil {
+			errs = append(errs, fmt.Errorf("cluster %s: %w", name, listErr))
+			continue
+		}
+
+		counts := make(map[metrics.SidecarCount]int)
+		for i := range deployments {
+			for _, sidecar := range readInjectedSidecars(&deployments[i]) {
+				counts[metrics.SidecarCount{Cluster: name, Namespace: deployments[i].Namespace, Profile: sidecar.Profile}]++
+			}
+		}
+
+		for key, count := range counts {
+			key.Count = count
+			result = append(result, key)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Cluster != result[j].Cluster {
+			return result[i].Cluster < result[j].Cluster
+		}
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+		return result[i].Profile < result[j].Profile
+	})
+
+	err = errors.Join(errs...)
+	return
+}
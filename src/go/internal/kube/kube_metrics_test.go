@@ -0,0 +1,36 @@
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/metrics"
+	injectormodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/injector"
+)
+
+func TestCountSidecars(t *testing.T) {
+	kubeClient, _, _ := newFakeKubeClient(t, nil,
+		newTestDeployment("payments", "payments-api"),
+		newTestDeployment("payments", "payments-worker"),
+		newTestDeployment("orders", "orders-api"))
+
+	for _, payload := range []injectormodels.SetSidecarPayload{
+		{Namespace: "payments", DeploymentName: "payments-api", SidecarContainerName: "debug", SidecarImage: "busybox"},
+		{Namespace: "payments", DeploymentName: "payments-worker", SidecarContainerName: "debug", SidecarImage: "busybox"},
+		{Namespace: "payments", DeploymentName: "payments-worker", SidecarContainerName: "tracer", SidecarImage: "tracer", Profile: "tracing"},
+	} {
+		_, err := kubeClient.SetSidecar(context.Background(), &payload)
+		assert.NoError(t, err)
+	}
+
+	counts, err := kubeClient.CountSidecars(context.Background())
+	assert.NoError(t, err)
+
+	// Check that the sidecars are counted by namespace and profile, the namespaces without any omitted
+	assert.Equal(t, []metrics.SidecarCount{
+		{Cluster: DefaultClusterName, Namespace: "payments", Count: 2},
+		{Cluster: DefaultClusterName, Namespace: "payments", Profile: "tracing", Count: 1},
+	}, counts)
+}
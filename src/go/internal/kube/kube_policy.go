@@ -0,0 +1,83 @@
+package kube
+
+import (
+	"context"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/policy"
+)
+
+// SetPolicy replaces the policy enforced by the next calls, of this client and of the ones bound to a caller or cluster
+func (kc *KubeClient) SetPolicy(namespacePolicy *policy.Policy) {
+	kc.policy.Store(namespacePolicy)
+}
+
+// checkNamespace enforces the allowed and denied namespaces of the policy
+func (kc *KubeClient) checkNamespace(namespace string) error {
+
+	currentPolicy := kc.currentPolicy()
+	if currentPolicy == nil {
+		return nil
+	}
+
+	if err := currentPolicy.CheckNamespace(namespace); err != nil {
+		return withKind(ErrForbidden, err)
+	}
+
+	return nil
+}
+
+// checkOptIn enforces, when required by the policy, that the deployment or its namespace opted in
+func (kc *KubeClient) checkOptIn(ctx context.Context, deployment *appsv1.Deployment) error {
+
+	currentPolicy := kc.currentPolicy()
+	if currentPolicy == nil || !currentPolicy.OptInRequired {
+		return nil
+	}
+
+	if currentPolicy.OptedIn(deployment.Labels, deployment.Annotations) || kc.namespaceOptedIn(ctx, deployment.Namespace) {
+		return nil
+	}
+
+	return withKind(ErrForbidden, currentPolicy.NotOptedInError(deployment.Namespace, deployment.Name))
+}
+
+// namespaceOptedIn reports whether the namespace carries the opt-in label or annotation.
+// The namespace is read by the injector itself, even when impersonating the caller
+func (kc *KubeClient) namespaceOptedIn(ctx context.Context, namespace string) bool {
+
+	currentPolicy := kc.currentPolicy()
+	if currentPolicy == nil || !currentPolicy.OptInRequired {
+		return true
+	}
+
+	ns, err := kc.ownClientset.CoreV1().Namespaces().Get(ctx, namespace, v1.GetOptions{})
+	if err != nil {
+		kc.logger.Log().Warn("Cannot read namespace for opt-in check", zap.String("namespace", namespace), zap.Error(err))
+		return false
+	}
+
+	return currentPolicy.OptedIn(ns.Labels, ns.Annotations)
+}
+
+// currentPolicy returns the policy enforced, nil when none
+func (kc *KubeClient) currentPolicy() *policy.Policy {
+
+	if kc.policy == nil {
+		return nil
+	}
+
+	return kc.policy.Load()
+}
+
+func newPolicyHolder(namespacePolicy *policy.Policy) *atomic.Pointer[policy.Policy] {
+
+	holder := &atomic.Pointer[policy.Policy]{}
+	holder.Store(namespacePolicy)
+
+	return holder
+}
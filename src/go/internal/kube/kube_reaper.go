@@ -0,0 +1,176 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/auth"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/tracing"
+)
+
+// reaperIdentity is the caller recorded in the Events and notifications of the sidecars removed on TTL expiration
+var reaperIdentity = &auth.Identity{Username: eventComponent}
+
+// ReapExpiredSidecars removes from the deployments of all the clusters the sidecars whose TTL expired by now, recording
+// a SidecarExpired Event for each of them. The deployments of all the namespaces are looked through on the cache;
+// without the cache only the ones of the namespaces allowed by name by the policy, the injector being granted listing
+// Deployments from the API server namespace by namespace. The sidecars are removed whatever the opt-in, having been
+// allowed when injected. The failure on a cluster or a deployment does not stop the others, and the count of the
+// removed sidecars is returned along with the error
+func (kc *KubeClient) ReapExpiredSidecars(ctx context.Context, now time.Time) (removed int, err error) {
+
+	defer func() { err = classifyError(err) }()
+
+	ctx, span := tracing.Start(ctx, "KubeClient.ReapExpiredSidecars")
+	defer func() { tracing.End(span, err) }()
+
+	errs := make([]error, 0)
+
+	for _, name := range kc.clusterNames {
+
+		bound, boundErr := kc.onCluster(name)
+		if boundErr != nil {
+			errs = append(errs, boundErr)
+			continue
+		}
+		bound.caller = reaperIdentity
+
+		namespaces, namespacesErr := bound.reapedNamespaces()
+		if namespacesErr != nil {
+			errs = append(errs, fmt.Errorf("cluster %s: %w", name, namespacesErr))
+			continue
+		}
+
+		for _, namespace := range namespaces {
+
+			deployments, listErr := bound.listDeployments(ctx, namespace, false)
+			if listErr != nil {
+				errs = append(errs, fmt.Errorf("cluster %s: %w", name, listErr))
+				continue
+			}
+
+			for i := range deployments {
+				if len(expiredSidecarsOf(&deployments[i], now)) == 0 {
+					continue
+				}
+
+				count, reapErr := bound.reapExpiredOn(ctx, deployments[i].Namespace, deployments[i].Name, now)
+				removed += count
+				if reapErr != nil {
+					errs = append(errs, fmt.Errorf("cluster %s, deployment %s/%s: %w", name, deployments[i].Namespace, deployments[i].Name, reapErr))
+				}
+			}
+		}
+	}
+
+	err = errors.Join(errs...)
+	return
+}
+
+// private functions and methods
+
+// reapedNamespaces returns the namespaces whose deployments are looked through on the bound cluster: all of them, listed
+// at once, on the cache, otherwise the ones allowed by name by the policy
+func (kc *KubeClient) reapedNamespaces() ([]string, error) {
+
+	if kc.cache != nil {
+		return []string{v1.NamespaceAll}, nil
+	}
+
+	namespaces, named := kc.currentPolicy().AllowedNamespaceNames()
+	if !named {
+		return nil, errors.New("without the deployment cache the policy must allow the namespaces by name, for listing their deployments")
+	}
+
+	return namespaces, nil
+}
+
+// reapExpiredOn removes the expired sidecars from the deployment, read again from the API server as it is going to be updated
+func (kc *KubeClient) reapExpiredOn(ctx context.Context, namespace string, name string, now time.Time) (int, error) {
+
+	ctx, cancel := kc.withTimeout(ctx)
+	defer cancel()
+
+	deployment, err := kc.clientset.AppsV1().Deployments(namespace).Get(ctx, name, v1.GetOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	expired := expiredSidecarsOf(deployment, now)
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	current := deployment.DeepCopy()
+
+	sidecars := readInjectedSidecars(deployment)
+	removed := make([]corev1.Container, 0, len(expired))
+	containers := make([]corev1.Container, 0, len(deployment.Spec.Template.Spec.Containers))
+
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		if _, found := expired[container.Name]; found {
+			removed = append(removed, container)
+			continue
+		}
+
+		containers = append(containers, container)
+	}
+
+	// the metadata of the sidecars removed by hand is dropped as well
+	for containerName := range expired {
+		delete(sidecars, containerName)
+	}
+
+	deployment.Spec.Template.Spec.Containers = containers
+
+	fail := func(err error) (int, error) {
+		for _, container := range removed {
+			kc.recordSidecarFailed(deployment, "Expiration", container.Name, container.Image, err)
+		}
+		return 0, err
+	}
+
+	err = writeInjectedSidecars(deployment, sidecars)
+	if err != nil {
+		return fail(err)
+	}
+
+	err = kc.recordHistory(deployment, current, "SidecarExpired", "")
+	if err != nil {
+		return fail(err)
+	}
+
+	_, err = kc.clientset.AppsV1().Deployments(namespace).Update(ctx, deployment, v1.UpdateOptions{})
+	if err != nil {
+		return fail(err)
+	}
+
+	for _, container := range removed {
+		kc.recordSidecarExpired(deployment, container.Name, container.Image)
+	}
+
+	kc.logger.Log().Info("ReapExpiredSidecars - Updated Deployment", zap.String("cluster", kc.clusterName), zap.String("name", name), zap.String("namespace", namespace), zap.Int("removed", len(removed)))
+
+	return len(removed), nil
+}
+
+// expiredSidecarsOf returns the names of the sidecars of the deployment whose TTL expired by now
+func expiredSidecarsOf(deployment *appsv1.Deployment, now time.Time) map[string]struct{} {
+
+	expired := make(map[string]struct{})
+
+	for containerName, sidecar := range readInjectedSidecars(deployment) {
+		if sidecar.ExpiresAt != nil && !sidecar.ExpiresAt.After(now) {
+			expired[containerName] = struct{}{}
+		}
+	}
+
+	return expired
+}
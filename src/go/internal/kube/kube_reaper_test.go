@@ -0,0 +1,137 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	injectormodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/injector"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/policy"
+)
+
+// newTestDeploymentExpiring returns a test deployment holding the given sidecars, injected with the given expiration times
+func newTestDeploymentExpiring(namespace string, name string, expirations map[string]*time.Time) *appsv1.Deployment {
+
+	sidecars := make(map[string]injectormodels.InjectedSidecar)
+	names := make([]string, 0, len(expirations))
+	for containerName, expiresAt := range expirations {
+		sidecars[containerName] = injectormodels.InjectedSidecar{ContainerName: containerName, Image: "busybox", ExpiresAt: expiresAt}
+		names = append(names, containerName)
+	}
+
+	deployment := newTestDeploymentWithSidecars(namespace, name, namespace, names...)
+	writeInjectedSidecars(deployment, sidecars)
+
+	return deployment
+}
+
+// denyClusterWideLists makes the clientset refuse listing deployments cluster-wide, like the API server when the injector
+// is granted listing them only namespace by namespace
+func denyClusterWideLists(clientset *fake.Clientset) {
+	clientset.PrependReactor("list", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetNamespace() == v1.NamespaceAll {
+			return true, nil, apierrors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "deployments"}, "", errors.New("cannot list resource at the cluster scope"))
+		}
+		return false, nil, nil
+	})
+}
+
+func TestReapExpiredSidecars(t *testing.T) {
+	now := time.Now().UTC()
+	expired := now.Add(-time.Minute)
+	valid := now.Add(time.Hour)
+
+	// Check that the opt-in does not stop the removal of the sidecars injected when allowed
+	namespacePolicy := &policy.Policy{AllowedNamespaces: []string{"payments", "orders"}, OptInRequired: true}
+	assert.NoError(t, namespacePolicy.Validate())
+
+	kubeClient, clientset, recorder := newFakeKubeClient(t, namespacePolicy,
+		newTestDeploymentExpiring("payments", "payments-api", map[string]*time.Time{"prefix-debug": &expired, "prefix-tracer": &valid}),
+		newTestDeploymentExpiring("orders", "orders-api", map[string]*time.Time{"prefix-debug": nil}),
+		newTestDeploymentExpiring("billing", "billing-api", map[string]*time.Time{"prefix-debug": &expired}),
+	)
+
+	// Check that without the cache the deployments are listed namespace by namespace
+	denyClusterWideLists(clientset)
+
+	removed, err := kubeClient.ReapExpiredSidecars(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	stored, err := clientset.AppsV1().Deployments("payments").Get(context.Background(), "payments-api", v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"app", "prefix-tracer"}, convertToInternalModel("", *stored).ContainerNames)
+	assert.Contains(t, readInjectedSidecars(stored), "prefix-tracer")
+	assert.NotContains(t, readInjectedSidecars(stored), "prefix-debug")
+
+	// Check that the sidecars without TTL are kept
+	stored, err = clientset.AppsV1().Deployments("orders").Get(context.Background(), "orders-api", v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, stored.Spec.Template.Spec.Containers, 2)
+
+	// Check that the namespaces not allowed are not looked through
+	stored, err = clientset.AppsV1().Deployments("billing").Get(context.Background(), "billing-api", v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, stored.Spec.Template.Spec.Containers, 2)
+
+	if assert.Len(t, recorder.Events, 1) {
+		assert.Contains(t, <-recorder.Events, "Normal SidecarExpired Sidecar container prefix-debug with image busybox removed on TTL expiration")
+	}
+
+	// Check that nothing is left to reap
+	removed, err = kubeClient.ReapExpiredSidecars(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, removed)
+}
+
+func TestReapExpiredSidecarsPartialFailure(t *testing.T) {
+	now := time.Now().UTC()
+	expired := now.Add(-time.Minute)
+
+	kubeClient, clientset, recorder := newFakeKubeClient(t, &policy.Policy{AllowedNamespaces: []string{"payments"}},
+		newTestDeploymentExpiring("payments", "payments-api", map[string]*time.Time{"prefix-debug": &expired}),
+		newTestDeploymentExpiring("payments", "payments-worker", map[string]*time.Time{"prefix-debug": &expired}),
+	)
+
+	clientset.PrependReactor("update", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.UpdateActionImpl).GetObject().(*appsv1.Deployment).Name == "payments-worker" {
+			return true, nil, errors.New("update failed")
+		}
+		return false, nil, nil
+	})
+
+	removed, err := kubeClient.ReapExpiredSidecars(context.Background(), now)
+	assert.ErrorContains(t, err, "deployment payments/payments-worker: update failed")
+	assert.Equal(t, 1, removed)
+
+	assert.Len(t, recorder.Events, 2)
+}
+
+func TestReapExpiredSidecarsWithoutNamedNamespaces(t *testing.T) {
+	now := time.Now().UTC()
+	expired := now.Add(-time.Minute)
+
+	kubeClient, clientset, _ := newFakeKubeClient(t, &policy.Policy{AllowedNamespaces: []string{"team-*"}},
+		newTestDeploymentExpiring("team-payments", "payments-api", map[string]*time.Time{"prefix-debug": &expired}),
+	)
+	denyClusterWideLists(clientset)
+
+	// Check that nothing is listed cluster-wide without the cache
+	removed, err := kubeClient.ReapExpiredSidecars(context.Background(), now)
+	assert.ErrorContains(t, err, "cluster default: without the deployment cache the policy must allow the namespaces by name")
+	assert.Equal(t, 0, removed)
+
+	for _, action := range clientset.Actions() {
+		assert.NotEqual(t, "list", action.GetVerb())
+	}
+}
@@ -0,0 +1,124 @@
+package kube
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/auth"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/tracing"
+)
+
+// ReviewToken authenticates a service-account or user token by means of a TokenReview on the default cluster
+func (kc *KubeClient) ReviewToken(ctx context.Context, token string) (identity *auth.Identity, err error) {
+
+	ctx, cancel := kc.withTimeout(ctx)
+	defer cancel()
+
+	if token == "" {
+		err = errors.New("token is required")
+		return
+	}
+
+	target, err := kc.cluster("")
+	if err != nil {
+		return
+	}
+
+	review := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token: token,
+		},
+	}
+
+	result, err := target.clientset.AuthenticationV1().TokenReviews().Create(ctx, review, v1.CreateOptions{})
+	if err != nil {
+		return
+	}
+
+	if !result.Status.Authenticated {
+		err = errors.New("token not authenticated: " + result.Status.Error)
+		return
+	}
+
+	identity = &auth.Identity{
+		Username: result.Status.User.Username,
+		UID:      result.Status.User.UID,
+		Groups:   result.Status.User.Groups,
+	}
+
+	if len(result.Status.User.Extra) > 0 {
+		identity.Extra = make(map[string][]string, len(result.Status.User.Extra))
+		for key, value := range result.Status.User.Extra {
+			identity.Extra[key] = []string(value)
+		}
+	}
+
+	kc.logger.Log().Info("ReviewToken - Authenticated caller", zap.String("username", identity.Username), zap.Strings("groups", identity.Groups))
+
+	return
+}
+
+// ReviewAccess checks by means of a SubjectAccessReview on the target cluster whether the caller could update the named deployment by itself
+func (kc *KubeClient) ReviewAccess(ctx context.Context, caller *auth.Identity, cluster string, namespace string, name string) (allowed bool, err error) {
+
+	defer func() { err = classifyError(err) }()
+
+	ctx, span := tracing.Start(ctx, "KubeClient.ReviewAccess", tracing.Target(cluster, namespace, name)...)
+	defer func() { tracing.End(span, err) }()
+
+	ctx, cancel := kc.withTimeout(ctx)
+	defer cancel()
+
+	if caller == nil {
+		err = invalidError("caller identity is required")
+		return
+	}
+
+	if namespace == "" {
+		err = invalidError("namespace is required")
+		return
+	}
+
+	target, err := kc.cluster(cluster)
+	if err != nil {
+		return
+	}
+
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   caller.Username,
+			UID:    caller.UID,
+			Groups: caller.Groups,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      "update",
+				Group:     "apps",
+				Resource:  "deployments",
+				Name:      name,
+			},
+		},
+	}
+
+	if len(caller.Extra) > 0 {
+		review.Spec.Extra = make(map[string]authorizationv1.ExtraValue, len(caller.Extra))
+		for key, value := range caller.Extra {
+			review.Spec.Extra[key] = authorizationv1.ExtraValue(value)
+		}
+	}
+
+	result, err := target.clientset.AuthorizationV1().SubjectAccessReviews().Create(ctx, review, v1.CreateOptions{})
+	if err != nil {
+		return
+	}
+
+	allowed = result.Status.Allowed && !result.Status.Denied
+
+	kc.logger.Log().Info("ReviewAccess - Reviewed caller access", zap.String("username", caller.Username), zap.String("cluster", target.name), zap.String("namespace", namespace), zap.String("name", name), zap.Bool("allowed", allowed), zap.String("reason", result.Status.Reason))
+
+	return
+}
@@ -0,0 +1,225 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+)
+
+// Defaults of the timing of the election, the ones of the Kubernetes controllers
+const (
+	DefaultLeaseDuration = 15 * time.Second
+	DefaultRenewDeadline = 10 * time.Second
+	DefaultRetryPeriod   = 2 * time.Second
+)
+
+type Options struct {
+	// elect a leader among the replicas by a Lease; when disabled the replica is always the leader
+	Enabled bool
+	// namespace and name of the Lease
+	Namespace string
+	LeaseName string
+	// identity of the replica, unique among the replicas like the pod name
+	Identity string
+	// timing of the election, defaulting to DefaultLeaseDuration, DefaultRenewDeadline and DefaultRetryPeriod
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
+
+// Elector elects by a Lease the replica running the background loops, so that they are not run by every replica
+// while all of them serve the API
+type Elector struct {
+	logger  *logging.Logger
+	options Options
+	config  leaderelection.LeaderElectionConfig
+
+	leading atomic.Bool
+	mutex   sync.RWMutex
+	leader  string
+}
+
+// NewElector creates a new instance of the Elector, taking part in the election by means of the given clientset
+func New(logger *logging.Logger, clientset kubernetes.Interface, options Options) (*Elector, error) {
+
+	if options.Identity == "" {
+		return nil, errors.New("the identity of the replica is required")
+	}
+
+	elector := &Elector{
+		logger:  logger,
+		options: options,
+	}
+
+	// without election the replica is the leader
+	if !options.Enabled {
+		elector.leading.Store(true)
+		elector.leader = options.Identity
+		return elector, nil
+	}
+
+	if options.Namespace == "" || options.LeaseName == "" {
+		return nil, errors.New("both the namespace and the name of the Lease are required")
+	}
+
+	if options.LeaseDuration <= 0 {
+		options.LeaseDuration = DefaultLeaseDuration
+	}
+	if options.RenewDeadline <= 0 {
+		options.RenewDeadline = DefaultRenewDeadline
+	}
+	if options.RetryPeriod <= 0 {
+		options.RetryPeriod = DefaultRetryPeriod
+	}
+
+	elector.options = options
+	elector.config = leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta:  v1.ObjectMeta{Namespace: options.Namespace, Name: options.LeaseName},
+			Client:     clientset.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{Identity: options.Identity},
+		},
+		Name:          options.LeaseName,
+		LeaseDuration: options.LeaseDuration,
+		RenewDeadline: options.RenewDeadline,
+		RetryPeriod:   options.RetryPeriod,
+		// on shutdown the Lease is released, so that another replica takes over without waiting for its expiration
+		ReleaseOnCancel: true,
+	}
+
+	// checks the timing, the callbacks being set at every term
+	check := elector.config
+	check.Callbacks = leaderelection.LeaderCallbacks{OnStartedLeading: func(context.Context) {}, OnStoppedLeading: func() {}}
+	if _, err := leaderelection.NewLeaderElector(check); err != nil {
+		return nil, err
+	}
+
+	return elector, nil
+}
+
+// Run takes part in the election until stopCh is closed, running the loop while leading. The loop is stopped, closing
+// its stop channel, when the leadership is lost, and run again when regained. Without election the loop is just run
+func (e *Elector) Run(stopCh <-chan struct{}, loop func(stopCh <-chan struct{})) {
+
+	if !e.options.Enabled {
+		loop(stopCh)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for ctx.Err() == nil {
+		if err := e.runTerm(ctx, loop); err != nil {
+			// the configuration has been checked by New
+			e.logger.Log().Error("Error creating the leader elector", zap.Error(err))
+			return
+		}
+	}
+}
+
+// Identity returns the identity of the replica
+func (e *Elector) Identity() string {
+
+	if e == nil {
+		return ""
+	}
+
+	return e.options.Identity
+}
+
+// Leader returns the identity of the leader last observed, empty when not known yet
+func (e *Elector) Leader() string {
+
+	if e == nil {
+		return ""
+	}
+
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	return e.leader
+}
+
+// IsLeader reports whether the replica is the leader
+func (e *Elector) IsLeader() bool {
+	return e != nil && e.leading.Load()
+}
+
+// private functions and methods
+
+// runTerm campaigns for the leadership and, once elected, runs the loop until the leadership is lost or ctx is done
+func (e *Elector) runTerm(ctx context.Context, loop func(stopCh <-chan struct{})) error {
+
+	var started atomic.Bool
+	stopped := make(chan struct{})
+
+	config := e.config
+	config.Callbacks = leaderelection.LeaderCallbacks{
+		OnStartedLeading: func(leadingCtx context.Context) {
+			started.Store(true)
+			defer close(stopped)
+
+			e.leading.Store(true)
+			// OnNewLeader is called asynchronously, possibly later
+			e.setLeader(e.options.Identity)
+			e.logger.Log().Info("Started leading", zap.String("identity", e.options.Identity), zap.String("lease", e.options.LeaseName))
+
+			loop(leadingCtx.Done())
+		},
+		// called at the end of every term, even when not elected
+		OnStoppedLeading: func() {
+			// unknown until observed again
+			e.setLeader("")
+			if e.leading.Swap(false) {
+				e.logger.Log().Info("Stopped leading", zap.String("identity", e.options.Identity), zap.String("lease", e.options.LeaseName))
+			}
+		},
+		OnNewLeader: func(identity string) {
+			e.setLeader(identity)
+			if identity != e.options.Identity {
+				e.logger.Log().Info("New leader elected", zap.String("leader", identity), zap.String("lease", e.options.LeaseName))
+			}
+		},
+	}
+
+	elector, err := leaderelection.NewLeaderElector(config)
+	if err != nil {
+		return err
+	}
+
+	elector.Run(ctx)
+
+	// the loop of the term is over before campaigning again
+	if started.Load() {
+		<-stopped
+	}
+
+	return nil
+}
+
+func (e *Elector) setLeader(identity string) {
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.leader = identity
+}
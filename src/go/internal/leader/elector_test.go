@@ -0,0 +1,119 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+)
+
+func newTestOptions(identity string) Options {
+	return Options{
+		Enabled:       true,
+		Namespace:     "injector",
+		LeaseName:     "kube-ondemand-sidecar-injector",
+		Identity:      identity,
+		LeaseDuration: time.Second,
+		RenewDeadline: 500 * time.Millisecond,
+		RetryPeriod:   100 * time.Millisecond,
+	}
+}
+
+// runLoop returns a loop reporting on the returned channel whether it is running
+func runLoop() (func(stopCh <-chan struct{}), chan bool) {
+
+	running := make(chan bool, 10)
+
+	return func(stopCh <-chan struct{}) {
+		running <- true
+		<-stopCh
+		running <- false
+	}, running
+}
+
+func TestNewErrors(t *testing.T) {
+	testCases := []struct {
+		name          string
+		options       Options
+		expectedError string
+	}{
+		{"without identity", Options{}, "the identity of the replica is required"},
+		{"without lease", Options{Enabled: true, Identity: "replica-1"}, "both the namespace and the name of the Lease are required"},
+		{"invalid timing", Options{Enabled: true, Identity: "replica-1", Namespace: "injector", LeaseName: "lease", LeaseDuration: time.Second, RenewDeadline: 2 * time.Second}, "leaseDuration must be greater than renewDeadline"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := New(logging.New(), fake.NewSimpleClientset(), tc.options)
+			assert.ErrorContains(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestRunWithoutElection(t *testing.T) {
+	elector, err := New(logging.New(), nil, Options{Identity: "replica-1"})
+	assert.NoError(t, err)
+
+	loop, running := runLoop()
+	stopCh := make(chan struct{})
+
+	go elector.Run(stopCh, loop)
+
+	assert.True(t, <-running)
+	assert.True(t, elector.IsLeader())
+	assert.Equal(t, "replica-1", elector.Leader())
+
+	close(stopCh)
+	assert.False(t, <-running)
+}
+
+func TestRun(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+
+	first, err := New(logging.New(), clientset, newTestOptions("replica-1"))
+	assert.NoError(t, err)
+	second, err := New(logging.New(), clientset, newTestOptions("replica-2"))
+	assert.NoError(t, err)
+
+	firstLoop, firstRunning := runLoop()
+	secondLoop, secondRunning := runLoop()
+	firstStopCh := make(chan struct{})
+	secondStopCh := make(chan struct{})
+
+	go first.Run(firstStopCh, firstLoop)
+	assert.True(t, <-firstRunning)
+
+	go second.Run(secondStopCh, secondLoop)
+	defer close(secondStopCh)
+
+	// Check that only the leader runs the loop, while both know the leader
+	assert.Eventually(t, func() bool { return second.Leader() == "replica-1" }, 5*time.Second, 10*time.Millisecond)
+	assert.True(t, first.IsLeader())
+	assert.False(t, second.IsLeader())
+	assert.Equal(t, "replica-1", first.Leader())
+	assert.Empty(t, secondRunning)
+
+	lease, err := clientset.CoordinationV1().Leases("injector").Get(context.Background(), "kube-ondemand-sidecar-injector", v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "replica-1", *lease.Spec.HolderIdentity)
+
+	// Check that the Lease is released on stop, and the other replica takes over
+	close(firstStopCh)
+	assert.False(t, <-firstRunning)
+
+	select {
+	case started := <-secondRunning:
+		assert.True(t, started)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "the second replica did not take over")
+	}
+
+	assert.True(t, second.IsLeader())
+	assert.Equal(t, "replica-2", second.Leader())
+	assert.Eventually(t, func() bool { return !first.IsLeader() && first.Leader() == "" }, time.Second, 10*time.Millisecond)
+}
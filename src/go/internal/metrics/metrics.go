@@ -0,0 +1,201 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+	clientmetrics "k8s.io/client-go/tools/metrics"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+)
+
+const namespace = "kube_ondemand_sidecar_injector"
+
+// maximum duration of the count of the injected sidecars at every scrape
+const countTimeout = 10 * time.Second
+
+// SidecarCount is the number of sidecars injected, and not removed yet, in the deployments of a namespace with the same profile
+type SidecarCount struct {
+	Cluster   string
+	Namespace string
+	// empty for the sidecars injected without a profile
+	Profile string
+	Count   int
+}
+
+// ISidecarCounter counts the sidecars currently injected
+type ISidecarCounter interface {
+	CountSidecars(ctx context.Context) ([]SidecarCount, error)
+}
+
+// Metrics holds the Prometheus metrics of the injector, exposed by its Handler
+type Metrics struct {
+	logger            *logging.Logger
+	registry          *prometheus.Registry
+	httpRequests      *prometheus.CounterVec
+	httpDuration      *prometheus.HistogramVec
+	kubeDuration      *prometheus.HistogramVec
+	kubeErrors        *prometheus.CounterVec
+	sidecarEvents     *prometheus.CounterVec
+	activeSidecarDesc *prometheus.Desc
+	counter           ISidecarCounter
+}
+
+// NewMetrics creates a new instance of the Metrics with a registry of its own, holding the Go runtime and process metrics too
+func New(logger *logging.Logger) *Metrics {
+
+	m := &Metrics{
+		logger:   logger,
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests served, by method, route and status code.",
+		}, []string{"method", "route", "code"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of the HTTP requests served, by method and route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		kubeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "kube_api_request_duration_seconds",
+			Help:      "Latency of the Kubernetes API calls, by verb and API server host.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"verb", "host"}),
+		kubeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "kube_api_errors_total",
+			Help:      "Kubernetes API calls failed, by verb, API server host and status code, <error> when no response was received.",
+		}, []string{"verb", "host", "code"}),
+		sidecarEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sidecar_events_total",
+			Help:      "Steps of the sidecar lifecycle, by cluster and reason of the Kubernetes Event recorded, like SidecarExpired or SidecarRollback.",
+		}, []string{"cluster", "reason"}),
+		activeSidecarDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "active_sidecars"),
+			"Sidecars currently injected, by cluster, namespace and profile.",
+			[]string{"cluster", "namespace", "profile"}, nil),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.kubeDuration,
+		m.kubeErrors,
+		m.sidecarEvents,
+	)
+
+	return m
+}
+
+// Handler returns the http handler exposing the metrics
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Middleware returns the gin middleware measuring the requests, labeled by the route pattern rather than the path,
+// so that path parameters do not multiply the series
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		start := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		m.httpRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		m.httpDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveSidecarEvent counts a step of the sidecar lifecycle. Nothing is done on nil Metrics
+func (m *Metrics) ObserveSidecarEvent(cluster string, reason string) {
+
+	if m == nil {
+		return
+	}
+
+	m.sidecarEvents.WithLabelValues(cluster, reason).Inc()
+}
+
+// RegisterSidecarCounter exposes the sidecars currently injected, as counted by the counter at every scrape
+func (m *Metrics) RegisterSidecarCounter(counter ISidecarCounter) {
+	m.counter = counter
+	m.registry.MustRegister(&activeSidecarsCollector{metrics: m})
+}
+
+// RegisterKubeClientMetrics measures the calls of all the Kubernetes clients of the process.
+// The client-go metrics are global, so only the first Metrics registering them receive the measures
+func (m *Metrics) RegisterKubeClientMetrics() {
+	clientmetrics.Register(clientmetrics.RegisterOpts{
+		RequestLatency: &kubeLatencyAdapter{m.kubeDuration},
+		RequestResult:  &kubeResultAdapter{m.kubeErrors},
+	})
+}
+
+// private functions and methods
+
+// activeSidecarsCollector counts the injected sidecars when scraped, so that the count reflects
+// the changes made by anybody, and survives restarts
+type activeSidecarsCollector struct {
+	metrics *Metrics
+}
+
+func (ac *activeSidecarsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- ac.metrics.activeSidecarDesc
+}
+
+func (ac *activeSidecarsCollector) Collect(ch chan<- prometheus.Metric) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), countTimeout)
+	defer cancel()
+
+	counts, err := ac.metrics.counter.CountSidecars(ctx)
+	if err != nil {
+		// the counts of the reachable clusters are still worth exposing
+		ac.metrics.logger.Log().Error("Error counting active sidecars", zap.Error(err))
+	}
+
+	for _, count := range counts {
+		ch <- prometheus.MustNewConstMetric(ac.metrics.activeSidecarDesc, prometheus.GaugeValue, float64(count.Count), count.Cluster, count.Namespace, count.Profile)
+	}
+}
+
+type kubeLatencyAdapter struct {
+	histogram *prometheus.HistogramVec
+}
+
+func (a *kubeLatencyAdapter) Observe(ctx context.Context, verb string, u url.URL, latency time.Duration) {
+	a.histogram.WithLabelValues(verb, u.Host).Observe(latency.Seconds())
+}
+
+type kubeResultAdapter struct {
+	counter *prometheus.CounterVec
+}
+
+func (a *kubeResultAdapter) Increment(ctx context.Context, code string, method string, host string) {
+
+	// successful calls are counted by the latency histogram already
+	if len(code) == 3 && code[0] == '2' {
+		return
+	}
+
+	a.counter.WithLabelValues(method, host, code).Inc()
+}
@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+)
+
+type testCounter struct {
+	counts []SidecarCount
+	err    error
+}
+
+func (tc *testCounter) CountSidecars(ctx context.Context) ([]SidecarCount, error) {
+	return tc.counts, tc.err
+}
+
+func TestMiddleware(t *testing.T) {
+	m := New(logging.New())
+
+	router := gin.New()
+	router.Use(m.Middleware())
+	router.GET("/api/v2/namespaces/:namespace/deployments", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for _, path := range []string{"/api/v2/namespaces/payments/deployments", "/api/v2/namespaces/orders/deployments", "/missing"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	// Check that the requests are labeled by route, not by path
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.httpRequests.WithLabelValues("GET", "/api/v2/namespaces/:namespace/deployments", "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.httpRequests.WithLabelValues("GET", "unmatched", "404")))
+	assert.Equal(t, 2, testutil.CollectAndCount(m.httpDuration))
+}
+
+func TestObserveSidecarEvent(t *testing.T) {
+	m := New(logging.New())
+
+	m.ObserveSidecarEvent("production", "SidecarExpired")
+	m.ObserveSidecarEvent("production", "SidecarExpired")
+	m.ObserveSidecarEvent("production", "SidecarRollback")
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.sidecarEvents.WithLabelValues("production", "SidecarExpired")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.sidecarEvents.WithLabelValues("production", "SidecarRollback")))
+
+	var nilMetrics *Metrics
+	assert.NotPanics(t, func() { nilMetrics.ObserveSidecarEvent("production", "SidecarExpired") })
+}
+
+func TestKubeClientAdapters(t *testing.T) {
+	m := New(logging.New())
+
+	latency := &kubeLatencyAdapter{m.kubeDuration}
+	latency.Observe(context.Background(), "PUT", url.URL{Scheme: "https", Host: "10.0.0.1:6443", Path: "/apis/apps/v1/namespaces/payments/deployments/payments-api"}, 50*time.Millisecond)
+
+	result := &kubeResultAdapter{m.kubeErrors}
+	result.Increment(context.Background(), "200", "PUT", "10.0.0.1:6443")
+	result.Increment(context.Background(), "409", "PUT", "10.0.0.1:6443")
+	result.Increment(context.Background(), "<error>", "GET", "10.0.0.1:6443")
+
+	assert.Equal(t, 1, testutil.CollectAndCount(m.kubeDuration))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.kubeErrors.WithLabelValues("PUT", "10.0.0.1:6443", "409")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.kubeErrors.WithLabelValues("GET", "10.0.0.1:6443", "<error>")))
+	assert.Equal(t, 2, testutil.CollectAndCount(m.kubeErrors))
+}
+
+func TestActiveSidecars(t *testing.T) {
+	m := New(logging.New())
+	m.RegisterSidecarCounter(&testCounter{
+		counts: []SidecarCount{
+			{Cluster: "production", Namespace: "payments", Profile: "debug", Count: 2},
+			{Cluster: "production", Namespace: "orders", Count: 1},
+		},
+		// the counts of the reachable clusters are exposed anyway
+		err: errors.New("cluster staging: connection refused"),
+	})
+
+	expected := `
+# HELP kube_ondemand_sidecar_injector_active_sidecars Sidecars currently injected, by cluster, namespace and profile.
+# TYPE kube_ondemand_sidecar_injector_active_sidecars gauge
+kube_ondemand_sidecar_injector_active_sidecars{cluster="production",namespace="orders",profile=""} 1
+kube_ondemand_sidecar_injector_active_sidecars{cluster="production",namespace="payments",profile="debug"} 2
+`
+	assert.NoError(t, testutil.GatherAndCompare(m.registry, strings.NewReader(expected), "kube_ondemand_sidecar_injector_active_sidecars"))
+}
+
+func TestHandler(t *testing.T) {
+	m := New(logging.New())
+	m.ObserveSidecarEvent("production", "SidecarInjected")
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `kube_ondemand_sidecar_injector_sidecar_events_total{cluster="production",reason="SidecarInjected"} 1`)
+	assert.Contains(t, w.Body.String(), "go_goroutines")
+}
@@ -0,0 +1,38 @@
+package auditmodels
+
+import "time"
+
+type Query struct {
+	Since          time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Until          time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+	Cluster        string    `form:"cluster"`
+	Namespace      string    `form:"namespace"`
+	DeploymentName string    `form:"deploymentName"`
+	Limit          int       `form:"limit" binding:"min=0"`
+}
+
+// Matches reports whether the record satisfies the query filters
+func (q *Query) Matches(record *Record) bool {
+
+	if !q.Since.IsZero() && record.Time.Before(q.Since) {
+		return false
+	}
+
+	if !q.Until.IsZero() && record.Time.After(q.Until) {
+		return false
+	}
+
+	if q.Cluster != "" && record.Cluster != q.Cluster {
+		return false
+	}
+
+	if q.Namespace != "" && record.Namespace != q.Namespace {
+		return false
+	}
+
+	if q.DeploymentName != "" && record.DeploymentName != q.DeploymentName {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,29 @@
+package auditmodels
+
+import "time"
+
+type Record struct {
+	Time             time.Time `json:"Time"`
+	Operation        string    `json:"Operation"`
+	Caller           string    `json:"Caller"`
+	CallerGroups     []string  `json:"CallerGroups,omitempty"`
+	SourceIP         string    `json:"SourceIP"`
+	RequestID        string    `json:"RequestID,omitempty"`
+	Cluster          string    `json:"Cluster,omitempty"`
+	Namespace        string    `json:"Namespace"`
+	DeploymentName   string    `json:"DeploymentName"`
+	Payload          any       `json:"Payload" swaggertype:"object"`
+	ContainersBefore []string  `json:"ContainersBefore"`
+	ContainersAfter  []string  `json:"ContainersAfter"`
+	Result           string    `json:"Result"`
+	StatusCode       int       `json:"StatusCode"`
+	Error            string    `json:"Error,omitempty"`
+	DurationMs       int64     `json:"DurationMs"`
+}
+
+const (
+	ResultSucceeded = "Succeeded"
+	ResultFailed    = "Failed"
+	// some of the deployments of a call acting on many of them failed, while the others succeeded
+	ResultPartiallyFailed = "PartiallyFailed"
+)
@@ -0,0 +1,10 @@
+package clustermodels
+
+type Cluster struct {
+	Name      string `json:"Name"`
+	Default   bool   `json:"Default"`
+	Host      string `json:"Host"`
+	Reachable bool   `json:"Reachable"`
+	Version   string `json:"Version,omitempty"`
+	Error     string `json:"Error,omitempty"`
+}
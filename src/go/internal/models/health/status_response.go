@@ -0,0 +1,24 @@
+package healthmodels
+
+import (
+	"time"
+
+	clustermodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/clusters"
+)
+
+type Status struct {
+	// true when the readiness probe succeeds
+	Ready bool `json:"Ready"`
+	// why the injector is not ready, empty when ready
+	Reason      string    `json:"Reason,omitempty" example:"Deployment cache not synced"`
+	StartedAt   time.Time `json:"StartedAt"`
+	Uptime      string    `json:"Uptime" example:"26h3m4s"`
+	CacheSynced bool      `json:"CacheSynced"`
+	// identity of this replica, and of the leader running the background loops like the removal of the expired sidecars,
+	// empty while not known
+	Replica  string `json:"Replica" example:"kube-ondemand-sidecar-injector-7d9f8b6c5-x2k4p"`
+	Leader   string `json:"Leader" example:"kube-ondemand-sidecar-injector-7d9f8b6c5-q8j2m"`
+	IsLeader bool   `json:"IsLeader"`
+	// the clusters with the reachability and the version of their API server, their hosts omitted
+	Clusters []clustermodels.Cluster `json:"Clusters"`
+}
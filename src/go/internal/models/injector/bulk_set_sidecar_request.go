@@ -0,0 +1,20 @@
+package injectormodels
+
+// BulkSetSidecarPayload injects the same sidecar into all the deployments of the namespaces matching the LabelSelector, if any
+type BulkSetSidecarPayload struct {
+	Cluster              string   `json:"Cluster"`
+	Namespaces           []string `json:"Namespaces" binding:"required,min=1"`
+	LabelSelector        string   `json:"LabelSelector" example:"app.kubernetes.io/part-of=payments"`
+	SidecarContainerName string   `json:"SidecarContainerName" binding:"required"`
+	SidecarImage         string   `json:"SidecarImage" binding:"required"`
+	Command              []string `json:"Command"`
+	VolumeMounts         []Volume `json:"VolumeMounts"`
+	Profile              string   `json:"Profile"`
+	TTL                  string   `json:"TTL" example:"2h"`
+	Reason               string   `json:"Reason" example:"INC-1234 troubleshooting"`
+	// deployments injected at the same time, 4 when zero
+	Concurrency int `json:"Concurrency" binding:"min=0,max=16"`
+	// deployments injected first, one at a time, before the others; the others are skipped if any of them fails
+	Canaries int  `json:"Canaries" binding:"min=0"`
+	DryRun   bool `json:"DryRun"`
+}
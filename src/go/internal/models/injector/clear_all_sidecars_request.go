@@ -0,0 +1,11 @@
+package injectormodels
+
+// ClearAllSidecarsPayload targets the named deployment or, when DeploymentName is empty,
+// all the deployments of the namespace matching the LabelSelector, if any
+type ClearAllSidecarsPayload struct {
+	Cluster        string `json:"Cluster"`
+	Namespace      string `json:"Namespace" binding:"required"`
+	DeploymentName string `json:"DeploymentName"`
+	LabelSelector  string `json:"LabelSelector" example:"app.kubernetes.io/part-of=payments"`
+	DryRun         bool   `json:"DryRun"`
+}
@@ -1,7 +1,9 @@
 package injectormodels
 
 type ClearSidecarPayload struct {
+	Cluster              string `json:"Cluster"`
 	Namespace            string `json:"Namespace" binding:"required"`
 	DeploymentName       string `json:"DeploymentName" binding:"required"`
 	SidecarContainerName string `json:"SidecarContainerName" binding:"required"`
+	DryRun               bool   `json:"DryRun"`
 }
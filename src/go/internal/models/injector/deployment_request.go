@@ -1,12 +1,16 @@
 package injectormodels
 
 type GetDeploymentsPayload struct {
+	Cluster                        string `json:"Cluster"`
 	Namespace                      string `json:"Namespace" binding:"required"`
+	Consistent                     bool   `json:"Consistent"`
 	Filtered                       bool   `json:"Filtered"`
 	DeploymentNameSubstringPattern string `json:"DeploymentNameSubstringPattern"`
 }
 
 type GetSingleDeploymentPayload struct {
+	Cluster        string `json:"Cluster"`
 	Namespace      string `json:"Namespace" binding:"required"`
 	DeploymentName string `json:"DeploymentName" binding:"required"`
+	Consistent     bool   `json:"Consistent"`
 }
@@ -1,9 +1,13 @@
 package injectormodels
 
 type Deployment struct {
-	Namespace   string   `json:"Namespace"`
-	Name        string   `json:"Name"`
-	VolumeNames []string `json:"VolumeNames"`
+	Cluster          string            `json:"Cluster"`
+	Namespace        string            `json:"Namespace"`
+	Name             string            `json:"Name"`
+	VolumeNames      []string          `json:"VolumeNames"`
+	ContainerNames   []string          `json:"ContainerNames"`
+	InjectedSidecars []InjectedSidecar `json:"InjectedSidecars"`
+	DryRun           *DryRunPreview    `json:"DryRun,omitempty"`
 }
 
 // list of deployment
@@ -0,0 +1,9 @@
+package injectormodels
+
+// DryRunPreview describes the outcome of a dry-run call, which persists nothing
+type DryRunPreview struct {
+	// pod template of the Deployment as it would result from the call
+	PodTemplate any `json:"PodTemplate" swaggertype:"object"`
+	// unified diff of the current pod template, in YAML, against the resulting one
+	Diff string `json:"Diff"`
+}
@@ -0,0 +1,16 @@
+package injectormodels
+
+import "time"
+
+// HistoryEntry describes a snapshot of the pod template of a Deployment, taken before a change made by the injector
+type HistoryEntry struct {
+	Revision int       `json:"Revision"`
+	Time     time.Time `json:"Time"`
+	// operation whose change followed the snapshot
+	Operation     string `json:"Operation" example:"SetSidecar"`
+	ContainerName string `json:"ContainerName,omitempty"`
+	PerformedBy   string `json:"PerformedBy"`
+	// containers of the pod template in the snapshot
+	ContainerNames []string `json:"ContainerNames"`
+	PodTemplate    any      `json:"PodTemplate" swaggertype:"object"`
+}
@@ -0,0 +1,16 @@
+package injectormodels
+
+type GetHistoryPayload struct {
+	Cluster        string `json:"Cluster"`
+	Namespace      string `json:"Namespace" binding:"required"`
+	DeploymentName string `json:"DeploymentName" binding:"required"`
+	Consistent     bool   `json:"Consistent"`
+}
+
+type RestoreSnapshotPayload struct {
+	Cluster        string `json:"Cluster"`
+	Namespace      string `json:"Namespace" binding:"required"`
+	DeploymentName string `json:"DeploymentName" binding:"required"`
+	Revision       int    `json:"Revision" binding:"required" example:"3"`
+	DryRun         bool   `json:"DryRun"`
+}
@@ -0,0 +1,15 @@
+package injectormodels
+
+import "time"
+
+// InjectedSidecar describes a sidecar injected on demand, as recorded in the Deployment annotations
+type InjectedSidecar struct {
+	ContainerName string     `json:"ContainerName"`
+	Image         string     `json:"Image"`
+	InjectedBy    string     `json:"InjectedBy"`
+	InjectedAt    time.Time  `json:"InjectedAt"`
+	Profile       string     `json:"Profile,omitempty"`
+	TTL           string     `json:"TTL,omitempty"`
+	ExpiresAt     *time.Time `json:"ExpiresAt,omitempty"`
+	Reason        string     `json:"Reason,omitempty"`
+}
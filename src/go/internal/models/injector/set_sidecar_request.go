@@ -1,15 +1,32 @@
 package injectormodels
 
 type SetSidecarPayload struct {
+	Cluster              string   `json:"Cluster"`
 	Namespace            string   `json:"Namespace" binding:"required"`
 	DeploymentName       string   `json:"DeploymentName" binding:"required"`
 	SidecarContainerName string   `json:"SidecarContainerName" binding:"required"`
 	SidecarImage         string   `json:"SidecarImage" binding:"required"`
 	Command              []string `json:"Command"`
 	VolumeMounts         []Volume `json:"VolumeMounts"`
+	Profile              string   `json:"Profile"`
+	TTL                  string   `json:"TTL" example:"2h"`
+	Reason               string   `json:"Reason" example:"INC-1234 troubleshooting"`
+	DryRun               bool     `json:"DryRun"`
+	// succeed without changes when the sidecar is already injected as described, as the retries of a PUT expect
+	AcceptExisting bool `json:"-"`
 }
 
 type Volume struct {
 	Name      string `json:"Name" binding:"required"`
 	MountPath string `json:"MountPath" binding:"required"`
 }
+
+// SidecarSpec is the body of the v2 API call injecting a sidecar, whose deployment and name are given by the url path
+type SidecarSpec struct {
+	SidecarImage string   `json:"SidecarImage" binding:"required"`
+	Command      []string `json:"Command"`
+	VolumeMounts []Volume `json:"VolumeMounts"`
+	Profile      string   `json:"Profile"`
+	TTL          string   `json:"TTL" example:"2h"`
+	Reason       string   `json:"Reason" example:"INC-1234 troubleshooting"`
+}
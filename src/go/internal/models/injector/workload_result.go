@@ -0,0 +1,15 @@
+package injectormodels
+
+// WorkloadResult describes the outcome of an operation acting on many deployments, for one of them
+type WorkloadResult struct {
+	Cluster        string `json:"Cluster"`
+	Namespace      string `json:"Namespace"`
+	DeploymentName string `json:"DeploymentName"`
+	// sidecar containers changed by the operation on the deployment
+	Containers []string `json:"Containers"`
+	// resulting deployment, missing on error
+	Deployment *Deployment `json:"Deployment,omitempty"`
+	Error      string      `json:"Error,omitempty"`
+	// the operation did not act on the deployment, as reported by Error
+	Skipped bool `json:"Skipped,omitempty"`
+}
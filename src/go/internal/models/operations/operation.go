@@ -0,0 +1,42 @@
+package operationsmodels
+
+import "time"
+
+type Operation struct {
+	ID        string     `json:"ID" example:"1f0c6b9a4e2d8c7b5a3f1e0d9c8b7a6f"`
+	Operation string     `json:"Operation" example:"BulkSetSidecar"`
+	Status    string     `json:"Status" example:"Running"`
+	Caller    string     `json:"Caller"`
+	RequestID string     `json:"RequestID,omitempty"`
+	CreatedAt time.Time  `json:"CreatedAt"`
+	UpdatedAt time.Time  `json:"UpdatedAt"`
+	Progress  Progress   `json:"Progress"`
+	Logs      []LogEntry `json:"Logs"`
+	// response body the call would have had if run synchronously, set once finished
+	Result any `json:"Result,omitempty" swaggertype:"object"`
+	// http status the call would have had if run synchronously, set once finished
+	StatusCode int    `json:"StatusCode,omitempty"`
+	Error      string `json:"Error,omitempty"`
+}
+
+// Progress counts the steps of the operation, when known
+type Progress struct {
+	Done  int `json:"Done"`
+	Total int `json:"Total"`
+}
+
+type LogEntry struct {
+	Time    time.Time `json:"Time"`
+	Message string    `json:"Message"`
+}
+
+const (
+	StatusRunning   = "Running"
+	StatusSucceeded = "Succeeded"
+	StatusFailed    = "Failed"
+)
+
+// Finished reports whether the operation is over, successfully or not
+func (o *Operation) Finished() bool {
+	return o.Status == StatusSucceeded || o.Status == StatusFailed
+}
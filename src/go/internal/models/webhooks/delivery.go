@@ -0,0 +1,24 @@
+package webhooksmodels
+
+import "time"
+
+type Delivery struct {
+	ID        string `json:"ID"`
+	EventID   string `json:"EventID"`
+	EventType string `json:"EventType" example:"SidecarInjected"`
+	// name of the webhook the event is delivered to
+	Webhook  string `json:"Webhook" example:"chatops"`
+	Status   string `json:"Status" example:"Delivered"`
+	Attempts int    `json:"Attempts"`
+	// http status of the last attempt, zero when no response was received
+	StatusCode int       `json:"StatusCode,omitempty"`
+	Error      string    `json:"Error,omitempty"`
+	CreatedAt  time.Time `json:"CreatedAt"`
+	UpdatedAt  time.Time `json:"UpdatedAt"`
+}
+
+const (
+	DeliveryPending   = "Pending"
+	DeliveryDelivered = "Delivered"
+	DeliveryFailed    = "Failed"
+)
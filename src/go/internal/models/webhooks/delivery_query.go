@@ -0,0 +1,5 @@
+package webhooksmodels
+
+type DeliveryQuery struct {
+	Limit int `form:"limit" binding:"min=0"`
+}
@@ -0,0 +1,18 @@
+package webhooksmodels
+
+import "time"
+
+// Event is the body of the generic JSON webhooks, notifying a step of the sidecar lifecycle
+type Event struct {
+	ID   string    `json:"ID" example:"5d41402abc4b2a76b9719d911017c592"`
+	Time time.Time `json:"Time"`
+	// reason of the Kubernetes Event recorded along, like SidecarInjected
+	Type           string `json:"Type" example:"SidecarInjected"`
+	Cluster        string `json:"Cluster,omitempty"`
+	Namespace      string `json:"Namespace"`
+	DeploymentName string `json:"DeploymentName"`
+	ContainerName  string `json:"ContainerName,omitempty"`
+	Image          string `json:"Image,omitempty"`
+	Caller         string `json:"Caller"`
+	Message        string `json:"Message"`
+}
@@ -0,0 +1,176 @@
+package operations
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	operationsmodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/operations"
+)
+
+// FileStore saves every operation as a JSON file of a folder, so that operations survive restarts
+type FileStore struct {
+	mutex    sync.Mutex
+	dir      string
+	capacity int
+	// IDs in order of creation, to remove the oldest finished operations first
+	ids []string
+}
+
+// NewFileStore creates a new instance of the FileStore saving to the given folder up to capacity operations, beyond
+// which the files of the oldest finished ones are removed; the running ones are always kept.
+// The operations left running by a previous process are marked as failed, as nobody is going to finish them
+func NewFileStore(dir string, capacity int) (*FileStore, error) {
+
+	if dir == "" {
+		return nil, errors.New("operations folder is required")
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	store := &FileStore{dir: dir, capacity: capacity}
+
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (s *FileStore) Save(operation *operationsmodels.Operation) error {
+
+	content, err := json.Marshal(operation)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, err = os.Stat(s.path(operation.ID))
+	created := errors.Is(err, os.ErrNotExist)
+
+	// write and rename, so that readers never see a partially written file
+	temp := s.path(operation.ID) + ".tmp"
+	if err := os.WriteFile(temp, content, 0600); err != nil {
+		return err
+	}
+
+	if err := os.Rename(temp, s.path(operation.ID)); err != nil {
+		return err
+	}
+
+	if created {
+		s.ids = append(s.ids, operation.ID)
+	}
+
+	return s.prune()
+}
+
+func (s *FileStore) Get(id string) (*operationsmodels.Operation, error) {
+
+	// IDs are hex strings, anything else must not reach the file system
+	if _, err := hex.DecodeString(id); err != nil || id == "" {
+		return nil, ErrOperationNotFound
+	}
+
+	content, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrOperationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var operation operationsmodels.Operation
+	if err := json.Unmarshal(content, &operation); err != nil {
+		return nil, err
+	}
+
+	return &operation, nil
+}
+
+// private functions and methods
+
+func (s *FileStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// load reads the operations of the folder in order of creation, marking as failed the ones still running according to it,
+// and removes the oldest finished ones beyond the capacity
+func (s *FileStore) load() error {
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+
+	operations := make([]*operationsmodels.Operation, 0, len(entries))
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		operation, err := s.Get(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			continue
+		}
+		operations = append(operations, operation)
+
+		if operation.Finished() {
+			continue
+		}
+
+		operation.Status = operationsmodels.StatusFailed
+		operation.Error = "interrupted by a restart"
+		operation.UpdatedAt = time.Now().UTC()
+
+		if err := s.Save(operation); err != nil {
+			return err
+		}
+	}
+
+	sort.SliceStable(operations, func(i, j int) bool {
+		return operations[i].CreatedAt.Before(operations[j].CreatedAt)
+	})
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.ids = make([]string, len(operations))
+	for i, operation := range operations {
+		s.ids[i] = operation.ID
+	}
+
+	return s.prune()
+}
+
+// prune removes the files of the oldest finished operations beyond the capacity, skipping the running ones
+func (s *FileStore) prune() error {
+
+	for i := 0; s.capacity > 0 && len(s.ids) > s.capacity && i < len(s.ids); {
+
+		operation, err := s.Get(s.ids[i])
+		if err == nil && !operation.Finished() {
+			i++
+			continue
+		}
+
+		if err := os.Remove(s.path(s.ids[i])); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		s.ids = append(s.ids[:i], s.ids[i+1:]...)
+	}
+
+	return nil
+}
@@ -0,0 +1,245 @@
+package operations
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+	operationsmodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/operations"
+)
+
+var ErrOperationNotFound = errors.New("operation not found")
+
+// ErrInterrupted is the error of the operations cancelled by Shutdown
+var ErrInterrupted = errors.New("operation interrupted by the injector shutdown")
+
+// IStore persists operations and permits to get them back by ID
+type IStore interface {
+	Save(operation *operationsmodels.Operation) error
+	Get(id string) (*operationsmodels.Operation, error)
+}
+
+// Func performs the work of an operation, returning the response body the call would have had if run synchronously
+// and its http status
+type Func func(ctx context.Context) (result any, statusCode int, err error)
+
+type Manager struct {
+	logger *logging.Logger
+	store  IStore
+	wg     sync.WaitGroup
+	// parent of the contexts of the operations, cancelled by Shutdown
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+}
+
+// NewManager creates a new instance of the Manager saving operations to the given store
+func New(logger *logging.Logger, store IStore) *Manager {
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+
+	return &Manager{
+		logger: logger,
+		store:  store,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start runs the function in background, detached from the request that started it, and returns the operation tracking it.
+// The context of the function carries the Tracker of the operation, so that the function can report progress and steps
+func (m *Manager) Start(operation string, caller string, requestID string, run Func) *operationsmodels.Operation {
+
+	now := time.Now().UTC()
+
+	tracker := &Tracker{
+		manager: m,
+		operation: operationsmodels.Operation{
+			ID:        newOperationID(),
+			Operation: operation,
+			Status:    operationsmodels.StatusRunning,
+			Caller:    caller,
+			RequestID: requestID,
+			CreatedAt: now,
+			UpdatedAt: now,
+			Logs:      make([]operationsmodels.LogEntry, 0),
+		},
+	}
+
+	started := tracker.save()
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		result, statusCode, err := run(WithTracker(m.ctx, tracker))
+		if err != nil && errors.Is(context.Cause(m.ctx), ErrInterrupted) {
+			err = fmt.Errorf("%w: %v", ErrInterrupted, err)
+		}
+		tracker.finish(result, statusCode, err)
+	}()
+
+	m.logger.Log().Info("Operation started", zap.String("id", started.ID), zap.String("operation", operation), zap.String("caller", caller))
+
+	return started
+}
+
+// Get returns the operation with the given ID
+func (m *Manager) Get(id string) (*operationsmodels.Operation, error) {
+	return m.store.Get(id)
+}
+
+// Wait blocks until all the running operations are finished
+func (m *Manager) Wait() {
+	m.wg.Wait()
+}
+
+// Shutdown waits for the running operations to finish until ctx is done, then cancels the remaining ones and waits
+// for them to return, so that every operation is saved as finished, the cancelled ones failed with ErrInterrupted.
+// It returns the error of ctx when operations had to be cancelled
+func (m *Manager) Shutdown(ctx context.Context) error {
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	m.logger.Log().Warn("Shutdown deadline reached, cancelling the running operations")
+	m.cancel(ErrInterrupted)
+	<-done
+
+	return ctx.Err()
+}
+
+// Tracker records the progress of a running operation, saving it at every change.
+// All its methods do nothing on a nil Tracker, so that functions can report progress whether run as operations or not
+type Tracker struct {
+	manager   *Manager
+	mutex     sync.Mutex
+	operation operationsmodels.Operation
+}
+
+type trackerKey struct{}
+
+// WithTracker returns a copy of the context carrying the tracker
+func WithTracker(ctx context.Context, tracker *Tracker) context.Context {
+	return context.WithValue(ctx, trackerKey{}, tracker)
+}
+
+// TrackerFrom returns the tracker carried by the context, or nil if the context does not belong to an operation
+func TrackerFrom(ctx context.Context) *Tracker {
+	tracker, _ := ctx.Value(trackerKey{}).(*Tracker)
+	return tracker
+}
+
+// SetTotal sets the count of the steps of the operation
+func (t *Tracker) SetTotal(total int) {
+
+	if t == nil {
+		return
+	}
+
+	t.update(func(operation *operationsmodels.Operation) {
+		operation.Progress.Total = total
+	})
+}
+
+// Step records the completion of a step, described by the message
+func (t *Tracker) Step(message string) {
+
+	if t == nil {
+		return
+	}
+
+	t.update(func(operation *operationsmodels.Operation) {
+		operation.Progress.Done++
+		operation.Logs = append(operation.Logs, operationsmodels.LogEntry{Time: time.Now().UTC(), Message: message})
+	})
+}
+
+// Log records the message in the logs of the operation, without completing a step
+func (t *Tracker) Log(message string) {
+
+	if t == nil {
+		return
+	}
+
+	t.update(func(operation *operationsmodels.Operation) {
+		operation.Logs = append(operation.Logs, operationsmodels.LogEntry{Time: time.Now().UTC(), Message: message})
+	})
+}
+
+// private functions and methods
+
+func (t *Tracker) finish(result any, statusCode int, err error) {
+
+	t.update(func(operation *operationsmodels.Operation) {
+		operation.Result = result
+		operation.StatusCode = statusCode
+
+		if err != nil || statusCode >= http.StatusBadRequest {
+			operation.Status = operationsmodels.StatusFailed
+		} else {
+			operation.Status = operationsmodels.StatusSucceeded
+		}
+
+		if err != nil {
+			operation.Error = err.Error()
+		}
+	})
+
+	t.manager.logger.Log().Info("Operation finished", zap.String("id", t.operation.ID), zap.String("operation", t.operation.Operation), zap.String("status", t.operation.Status), zap.Int("statusCode", statusCode))
+}
+
+func (t *Tracker) update(change func(operation *operationsmodels.Operation)) {
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	change(&t.operation)
+	t.operation.UpdatedAt = time.Now().UTC()
+
+	t.saveLocked()
+}
+
+func (t *Tracker) save() *operationsmodels.Operation {
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.saveLocked()
+}
+
+// saveLocked saves a copy of the operation, so that the store never shares memory with the tracker
+func (t *Tracker) saveLocked() *operationsmodels.Operation {
+
+	saved := t.operation
+	saved.Logs = append(make([]operationsmodels.LogEntry, 0, len(t.operation.Logs)), t.operation.Logs...)
+
+	if err := t.manager.store.Save(&saved); err != nil {
+		// failing to save progress is not worth interrupting the operation
+		t.manager.logger.Log().Error("Error saving operation", zap.String("id", saved.ID), zap.Error(err))
+	}
+
+	return &saved
+}
+
+func newOperationID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,244 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+	operationsmodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/operations"
+)
+
+func TestManager(t *testing.T) {
+	manager := New(logging.New(), NewMemoryStore(10))
+
+	release := make(chan struct{})
+	started := manager.Start("BulkSetSidecar", "jane", "test-request-id", func(ctx context.Context) (any, int, error) {
+		tracker := TrackerFrom(ctx)
+		tracker.SetTotal(2)
+		tracker.Step("payments/payments-api: injected prefix-tracer")
+		<-release
+		tracker.Log("waiting for the rollout")
+		tracker.Step("payments/payments-worker: injected prefix-tracer")
+		return "done", http.StatusOK, nil
+	})
+
+	assert.Equal(t, operationsmodels.StatusRunning, started.Status)
+	assert.Equal(t, "jane", started.Caller)
+	assert.Equal(t, "test-request-id", started.RequestID)
+	assert.Len(t, started.ID, 32)
+
+	close(release)
+	manager.Wait()
+
+	operation, err := manager.Get(started.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, operationsmodels.StatusSucceeded, operation.Status)
+	assert.Equal(t, operationsmodels.Progress{Done: 2, Total: 2}, operation.Progress)
+	assert.Len(t, operation.Logs, 3)
+	assert.Equal(t, "done", operation.Result)
+	assert.Equal(t, http.StatusOK, operation.StatusCode)
+}
+
+func TestManagerFailedOperation(t *testing.T) {
+	testCases := []struct {
+		name          string
+		statusCode    int
+		err           error
+		expectedError string
+	}{
+		{"error", http.StatusInternalServerError, errors.New("boom"), "boom"},
+		{"error status", http.StatusConflict, nil, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			manager := New(logging.New(), NewMemoryStore(10))
+
+			started := manager.Start("SetSidecar", "jane", "", func(ctx context.Context) (any, int, error) {
+				return nil, tc.statusCode, tc.err
+			})
+			manager.Wait()
+
+			operation, err := manager.Get(started.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, operationsmodels.StatusFailed, operation.Status)
+			assert.Equal(t, tc.statusCode, operation.StatusCode)
+			assert.Equal(t, tc.expectedError, operation.Error)
+		})
+	}
+}
+
+func TestManagerShutdown(t *testing.T) {
+	manager := New(logging.New(), NewMemoryStore(10))
+
+	finished := manager.Start("SetSidecar", "jane", "", func(ctx context.Context) (any, int, error) {
+		return "done", http.StatusOK, nil
+	})
+
+	// Check that nothing is cancelled when the operations finish in time
+	assert.NoError(t, manager.Shutdown(context.Background()))
+
+	stuck := manager.Start("BulkSetSidecar", "jane", "", func(ctx context.Context) (any, int, error) {
+		<-ctx.Done()
+		return nil, http.StatusInternalServerError, ctx.Err()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// Check that the operations still running at the deadline are cancelled and saved as interrupted
+	assert.ErrorIs(t, manager.Shutdown(ctx), context.DeadlineExceeded)
+
+	operation, err := manager.Get(finished.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, operationsmodels.StatusSucceeded, operation.Status)
+
+	operation, err = manager.Get(stuck.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, operationsmodels.StatusFailed, operation.Status)
+	assert.Equal(t, "operation interrupted by the injector shutdown: context canceled", operation.Error)
+}
+
+func TestTrackerWithoutOperation(t *testing.T) {
+	tracker := TrackerFrom(context.Background())
+
+	// Check that reporting progress outside of an operation does nothing
+	assert.Nil(t, tracker)
+	assert.NotPanics(t, func() {
+		tracker.SetTotal(1)
+		tracker.Step("step")
+		tracker.Log("log")
+	})
+}
+
+func TestMemoryStoreCapacity(t *testing.T) {
+	store := NewMemoryStore(2)
+
+	for _, id := range []string{"01", "02", "03"} {
+		assert.NoError(t, store.Save(&operationsmodels.Operation{ID: id, Status: operationsmodels.StatusSucceeded}))
+	}
+
+	// Check that the oldest operation is evicted
+	_, err := store.Get("01")
+	assert.ErrorIs(t, err, ErrOperationNotFound)
+
+	operation, err := store.Get("03")
+	assert.NoError(t, err)
+	assert.Equal(t, "03", operation.ID)
+}
+
+func TestMemoryStoreCapacityKeepsRunning(t *testing.T) {
+	store := NewMemoryStore(2)
+
+	assert.NoError(t, store.Save(&operationsmodels.Operation{ID: "01", Status: operationsmodels.StatusRunning}))
+	for _, id := range []string{"02", "03", "04"} {
+		assert.NoError(t, store.Save(&operationsmodels.Operation{ID: id, Status: operationsmodels.StatusSucceeded}))
+	}
+
+	// Check that the oldest finished operations are evicted, while the running one is kept
+	for id, kept := range map[string]bool{"01": true, "02": false, "03": false, "04": true} {
+		_, err := store.Get(id)
+		if kept {
+			assert.NoError(t, err, id)
+		} else {
+			assert.ErrorIs(t, err, ErrOperationNotFound, id)
+		}
+	}
+
+	// Check that the progress of the running operation keeps its place, and it is evicted once finished and the oldest
+	assert.NoError(t, store.Save(&operationsmodels.Operation{ID: "01", Status: operationsmodels.StatusSucceeded}))
+	assert.NoError(t, store.Save(&operationsmodels.Operation{ID: "05", Status: operationsmodels.StatusSucceeded}))
+
+	_, err := store.Get("01")
+	assert.ErrorIs(t, err, ErrOperationNotFound)
+	_, err = store.Get("04")
+	assert.NoError(t, err)
+}
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewFileStore(dir, 10)
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.Save(&operationsmodels.Operation{ID: "01", Status: operationsmodels.StatusRunning}))
+	assert.NoError(t, store.Save(&operationsmodels.Operation{ID: "02", Status: operationsmodels.StatusSucceeded}))
+
+	operation, err := store.Get("02")
+	assert.NoError(t, err)
+	assert.Equal(t, operationsmodels.StatusSucceeded, operation.Status)
+
+	for _, id := range []string{"03", "../01", ""} {
+		_, err = store.Get(id)
+		assert.ErrorIs(t, err, ErrOperationNotFound, id)
+	}
+
+	// Check that a new store fails the operations left running, as after a restart
+	store, err = NewFileStore(dir, 10)
+	assert.NoError(t, err)
+
+	operation, err = store.Get("01")
+	assert.NoError(t, err)
+	assert.Equal(t, operationsmodels.StatusFailed, operation.Status)
+	assert.Equal(t, "interrupted by a restart", operation.Error)
+
+	operation, err = store.Get("02")
+	assert.NoError(t, err)
+	assert.Equal(t, operationsmodels.StatusSucceeded, operation.Status)
+
+	_, err = os.Stat(filepath.Join(dir, "01.json.tmp"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestFileStoreRetention(t *testing.T) {
+	dir := t.TempDir()
+	createdAt := time.Now().UTC()
+
+	store, err := NewFileStore(dir, 3)
+	assert.NoError(t, err)
+
+	// the oldest operation is still running
+	assert.NoError(t, store.Save(&operationsmodels.Operation{ID: "01", Status: operationsmodels.StatusRunning, CreatedAt: createdAt}))
+	for i, id := range []string{"02", "03", "04", "05"} {
+		assert.NoError(t, store.Save(&operationsmodels.Operation{ID: id, Status: operationsmodels.StatusSucceeded, CreatedAt: createdAt.Add(time.Duration(i+1) * time.Second)}))
+	}
+
+	// Check that the oldest finished operations are removed beyond the capacity, while the running one is kept
+	for id, kept := range map[string]bool{"01": true, "02": false, "03": false, "04": true, "05": true} {
+		_, err := os.Stat(filepath.Join(dir, id+".json"))
+		if kept {
+			assert.NoError(t, err, id)
+		} else {
+			assert.ErrorIs(t, err, os.ErrNotExist, id)
+		}
+	}
+
+	// Check that updating an operation does not count it twice
+	assert.NoError(t, store.Save(&operationsmodels.Operation{ID: "05", Status: operationsmodels.StatusFailed, CreatedAt: createdAt.Add(4 * time.Second)}))
+	_, err = store.Get("04")
+	assert.NoError(t, err)
+
+	// Check that a new store with a lower capacity removes the oldest operations, by creation time,
+	// including the one failed as interrupted by the restart
+	store, err = NewFileStore(dir, 1)
+	assert.NoError(t, err)
+
+	for _, id := range []string{"01", "04"} {
+		_, err = store.Get(id)
+		assert.ErrorIs(t, err, ErrOperationNotFound, id)
+	}
+	_, err = store.Get("05")
+	assert.NoError(t, err)
+}
+
+func TestNewFileStoreWithoutDir(t *testing.T) {
+	_, err := NewFileStore("", 10)
+	assert.Error(t, err)
+}
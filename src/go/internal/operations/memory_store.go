@@ -0,0 +1,66 @@
+package operations
+
+import (
+	"sync"
+
+	operationsmodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/operations"
+)
+
+// MemoryStore keeps the most recent operations in memory, losing them on restart
+type MemoryStore struct {
+	mutex      sync.Mutex
+	capacity   int
+	operations map[string]*operationsmodels.Operation
+	// IDs in order of creation, to evict the oldest finished operations first
+	ids []string
+}
+
+// NewMemoryStore creates a new instance of the MemoryStore keeping up to capacity operations, beyond which the oldest
+// finished ones are evicted; the running ones are always kept
+func NewMemoryStore(capacity int) *MemoryStore {
+	return &MemoryStore{
+		capacity:   capacity,
+		operations: make(map[string]*operationsmodels.Operation),
+		ids:        make([]string, 0),
+	}
+}
+
+func (s *MemoryStore) Save(operation *operationsmodels.Operation) error {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, found := s.operations[operation.ID]; !found {
+		s.ids = append(s.ids, operation.ID)
+	}
+
+	saved := *operation
+	s.operations[operation.ID] = &saved
+
+	// the running operations are kept, as their callers are still polling them
+	for i := 0; s.capacity > 0 && len(s.ids) > s.capacity && i < len(s.ids); {
+		if !s.operations[s.ids[i]].Finished() {
+			i++
+			continue
+		}
+
+		delete(s.operations, s.ids[i])
+		s.ids = append(s.ids[:i], s.ids[i+1:]...)
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) Get(id string) (*operationsmodels.Operation, error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	operation, found := s.operations[id]
+	if !found {
+		return nil, ErrOperationNotFound
+	}
+
+	result := *operation
+	return &result, nil
+}
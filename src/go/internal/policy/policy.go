@@ -0,0 +1,109 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// DefaultOptInKey is the label or annotation opting a Deployment or a namespace in, when OptInKey is not configured
+const DefaultOptInKey = "ondemand-sidecar-injector.alesspanms.github.io/enabled"
+
+var (
+	// ErrNamespaceNotAllowed is returned when the namespace is denied or not allowed by the policy
+	ErrNamespaceNotAllowed = errors.New("namespace not allowed")
+
+	// ErrNotOptedIn is returned when opt-in is required and neither the Deployment nor its namespace opted in
+	ErrNotOptedIn = errors.New("deployment not opted in")
+)
+
+// Policy defines which workloads may receive sidecars.
+// Namespace lists accept shell patterns like "team-*"; an empty AllowedNamespaces list allows every namespace not denied
+type Policy struct {
+	AllowedNamespaces []string
+	DeniedNamespaces  []string
+	OptInRequired     bool
+	OptInKey          string
+	OptInValue        string
+}
+
+// Validate checks the namespace patterns and fills the opt-in defaults
+func (p *Policy) Validate() error {
+
+	for _, pattern := range append(append([]string{}, p.AllowedNamespaces...), p.DeniedNamespaces...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid namespace pattern '%s': %w", pattern, err)
+		}
+	}
+
+	if p.OptInKey == "" {
+		p.OptInKey = DefaultOptInKey
+	}
+
+	if p.OptInValue == "" {
+		p.OptInValue = "true"
+	}
+
+	return nil
+}
+
+// CheckNamespace returns ErrNamespaceNotAllowed when the namespace is denied or not in the allowed list
+func (p *Policy) CheckNamespace(namespace string) error {
+
+	if matchesAny(p.DeniedNamespaces, namespace) {
+		return fmt.Errorf("%w: namespace %s is denied", ErrNamespaceNotAllowed, namespace)
+	}
+
+	if len(p.AllowedNamespaces) > 0 && !matchesAny(p.AllowedNamespaces, namespace) {
+		return fmt.Errorf("%w: namespace %s is not in the allowed list", ErrNamespaceNotAllowed, namespace)
+	}
+
+	return nil
+}
+
+// AllowedNamespaceNames returns the allowed namespaces when all of them are named without patterns, false when some
+// namespace is allowed by pattern or every namespace not denied is allowed. It is always false on a nil Policy
+func (p *Policy) AllowedNamespaceNames() ([]string, bool) {
+
+	if p == nil || len(p.AllowedNamespaces) == 0 {
+		return nil, false
+	}
+
+	for _, namespace := range p.AllowedNamespaces {
+		if strings.ContainsAny(namespace, `*?[\`) {
+			return nil, false
+		}
+	}
+
+	return p.AllowedNamespaces, true
+}
+
+// OptedIn reports whether an object with the given labels and annotations carries the opt-in key.
+// It is always true when opt-in is not required, or on a nil Policy
+func (p *Policy) OptedIn(labels map[string]string, annotations map[string]string) bool {
+
+	if p == nil || !p.OptInRequired {
+		return true
+	}
+
+	return labels[p.OptInKey] == p.OptInValue || annotations[p.OptInKey] == p.OptInValue
+}
+
+// NotOptedInError returns the error describing a Deployment refused because it did not opt in
+func (p *Policy) NotOptedInError(namespace string, name string) error {
+	return fmt.Errorf("%w: deployment %s/%s and its namespace lack the label or annotation %s=%s", ErrNotOptedIn, namespace, name, p.OptInKey, p.OptInValue)
+}
+
+// private functions and methods
+
+func matchesAny(patterns []string, namespace string) bool {
+
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, namespace); matched {
+			return true
+		}
+	}
+
+	return false
+}
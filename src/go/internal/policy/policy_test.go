@@ -0,0 +1,95 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	p := &Policy{AllowedNamespaces: []string{"team-*"}}
+
+	assert.NoError(t, p.Validate())
+	assert.Equal(t, DefaultOptInKey, p.OptInKey)
+	assert.Equal(t, "true", p.OptInValue)
+
+	p = &Policy{DeniedNamespaces: []string{"team-["}}
+	assert.Error(t, p.Validate())
+}
+
+func TestCheckNamespace(t *testing.T) {
+	p := &Policy{
+		AllowedNamespaces: []string{"team-*", "payments"},
+		DeniedNamespaces:  []string{"team-secret"},
+	}
+	assert.NoError(t, p.Validate())
+
+	testCases := []struct {
+		namespace string
+		allowed   bool
+	}{
+		{"payments", true},
+		{"team-orders", true},
+		{"team-secret", false},
+		{"kube-system", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.namespace, func(t *testing.T) {
+			err := p.CheckNamespace(tc.namespace)
+			if tc.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.True(t, errors.Is(err, ErrNamespaceNotAllowed))
+			}
+		})
+	}
+}
+
+func TestCheckNamespaceWithoutAllowedList(t *testing.T) {
+	p := &Policy{DeniedNamespaces: []string{"kube-*"}}
+	assert.NoError(t, p.Validate())
+
+	assert.NoError(t, p.CheckNamespace("payments"))
+	assert.Error(t, p.CheckNamespace("kube-system"))
+}
+
+func TestAllowedNamespaceNames(t *testing.T) {
+	testCases := []struct {
+		name     string
+		policy   *Policy
+		expected []string
+		named    bool
+	}{
+		{"named", &Policy{AllowedNamespaces: []string{"payments", "orders"}}, []string{"payments", "orders"}, true},
+		{"pattern", &Policy{AllowedNamespaces: []string{"payments", "team-*"}}, nil, false},
+		{"without allowed list", &Policy{DeniedNamespaces: []string{"kube-system"}}, nil, false},
+		{"nil policy", nil, nil, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			namespaces, named := tc.policy.AllowedNamespaceNames()
+			assert.Equal(t, tc.expected, namespaces)
+			assert.Equal(t, tc.named, named)
+		})
+	}
+}
+
+func TestOptedIn(t *testing.T) {
+	p := &Policy{}
+	assert.NoError(t, p.Validate())
+
+	// Check that everything is opted in when opt-in is not required
+	assert.True(t, p.OptedIn(nil, nil))
+
+	p.OptInRequired = true
+	assert.False(t, p.OptedIn(nil, nil))
+	assert.True(t, p.OptedIn(map[string]string{DefaultOptInKey: "true"}, nil))
+	assert.True(t, p.OptedIn(nil, map[string]string{DefaultOptInKey: "true"}))
+	assert.False(t, p.OptedIn(map[string]string{DefaultOptInKey: "false"}, nil))
+
+	err := p.NotOptedInError("payments", "payments-api")
+	assert.True(t, errors.Is(err, ErrNotOptedIn))
+}
@@ -0,0 +1,77 @@
+package reaper
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/kube"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+)
+
+// Reaper removes periodically the sidecars whose TTL expired
+type Reaper struct {
+	logger     *logging.Logger
+	kubeClient kube.IKubeClient
+	interval   time.Duration
+}
+
+// NewReaper creates a new instance of the Reaper looking for the expired sidecars at every interval; nothing is ever
+// removed when the interval is zero
+func New(logger *logging.Logger, kubeClient kube.IKubeClient, interval time.Duration) *Reaper {
+	return &Reaper{
+		logger:     logger,
+		kubeClient: kubeClient,
+		interval:   interval,
+	}
+}
+
+// Run removes the expired sidecars at start and then at every interval, until stopCh is closed.
+// The removals in progress are cancelled when stopCh is closed
+func (r *Reaper) Run(stopCh <-chan struct{}) {
+
+	if r.interval <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		r.reap(ctx)
+
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// private functions and methods
+
+func (r *Reaper) reap(ctx context.Context) {
+
+	removed, err := r.kubeClient.ReapExpiredSidecars(ctx, time.Now().UTC())
+	if err != nil {
+		// the sidecars left are removed at the next interval
+		r.logger.Log().Error("Error removing expired sidecars", zap.Int("removed", removed), zap.Error(err))
+		return
+	}
+
+	if removed > 0 {
+		r.logger.Log().Info("Expired sidecars removed", zap.Int("removed", removed))
+	}
+}
@@ -0,0 +1,48 @@
+package reaper
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/kube"
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+)
+
+func TestRun(t *testing.T) {
+	kubeClient := new(kube.KubeClientMock)
+
+	reaped := make(chan struct{}, 10)
+	kubeClient.On("ReapExpiredSidecars", mock.Anything, mock.Anything).Return(1, nil).Once()
+	kubeClient.On("ReapExpiredSidecars", mock.Anything, mock.Anything).Return(0, errors.New("cluster not reachable")).Run(func(mock.Arguments) {
+		reaped <- struct{}{}
+	})
+
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		New(logging.New(), kubeClient, 10*time.Millisecond).Run(stopCh)
+		close(done)
+	}()
+
+	// Check that the reaper goes on after a failure
+	<-reaped
+	<-reaped
+
+	close(stopCh)
+	<-done
+
+	kubeClient.AssertExpectations(t)
+}
+
+func TestRunDisabled(t *testing.T) {
+	kubeClient := new(kube.KubeClientMock)
+
+	// Check that nothing is done without an interval
+	New(logging.New(), kubeClient, 0).Run(make(chan struct{}))
+
+	kubeClient.AssertNotCalled(t, "ReapExpiredSidecars", mock.Anything, mock.Anything)
+}
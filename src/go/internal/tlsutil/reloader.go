@@ -0,0 +1,163 @@
+package tlsutil
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+)
+
+// DefaultCheckInterval is how often the certificate files are checked for changes
+const DefaultCheckInterval = 10 * time.Second
+
+type Options struct {
+	// PEM files of the server certificate chain and of its private key
+	CertFile string
+	KeyFile  string
+	// PEM file of the certificate authorities of the client certificates; client certificates are not verified when empty
+	ClientCAFile string
+	// how often the files are checked for changes; defaults to DefaultCheckInterval
+	CheckInterval time.Duration
+}
+
+// Reloader serves the TLS handshakes with the certificates read from disk, reading them again when the files change,
+// so that renewed certificates are served without a restart
+type Reloader struct {
+	logger   *logging.Logger
+	options  Options
+	contents [][]byte
+
+	mutex       sync.RWMutex
+	certificate *tls.Certificate
+	clientCAs   *x509.CertPool
+}
+
+// NewReloader creates a new instance of the Reloader, failing when the certificate files cannot be loaded
+func New(logger *logging.Logger, options Options) (*Reloader, error) {
+
+	if options.CertFile == "" || options.KeyFile == "" {
+		return nil, errors.New("both the certificate and the key files are required")
+	}
+
+	if options.CheckInterval <= 0 {
+		options.CheckInterval = DefaultCheckInterval
+	}
+
+	reloader := &Reloader{
+		logger:  logger,
+		options: options,
+	}
+
+	if _, err := reloader.reload(); err != nil {
+		return nil, err
+	}
+
+	return reloader, nil
+}
+
+// TLSConfig returns the server configuration serving the current certificates. When client certificate authorities
+// are configured, the client certificates presented are verified, and the handshakes with invalid ones fail;
+// requiring them is left to the handlers, so that probes and metrics scrapes can connect without any
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+
+			r.mutex.RLock()
+			defer r.mutex.RUnlock()
+
+			config := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.certificate},
+			}
+
+			if r.clientCAs != nil {
+				config.ClientAuth = tls.VerifyClientCertIfGiven
+				config.ClientCAs = r.clientCAs
+			}
+
+			return config, nil
+		},
+	}
+}
+
+// MutualTLS reports whether the client certificates are verified
+func (r *Reloader) MutualTLS() bool {
+	return r.options.ClientCAFile != ""
+}
+
+// Run checks the certificate files for changes until stopCh is closed
+func (r *Reloader) Run(stopCh <-chan struct{}) {
+
+	ticker := time.NewTicker(r.options.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			if reloaded, err := r.reload(); err != nil {
+				r.logger.Log().Error("Error reloading certificates, keeping the current ones", zap.Error(err))
+			} else if reloaded {
+				r.logger.Log().Info("Certificates reloaded", zap.String("certFile", r.options.CertFile))
+			}
+		}
+	}
+}
+
+// private functions and methods
+
+// reload loads the certificates again when any of the files changed, keeping the current ones if the new ones are invalid
+func (r *Reloader) reload() (bool, error) {
+
+	files := []string{r.options.CertFile, r.options.KeyFile}
+	if r.options.ClientCAFile != "" {
+		files = append(files, r.options.ClientCAFile)
+	}
+
+	contents := make([][]byte, len(files))
+	for i, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return false, err
+		}
+		contents[i] = content
+	}
+
+	if slices.EqualFunc(contents, r.contents, bytes.Equal) {
+		return false, nil
+	}
+
+	certificate, err := tls.X509KeyPair(contents[0], contents[1])
+	if err != nil {
+		return false, fmt.Errorf("certificate %s: %w", r.options.CertFile, err)
+	}
+
+	var clientCAs *x509.CertPool
+	if r.options.ClientCAFile != "" {
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(contents[2]) {
+			return false, fmt.Errorf("client CA %s: no valid PEM certificate found", r.options.ClientCAFile)
+		}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// the contents are remembered only once valid, so that invalid files are checked again until fixed
+	r.contents = contents
+	r.certificate = &certificate
+	r.clientCAs = clientCAs
+
+	return true, nil
+}
@@ -0,0 +1,240 @@
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+)
+
+// testAuthority issues the certificates of the tests
+type testAuthority struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	pem         []byte
+}
+
+func newTestAuthority(t *testing.T, name string) *testAuthority {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+
+	certificate, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+
+	return &testAuthority{
+		certificate: certificate,
+		key:         key,
+		pem:         pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue returns the PEM certificate and key of the subject, for servers or clients
+func (ta *testAuthority) issue(t *testing.T, serial int64, subject pkix.Name, usage x509.ExtKeyUsage) ([]byte, []byte) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      subject,
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ta.certificate, &key.PublicKey, ta.key)
+	assert.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+	authority := newTestAuthority(t, "server-ca")
+	cert, key := authority.issue(t, 2, pkix.Name{CommonName: "localhost"}, x509.ExtKeyUsageServerAuth)
+
+	certFile := writeFile(t, dir, "tls.crt", cert)
+	keyFile := writeFile(t, dir, "tls.key", key)
+
+	testCases := []struct {
+		name          string
+		options       Options
+		expectedError string
+	}{
+		{"missing key", Options{CertFile: certFile}, "both the certificate and the key files are required"},
+		{"unreadable certificate", Options{CertFile: filepath.Join(dir, "missing.crt"), KeyFile: keyFile}, "no such file"},
+		{"mismatched key", Options{CertFile: certFile, KeyFile: certFile}, "certificate " + certFile},
+		{"invalid client CA", Options{CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile}, "no valid PEM certificate found"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := New(logging.New(), tc.options)
+			assert.ErrorContains(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	authority := newTestAuthority(t, "server-ca")
+	cert, key := authority.issue(t, 2, pkix.Name{CommonName: "localhost"}, x509.ExtKeyUsageServerAuth)
+
+	reloader, err := New(logging.New(), Options{CertFile: writeFile(t, dir, "tls.crt", cert), KeyFile: writeFile(t, dir, "tls.key", key)})
+	assert.NoError(t, err)
+	assert.False(t, reloader.MutualTLS())
+
+	server := newTestServer(reloader)
+	defer server.Close()
+
+	client := newTestClient(authority, nil)
+	assert.Equal(t, int64(2), servedSerial(t, client, server.URL))
+
+	// Check that unchanged files are not reloaded
+	reloaded, err := reloader.reload()
+	assert.NoError(t, err)
+	assert.False(t, reloaded)
+
+	// Check that a half renewed pair is refused, the current certificate kept
+	renewedCert, renewedKey := authority.issue(t, 3, pkix.Name{CommonName: "localhost"}, x509.ExtKeyUsageServerAuth)
+	writeFile(t, dir, "tls.crt", renewedCert)
+
+	_, err = reloader.reload()
+	assert.Error(t, err)
+	assert.Equal(t, int64(2), servedSerial(t, client, server.URL))
+
+	writeFile(t, dir, "tls.key", renewedKey)
+
+	reloaded, err = reloader.reload()
+	assert.NoError(t, err)
+	assert.True(t, reloaded)
+
+	// Check that the renewed certificate is served to the new connections
+	assert.Equal(t, int64(3), servedSerial(t, newTestClient(authority, nil), server.URL))
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	serverAuthority := newTestAuthority(t, "server-ca")
+	clientAuthority := newTestAuthority(t, "client-ca")
+	cert, key := serverAuthority.issue(t, 2, pkix.Name{CommonName: "localhost"}, x509.ExtKeyUsageServerAuth)
+
+	reloader, err := New(logging.New(), Options{
+		CertFile:     writeFile(t, dir, "tls.crt", cert),
+		KeyFile:      writeFile(t, dir, "tls.key", key),
+		ClientCAFile: writeFile(t, dir, "ca.crt", clientAuthority.pem),
+	})
+	assert.NoError(t, err)
+	assert.True(t, reloader.MutualTLS())
+
+	server := newTestServer(reloader)
+	defer server.Close()
+
+	clientCert, clientKey := clientAuthority.issue(t, 4, pkix.Name{CommonName: "jane", Organization: []string{"sre"}}, x509.ExtKeyUsageClientAuth)
+	clientPair, err := tls.X509KeyPair(clientCert, clientKey)
+	assert.NoError(t, err)
+
+	// Check that the client certificate is verified and available to the handlers
+	response, err := newTestClient(serverAuthority, &clientPair).Get(server.URL)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "jane", response.Header.Get("X-Client"))
+		response.Body.Close()
+	}
+
+	// Check that clients without certificate can still connect
+	response, err = newTestClient(serverAuthority, nil).Get(server.URL)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "", response.Header.Get("X-Client"))
+		response.Body.Close()
+	}
+
+	// Check that certificates of other authorities are refused
+	otherCert, otherKey := serverAuthority.issue(t, 5, pkix.Name{CommonName: "mallory"}, x509.ExtKeyUsageClientAuth)
+	otherPair, err := tls.X509KeyPair(otherCert, otherKey)
+	assert.NoError(t, err)
+
+	_, err = newTestClient(serverAuthority, &otherPair).Get(server.URL)
+	assert.Error(t, err)
+}
+
+// private functions and methods
+
+// newTestServer starts a server answering with the serial of its certificate and the client certificate common name
+func newTestServer(reloader *Reloader) *httptest.Server {
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, request *http.Request) {
+		if len(request.TLS.VerifiedChains) > 0 {
+			w.Header().Set("X-Client", request.TLS.VerifiedChains[0][0].Subject.CommonName)
+		}
+	}))
+	server.TLS = reloader.TLSConfig()
+	server.StartTLS()
+
+	return server
+}
+
+func newTestClient(authority *testAuthority, certificate *tls.Certificate) *http.Client {
+
+	roots := x509.NewCertPool()
+	roots.AddCert(authority.certificate)
+
+	config := &tls.Config{RootCAs: roots, ServerName: "localhost"}
+	if certificate != nil {
+		// presented whatever the authorities accepted by the server
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) { return certificate, nil }
+	}
+
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: config, DisableKeepAlives: true}}
+}
+
+func servedSerial(t *testing.T, client *http.Client, url string) int64 {
+
+	response, err := client.Get(url)
+	if !assert.NoError(t, err) {
+		return 0
+	}
+	defer response.Body.Close()
+
+	return response.TLS.PeerCertificates[0].SerialNumber.Int64()
+}
+
+func writeFile(t *testing.T, dir string, name string, content []byte) string {
+
+	path := filepath.Join(dir, name)
+	assert.NoError(t, os.WriteFile(path, content, 0o600))
+
+	return path
+}
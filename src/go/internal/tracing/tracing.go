@@ -0,0 +1,127 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName is the name of the service reported in the spans, and of the tracer creating them
+const ServiceName = "kube-ondemand-sidecar-injector"
+
+// Exporters of the spans
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+type Options struct {
+	// ExporterOTLP, ExporterStdout or ExporterNone, the default, which records no span
+	Exporter string
+	// writer of the ExporterStdout spans, os.Stdout when nil
+	Writer io.Writer
+}
+
+// Setup installs the global tracer provider exporting the spans as configured by the options, and the W3C trace context
+// and baggage propagators, so that incoming trace context is continued. The OTLP exporter is configured by the standard
+// OTEL_EXPORTER_OTLP_* environment variables. The returned function flushes and stops the exporter
+func Setup(ctx context.Context, options Options) (shutdown func(context.Context) error, err error) {
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+
+	switch options.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx)
+	case ExporterStdout:
+		writer := options.Writer
+		if writer == nil {
+			writer = os.Stdout
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(writer))
+	default:
+		err = fmt.Errorf("exporter '%s' is not one of %s, %s, %s", options.Exporter, ExporterOTLP, ExporterStdout, ExporterNone)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	serviceResource, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(serviceResource),
+	)
+
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Middleware returns the gin middleware starting the span of every request, continuing the incoming trace context if any.
+// The probes and the metrics scrapes are not traced
+func Middleware() gin.HandlerFunc {
+	return otelgin.Middleware(ServiceName, otelgin.WithFilter(func(request *http.Request) bool {
+		return !slices.Contains(untracedPaths, request.URL.Path)
+	}))
+}
+
+var untracedPaths = []string{"/metrics", "/healthz", "/readyz"}
+
+// Start starts a span named after the operation, child of the span of the context if any
+func Start(ctx context.Context, operation string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(ServiceName).Start(ctx, operation, trace.WithAttributes(attributes...))
+}
+
+// End ends the span, recording the error if not nil
+func End(span trace.Span, err error) {
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// Target returns the attributes of the span of a call acting on a deployment, the empty ones omitted
+func Target(cluster string, namespace string, name string) []attribute.KeyValue {
+
+	attributes := make([]attribute.KeyValue, 0, 3)
+
+	if cluster != "" {
+		attributes = append(attributes, attribute.String("injector.cluster", cluster))
+	}
+	if namespace != "" {
+		attributes = append(attributes, attribute.String("k8s.namespace.name", namespace))
+	}
+	if name != "" {
+		attributes = append(attributes, attribute.String("k8s.deployment.name", name))
+	}
+
+	return attributes
+}
@@ -0,0 +1,113 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSetup(t *testing.T) {
+	shutdown, err := Setup(context.Background(), Options{Exporter: ExporterNone})
+	assert.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+
+	_, err = Setup(context.Background(), Options{Exporter: "zipkin"})
+	assert.ErrorContains(t, err, "exporter 'zipkin' is not one of")
+
+	var buffer bytes.Buffer
+
+	shutdown, err = Setup(context.Background(), Options{Exporter: ExporterStdout, Writer: &buffer})
+	assert.NoError(t, err)
+
+	_, span := Start(context.Background(), "KubeClient.SetSidecar", Target("production", "payments", "api")...)
+	End(span, nil)
+
+	// Check that the span is flushed on shutdown
+	assert.NoError(t, shutdown(context.Background()))
+	assert.Contains(t, buffer.String(), `"Name":"KubeClient.SetSidecar"`)
+	assert.Contains(t, buffer.String(), `"Value":"`+ServiceName+`"`)
+}
+
+func TestMiddleware(t *testing.T) {
+	recorder := useSpanRecorder(t)
+
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/api/v2/namespaces/:namespace/deployments", func(c *gin.Context) {
+		// Check that the handler spans are children of the request span
+		_, span := Start(c.Request.Context(), "InjectorController.GetDeployments")
+		End(span, nil)
+		c.Status(http.StatusOK)
+	})
+	router.GET("/metrics", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	parentTraceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	request := httptest.NewRequest("GET", "/api/v2/namespaces/payments/deployments", nil)
+	request.Header.Set("traceparent", "00-"+parentTraceID+"-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/metrics", nil))
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 2) {
+		assert.Equal(t, "InjectorController.GetDeployments", spans[0].Name())
+		assert.Equal(t, "GET /api/v2/namespaces/:namespace/deployments", spans[1].Name())
+		assert.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+
+		// Check that the incoming trace context is continued
+		assert.Equal(t, parentTraceID, spans[1].SpanContext().TraceID().String())
+	}
+}
+
+func TestEnd(t *testing.T) {
+	recorder := useSpanRecorder(t)
+
+	_, span := Start(context.Background(), "KubeClient.ClearSidecar")
+	End(span, errors.New("deployment not found"))
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, codes.Error, spans[0].Status().Code)
+		assert.Equal(t, "deployment not found", spans[0].Status().Description)
+		assert.Len(t, spans[0].Events(), 1)
+	}
+}
+
+func TestTarget(t *testing.T) {
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.String("injector.cluster", "production"),
+		attribute.String("k8s.namespace.name", "payments"),
+		attribute.String("k8s.deployment.name", "api"),
+	}, Target("production", "payments", "api"))
+
+	assert.Equal(t, []attribute.KeyValue{attribute.String("k8s.namespace.name", "payments")}, Target("", "payments", ""))
+}
+
+// private functions and methods
+
+// useSpanRecorder installs, for the duration of the test, a global tracer provider recording the ended spans
+func useSpanRecorder(t *testing.T) *tracetest.SpanRecorder {
+
+	_, err := Setup(context.Background(), Options{Exporter: ExporterNone})
+	assert.NoError(t, err)
+
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	return recorder
+}
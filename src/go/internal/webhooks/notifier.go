@@ -0,0 +1,387 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+	webhooksmodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/webhooks"
+)
+
+// Formats of the webhook bodies
+const (
+	FormatJSON  = "json"
+	FormatSlack = "slack"
+)
+
+// Headers of the webhook requests
+const (
+	EventHeader     = "X-Injector-Event"
+	DeliveryHeader  = "X-Injector-Delivery"
+	TimestampHeader = "X-Injector-Timestamp"
+	// HMAC-SHA256 of the timestamp header, a dot and the body, keyed by the webhook secret, as sha256=<hex>
+	SignatureHeader = "X-Injector-Signature-256"
+)
+
+const (
+	defaultMaxAttempts = 5
+	defaultBackoff     = time.Second
+	defaultTimeout     = 10 * time.Second
+	defaultLogCapacity = 1000
+)
+
+// Webhook is an endpoint notified of the sidecar lifecycle
+type Webhook struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// FormatJSON, the default, or FormatSlack
+	Format string `json:"format"`
+	// key of the HMAC signature of the requests; requests are not signed when empty
+	Secret string `json:"secret"`
+	// event types notified to the webhook, all of them when empty
+	Events []string `json:"events"`
+}
+
+type Options struct {
+	Webhooks []Webhook
+	// attempts of every delivery before giving up; defaults to 5
+	MaxAttempts int
+	// wait before the first retry, doubled at every further one; defaults to 1s
+	Backoff time.Duration
+	// timeout of every attempt; defaults to 10s
+	Timeout time.Duration
+	// deliveries kept in the delivery log; defaults to 1000
+	LogCapacity int
+}
+
+// Notifier delivers the events to the configured webhooks, in background and with retries, keeping a log of the deliveries
+type Notifier struct {
+	logger      *logging.Logger
+	webhooks    []Webhook
+	maxAttempts int
+	backoff     time.Duration
+	client      *http.Client
+	wg          sync.WaitGroup
+
+	// guards the webhooks and the delivery log
+	mutex       sync.Mutex
+	logCapacity int
+	deliveries  []*webhooksmodels.Delivery
+}
+
+// NewNotifier creates a new instance of the Notifier delivering to the webhooks of the options
+func New(logger *logging.Logger, options Options) (*Notifier, error) {
+
+	if err := Validate(options.Webhooks); err != nil {
+		return nil, err
+	}
+
+	notifier := &Notifier{
+		logger:      logger,
+		webhooks:    options.Webhooks,
+		maxAttempts: options.MaxAttempts,
+		backoff:     options.Backoff,
+		client:      &http.Client{Timeout: options.Timeout},
+		logCapacity: options.LogCapacity,
+		deliveries:  make([]*webhooksmodels.Delivery, 0),
+	}
+
+	if notifier.maxAttempts <= 0 {
+		notifier.maxAttempts = defaultMaxAttempts
+	}
+	if notifier.backoff <= 0 {
+		notifier.backoff = defaultBackoff
+	}
+	if options.Timeout <= 0 {
+		notifier.client.Timeout = defaultTimeout
+	}
+	if notifier.logCapacity <= 0 {
+		notifier.logCapacity = defaultLogCapacity
+	}
+
+	return notifier, nil
+}
+
+// Validate checks the name, the url and the format of every webhook
+func Validate(webhooks []Webhook) error {
+
+	for i, webhook := range webhooks {
+		if webhook.Name == "" {
+			return fmt.Errorf("webhook %d: name is required", i)
+		}
+
+		if parsed, err := url.Parse(webhook.URL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return fmt.Errorf("webhook %s: url '%s' is not a valid http or https url", webhook.Name, webhook.URL)
+		}
+
+		if webhook.Format != "" && webhook.Format != FormatJSON && webhook.Format != FormatSlack {
+			return fmt.Errorf("webhook %s: format '%s' is not one of %s, %s", webhook.Name, webhook.Format, FormatJSON, FormatSlack)
+		}
+	}
+
+	return nil
+}
+
+// SetWebhooks replaces the webhooks notified of the next events, the pending deliveries going on with the previous ones
+func (n *Notifier) SetWebhooks(webhooks []Webhook) error {
+
+	if err := Validate(webhooks); err != nil {
+		return err
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.webhooks = webhooks
+
+	return nil
+}
+
+// ParseWebhooks parses the webhooks from their JSON array, as found in the configuration
+func ParseWebhooks(value string) ([]Webhook, error) {
+
+	webhooks := make([]Webhook, 0)
+	if value == "" {
+		return webhooks, nil
+	}
+
+	if err := json.Unmarshal([]byte(value), &webhooks); err != nil {
+		return nil, fmt.Errorf("webhooks are not a valid JSON array: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+// Notify delivers the event, in background, to every webhook subscribed to its type. Nothing is done on a nil Notifier
+func (n *Notifier) Notify(event *webhooksmodels.Event) {
+
+	if n == nil {
+		return
+	}
+
+	if event.ID == "" {
+		event.ID = newID()
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	n.mutex.Lock()
+	webhooks := n.webhooks
+	n.mutex.Unlock()
+
+	for _, webhook := range webhooks {
+		if len(webhook.Events) > 0 && !slices.Contains(webhook.Events, event.Type) {
+			continue
+		}
+
+		body, err := bodyOf(&webhook, event)
+		if err != nil {
+			n.logger.Log().Error("Error encoding webhook body", zap.String("webhook", webhook.Name), zap.Error(err))
+			continue
+		}
+
+		delivery := n.startDelivery(&webhook, event)
+
+		n.wg.Add(1)
+		go func(webhook Webhook) {
+			defer n.wg.Done()
+			n.deliver(&webhook, delivery, body)
+		}(webhook)
+	}
+}
+
+// Deliveries returns the most recent deliveries, oldest first, up to limit when positive
+func (n *Notifier) Deliveries(limit int) []webhooksmodels.Delivery {
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	deliveries := n.deliveries
+	if limit > 0 && len(deliveries) > limit {
+		deliveries = deliveries[len(deliveries)-limit:]
+	}
+
+	result := make([]webhooksmodels.Delivery, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		result = append(result, *delivery)
+	}
+
+	return result
+}
+
+// Wait blocks until all the pending deliveries are over, delivered or failed
+func (n *Notifier) Wait() {
+
+	if n == nil {
+		return
+	}
+
+	n.wg.Wait()
+}
+
+// private functions and methods
+
+// deliver sends the body to the webhook, retrying with exponential backoff on network errors, 429 and 5xx responses
+func (n *Notifier) deliver(webhook *Webhook, delivery *webhooksmodels.Delivery, body []byte) {
+
+	backoff := n.backoff
+
+	for attempt := 1; ; attempt++ {
+
+		statusCode, err := n.send(webhook, delivery, body)
+
+		retry := err != nil || statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+		if err == nil && statusCode >= http.StatusMultipleChoices {
+			err = fmt.Errorf("webhook responded %d %s", statusCode, http.StatusText(statusCode))
+		}
+
+		if err == nil {
+			n.updateDelivery(delivery, attempt, statusCode, webhooksmodels.DeliveryDelivered, nil)
+			return
+		}
+
+		if !retry || attempt >= n.maxAttempts {
+			n.logger.Log().Error("Error delivering webhook", zap.String("webhook", webhook.Name), zap.String("delivery", delivery.ID), zap.Int("attempts", attempt), zap.Error(err))
+
+			n.updateDelivery(delivery, attempt, statusCode, webhooksmodels.DeliveryFailed, err)
+			return
+		}
+
+		n.updateDelivery(delivery, attempt, statusCode, webhooksmodels.DeliveryPending, err)
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// send performs a single attempt of the delivery, returning the http status of the response
+func (n *Notifier) send(webhook *Webhook, delivery *webhooksmodels.Delivery, body []byte) (int, error) {
+
+	request, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(EventHeader, delivery.EventType)
+	request.Header.Set(DeliveryHeader, delivery.ID)
+	request.Header.Set(TimestampHeader, timestamp)
+
+	if webhook.Secret != "" {
+		request.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, body))
+	}
+
+	response, err := n.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	return response.StatusCode, nil
+}
+
+// Sign returns the signature of the body sent at the timestamp, as found in the SignatureHeader,
+// so that receivers can verify it by computing it again with the shared secret
+func Sign(secret string, timestamp string, body []byte) string {
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// startDelivery adds a pending delivery of the event to the delivery log, evicting the oldest ones beyond capacity
+func (n *Notifier) startDelivery(webhook *Webhook, event *webhooksmodels.Event) *webhooksmodels.Delivery {
+
+	now := time.Now().UTC()
+
+	delivery := &webhooksmodels.Delivery{
+		ID:        newID(),
+		EventID:   event.ID,
+		EventType: event.Type,
+		Webhook:   webhook.Name,
+		Status:    webhooksmodels.DeliveryPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.deliveries = append(n.deliveries, delivery)
+	if len(n.deliveries) > n.logCapacity {
+		n.deliveries = n.deliveries[len(n.deliveries)-n.logCapacity:]
+	}
+
+	return delivery
+}
+
+func (n *Notifier) updateDelivery(delivery *webhooksmodels.Delivery, attempts int, statusCode int, status string, err error) {
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	delivery.Attempts = attempts
+	delivery.StatusCode = statusCode
+	delivery.Status = status
+	delivery.UpdatedAt = time.Now().UTC()
+	delivery.Error = ""
+
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+}
+
+// bodyOf encodes the event in the format of the webhook
+func bodyOf(webhook *Webhook, event *webhooksmodels.Event) ([]byte, error) {
+
+	if webhook.Format != FormatSlack {
+		return json.Marshal(event)
+	}
+
+	return json.Marshal(slackMessageOf(event))
+}
+
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// slackMessageOf returns the message of the event for the Slack-compatible incoming webhooks
+func slackMessageOf(event *webhooksmodels.Event) slackMessage {
+
+	target := event.Namespace + "/" + event.DeploymentName
+	if event.Cluster != "" {
+		target = event.Cluster + ": " + target
+	}
+
+	icon := ":information_source:"
+	// the failures are the only warnings among the events
+	if event.Type == "SidecarFailed" {
+		icon = ":warning:"
+	}
+
+	return slackMessage{Text: fmt.Sprintf("%s *%s* on `%s`\n%s", icon, event.Type, target, event.Message)}
+}
+
+func newID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
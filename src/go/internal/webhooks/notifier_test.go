@@ -0,0 +1,270 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alesspanms/kube-ondemand-sidecar-injector/internal/logging"
+	webhooksmodels "github.com/alesspanms/kube-ondemand-sidecar-injector/internal/models/webhooks"
+)
+
+// receiver is a local webhook receiver answering with the given statuses in turn, then with 200
+type receiver struct {
+	mutex    sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, request *http.Request) {
+
+	body, _ := io.ReadAll(request.Body)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.requests = append(r.requests, request)
+	r.bodies = append(r.bodies, body)
+
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+
+	w.WriteHeader(status)
+}
+
+func newTestNotifier(t *testing.T, webhooks ...Webhook) *Notifier {
+
+	notifier, err := New(logging.New(), Options{Webhooks: webhooks, MaxAttempts: 3, Backoff: time.Millisecond})
+	assert.NoError(t, err)
+
+	return notifier
+}
+
+func newTestEvent(eventType string) *webhooksmodels.Event {
+	return &webhooksmodels.Event{
+		Type:           eventType,
+		Namespace:      "payments",
+		DeploymentName: "payments-api",
+		ContainerName:  "prefix-debug",
+		Image:          "busybox",
+		Caller:         "jane",
+		Message:        "Sidecar container prefix-debug with image busybox injected by jane",
+	}
+}
+
+func TestNotifyJSON(t *testing.T) {
+	target := &receiver{}
+	server := httptest.NewServer(target)
+	defer server.Close()
+
+	notifier := newTestNotifier(t, Webhook{Name: "tickets", URL: server.URL, Secret: "s3cr3t"})
+
+	notifier.Notify(newTestEvent("SidecarInjected"))
+	notifier.Wait()
+
+	if assert.Len(t, target.requests, 1) {
+		request := target.requests[0]
+		assert.Equal(t, "SidecarInjected", request.Header.Get(EventHeader))
+		assert.NotEmpty(t, request.Header.Get(DeliveryHeader))
+
+		// Check that the signature can be verified by the receiver with the shared secret
+		assert.Equal(t, Sign("s3cr3t", request.Header.Get(TimestampHeader), target.bodies[0]), request.Header.Get(SignatureHeader))
+
+		var event webhooksmodels.Event
+		assert.NoError(t, json.Unmarshal(target.bodies[0], &event))
+		assert.Equal(t, "payments-api", event.DeploymentName)
+		assert.NotEmpty(t, event.ID)
+		assert.False(t, event.Time.IsZero())
+	}
+
+	deliveries := notifier.Deliveries(0)
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, webhooksmodels.DeliveryDelivered, deliveries[0].Status)
+		assert.Equal(t, "tickets", deliveries[0].Webhook)
+		assert.Equal(t, 1, deliveries[0].Attempts)
+		assert.Equal(t, http.StatusOK, deliveries[0].StatusCode)
+	}
+}
+
+func TestNotifySlack(t *testing.T) {
+	target := &receiver{}
+	server := httptest.NewServer(target)
+	defer server.Close()
+
+	notifier := newTestNotifier(t, Webhook{Name: "chatops", URL: server.URL, Format: FormatSlack})
+
+	event := newTestEvent("SidecarFailed")
+	event.Cluster = "production"
+	notifier.Notify(event)
+	notifier.Wait()
+
+	if assert.Len(t, target.requests, 1) {
+		assert.Empty(t, target.requests[0].Header.Get(SignatureHeader))
+		assert.JSONEq(t, `{"text": ":warning: *SidecarFailed* on `+"`production: payments/payments-api`"+`\nSidecar container prefix-debug with image busybox injected by jane"}`, string(target.bodies[0]))
+	}
+}
+
+func TestNotifyRetries(t *testing.T) {
+	testCases := []struct {
+		name             string
+		statuses         []int
+		expectedStatus   string
+		expectedAttempts int
+	}{
+		{"retried until delivered", []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}, webhooksmodels.DeliveryDelivered, 3},
+		{"retried until exhausted", []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}, webhooksmodels.DeliveryFailed, 3},
+		{"not retried on client error", []int{http.StatusBadRequest}, webhooksmodels.DeliveryFailed, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			target := &receiver{statuses: tc.statuses}
+			server := httptest.NewServer(target)
+			defer server.Close()
+
+			notifier := newTestNotifier(t, Webhook{Name: "tickets", URL: server.URL})
+
+			notifier.Notify(newTestEvent("SidecarRemoved"))
+			notifier.Wait()
+
+			assert.Len(t, target.requests, tc.expectedAttempts)
+
+			deliveries := notifier.Deliveries(0)
+			if assert.Len(t, deliveries, 1) {
+				assert.Equal(t, tc.expectedStatus, deliveries[0].Status)
+				assert.Equal(t, tc.expectedAttempts, deliveries[0].Attempts)
+			}
+
+			// the retries of a delivery all carry the same delivery ID
+			for _, request := range target.requests {
+				assert.Equal(t, deliveries[0].ID, request.Header.Get(DeliveryHeader))
+			}
+		})
+	}
+}
+
+func TestNotifyEventsFilter(t *testing.T) {
+	target := &receiver{}
+	server := httptest.NewServer(target)
+	defer server.Close()
+
+	notifier := newTestNotifier(t, Webhook{Name: "tickets", URL: server.URL, Events: []string{"SidecarExpired", "SidecarFailed"}})
+
+	notifier.Notify(newTestEvent("SidecarInjected"))
+	notifier.Notify(newTestEvent("SidecarExpired"))
+	notifier.Wait()
+
+	if assert.Len(t, target.requests, 1) {
+		assert.Equal(t, "SidecarExpired", target.requests[0].Header.Get(EventHeader))
+	}
+}
+
+func TestNotifyUnreachable(t *testing.T) {
+	server := httptest.NewServer(&receiver{})
+	server.Close()
+
+	notifier := newTestNotifier(t, Webhook{Name: "tickets", URL: server.URL})
+
+	notifier.Notify(newTestEvent("SidecarInjected"))
+	notifier.Wait()
+
+	deliveries := notifier.Deliveries(0)
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, webhooksmodels.DeliveryFailed, deliveries[0].Status)
+		assert.Equal(t, 3, deliveries[0].Attempts)
+		assert.NotEmpty(t, deliveries[0].Error)
+	}
+}
+
+func TestDeliveryLogCapacity(t *testing.T) {
+	server := httptest.NewServer(&receiver{})
+	defer server.Close()
+
+	notifier, err := New(logging.New(), Options{Webhooks: []Webhook{{Name: "tickets", URL: server.URL}}, LogCapacity: 2})
+	assert.NoError(t, err)
+
+	for _, eventType := range []string{"SidecarInjected", "SidecarRemoved", "SidecarExpired"} {
+		notifier.Notify(newTestEvent(eventType))
+	}
+	notifier.Wait()
+
+	// Check that the oldest delivery is evicted, and that the limit returns the most recent ones
+	deliveries := notifier.Deliveries(0)
+	if assert.Len(t, deliveries, 2) {
+		assert.Equal(t, "SidecarRemoved", deliveries[0].EventType)
+	}
+
+	deliveries = notifier.Deliveries(1)
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, "SidecarExpired", deliveries[0].EventType)
+	}
+}
+
+func TestSetWebhooks(t *testing.T) {
+	first, second := &receiver{}, &receiver{}
+	firstServer, secondServer := httptest.NewServer(first), httptest.NewServer(second)
+	defer firstServer.Close()
+	defer secondServer.Close()
+
+	notifier := newTestNotifier(t, Webhook{Name: "first", URL: firstServer.URL})
+
+	assert.NoError(t, notifier.SetWebhooks([]Webhook{{Name: "second", URL: secondServer.URL}}))
+
+	// Check that invalid webhooks are refused, the current ones kept
+	assert.Error(t, notifier.SetWebhooks([]Webhook{{Name: "third", URL: "ftp://example.com"}}))
+
+	notifier.Notify(newTestEvent("SidecarInjected"))
+	notifier.Wait()
+
+	assert.Empty(t, first.requests)
+	assert.Len(t, second.requests, 1)
+}
+
+func TestNewInvalidWebhooks(t *testing.T) {
+	testCases := []struct {
+		name    string
+		webhook Webhook
+	}{
+		{"missing name", Webhook{URL: "http://localhost"}},
+		{"invalid url", Webhook{Name: "tickets", URL: "localhost:8080"}},
+		{"invalid format", Webhook{Name: "tickets", URL: "http://localhost", Format: "xml"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := New(logging.New(), Options{Webhooks: []Webhook{tc.webhook}})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseWebhooks(t *testing.T) {
+	webhooks, err := ParseWebhooks(`[{"name": "chatops", "url": "https://hooks.slack.com/services/T0/B0/X", "format": "slack", "events": ["SidecarFailed"]}]`)
+	assert.NoError(t, err)
+	assert.Equal(t, []Webhook{{Name: "chatops", URL: "https://hooks.slack.com/services/T0/B0/X", Format: FormatSlack, Events: []string{"SidecarFailed"}}}, webhooks)
+
+	webhooks, err = ParseWebhooks("")
+	assert.NoError(t, err)
+	assert.Empty(t, webhooks)
+
+	_, err = ParseWebhooks("chatops")
+	assert.Error(t, err)
+}
+
+func TestNotifyWithoutNotifier(t *testing.T) {
+	var notifier *Notifier
+
+	assert.NotPanics(t, func() {
+		notifier.Notify(newTestEvent("SidecarInjected"))
+		notifier.Wait()
+	})
+}
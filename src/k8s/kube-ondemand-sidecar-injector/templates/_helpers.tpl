@@ -60,3 +60,72 @@ Create the name of the service account to use
 {{- default "default" .Values.serviceAccount.name }}
 {{- end }}
 {{- end }}
+
+{{/*
+Probe of the values, switched to HTTPS when TLS is enabled
+*/}}
+{{- define "kube-ondemand-sidecar-injector.probe" -}}
+{{- $probe := deepCopy .probe }}
+{{- if and .root.Values.tls.enabled $probe.httpGet }}
+{{- $_ := set $probe.httpGet "scheme" "HTTPS" }}
+{{- end }}
+{{- toYaml $probe }}
+{{- end }}
+
+{{/*
+Settings of the configuration file requiring a restart when changed
+*/}}
+{{- define "kube-ondemand-sidecar-injector.structuralConfig" -}}
+server:
+  port: {{ .Values.service.port }}
+  shutdownDelay: {{ .Values.shutdown.delay | quote }}
+  shutdownTimeout: {{ .Values.shutdown.timeout | quote }}
+  {{- if .Values.tls.enabled }}
+  tls:
+    certFile: /etc/kube-ondemand-sidecar-injector/tls/tls.crt
+    keyFile: /etc/kube-ondemand-sidecar-injector/tls/tls.key
+    {{- if .Values.tls.mutualTLS }}
+    clientCAFile: /etc/kube-ondemand-sidecar-injector/tls/ca.crt
+    {{- end }}
+  {{- end }}
+auth:
+  kubeAuth: {{ .Values.kubeAuth.enabled }}
+  impersonate: {{ .Values.kubeAuth.impersonate }}
+sidecar:
+  namePrefix: {{ .Values.sidecarNamePrefix | quote }}
+  historyLimit: {{ .Values.historyLimit }}
+  reapInterval: {{ .Values.reapInterval | quote }}
+kubernetes:
+  {{- if .Values.clusters.kubeconfigSecret }}
+  kubeconfigDir: /etc/kube-ondemand-sidecar-injector/clusters
+  {{- end }}
+  defaultCluster: {{ .Values.clusters.default | quote }}
+  operationTimeout: {{ .Values.kubeOperationTimeout | quote }}
+  cacheEnabled: {{ .Values.cache.enabled }}
+audit:
+  sink: {{ .Values.audit.sink | quote }}
+  filePath: {{ .Values.audit.filePath | quote }}
+operations:
+  store: {{ .Values.operations.store | quote }}
+  dir: {{ .Values.operations.dir | quote }}
+tracing:
+  exporter: {{ .Values.tracing.exporter | quote }}
+leaderElection:
+  enabled: {{ .Values.leaderElection.enabled }}
+  namespace: {{ .Release.Namespace | quote }}
+  leaseName: {{ include "kube-ondemand-sidecar-injector.fullname" . | quote }}
+{{- end }}
+
+{{/*
+Settings of the configuration file reloaded when changed
+*/}}
+{{- define "kube-ondemand-sidecar-injector.reloadableConfig" -}}
+policy:
+  allowedNamespaces: {{ toJson .Values.policy.allowedNamespaces }}
+  deniedNamespaces: {{ toJson .Values.policy.deniedNamespaces }}
+  optIn:
+    required: {{ .Values.policy.optIn.required }}
+    key: {{ .Values.policy.optIn.key | quote }}
+    value: {{ .Values.policy.optIn.value | quote }}
+webhooks: {{ toJson .Values.webhooks }}
+{{- end }}